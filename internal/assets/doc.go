@@ -17,8 +17,14 @@ package assets
 //go:generate ../../utils/generate.sh --out-var KubeadmSetupScriptCode --out-package assets  --out-file generated_kubeadm_setup.go ./static/kubeadm-setup.sh
 //go:generate ../../utils/generate.sh --out-var KubeletSysconfigCode --out-package assets --out-file generated_kubelet_sysconfig.go ./static/kubelet.sysconfig
 //go:generate ../../utils/generate.sh --out-var KubeadmDropinCode --out-package assets --out-file generated_kubeadm_dropin.go ./static/kubeadm-dropin.conf
+//go:generate ../../utils/generate.sh --out-var KubeletEtcdDropinCode --out-package assets --out-file generated_kubelet_etcd_dropin.go ./static/kubelet-etcd-dropin.conf
 //go:generate ../../utils/generate.sh --out-var KubeletServiceCode --out-package assets --out-file generated_kubelet_service.go ./static/service.conf
 //go:generate ../../utils/generate.sh --out-var CNIDefConfCode --out-package assets --out-file generated_cni_conf.go ./static/cni-default.conflist
 //go:generate ../../utils/generate.sh --out-var FlannelManifestCode --out-package assets --out-file generated_flannel_manifest.go ./static/kube-flannel.yml
 //go:generate ../../utils/generate.sh --out-var CloudProviderCode --out-package assets --out-file cloud_provider_manifest.go ./static/cloud-provider.yml
 //go:generate ../../utils/generate.sh --out-var WeaveManifestCode --out-package assets --out-file weave_manifest.go ./static/weave.yml
+//go:generate ../../utils/generate.sh --out-var CalicoManifestCode --out-package assets --out-file generated_calico_manifest.go ./static/calico.yml
+//go:generate ../../utils/generate.sh --out-var CiliumManifestCode --out-package assets --out-file generated_cilium_manifest.go ./static/cilium.yml
+//go:generate ../../utils/generate.sh --out-var AuditPolicyCode --out-package assets --out-file generated_audit_policy.go ./static/audit-policy.yaml
+//go:generate ../../utils/generate.sh --out-var PSPManifestCode --out-package assets --out-file generated_psp_manifest.go ./static/psp.yml
+//go:generate ../../utils/generate.sh --out-var KubeVipManifestCode --out-package assets --out-file generated_kube_vip_manifest.go ./static/kube-vip.yml
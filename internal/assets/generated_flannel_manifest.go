@@ -114,6 +114,9 @@ data:
         {
           "type": "flannel",
           "delegate": {
+{{- if .flannel_mtu}}
+            "mtu": {{.flannel_mtu}},
+{{- end}}
             "hairpinMode": true,
             "isDefaultGateway": true
           }
@@ -178,6 +181,12 @@ spec:
           args:
             - --ip-masq
             - --kube-subnet-mgr
+{{- if .flannel_iface}}
+            - --iface={{.flannel_iface}}
+{{- end}}
+{{- if .flannel_iface_regex}}
+            - --iface-regex={{.flannel_iface_regex}}
+{{- end}}
           resources:
             requests:
               cpu: "100m"
@@ -258,6 +267,12 @@ spec:
           args:
             - --ip-masq
             - --kube-subnet-mgr
+{{- if .flannel_iface}}
+            - --iface={{.flannel_iface}}
+{{- end}}
+{{- if .flannel_iface_regex}}
+            - --iface-regex={{.flannel_iface_regex}}
+{{- end}}
           resources:
             requests:
               cpu: "100m"
@@ -338,6 +353,12 @@ spec:
           args:
             - --ip-masq
             - --kube-subnet-mgr
+{{- if .flannel_iface}}
+            - --iface={{.flannel_iface}}
+{{- end}}
+{{- if .flannel_iface_regex}}
+            - --iface-regex={{.flannel_iface_regex}}
+{{- end}}
           resources:
             requests:
               cpu: "100m"
@@ -418,6 +439,12 @@ spec:
           args:
             - --ip-masq
             - --kube-subnet-mgr
+{{- if .flannel_iface}}
+            - --iface={{.flannel_iface}}
+{{- end}}
+{{- if .flannel_iface_regex}}
+            - --iface-regex={{.flannel_iface_regex}}
+{{- end}}
           resources:
             requests:
               cpu: "100m"
@@ -498,6 +525,12 @@ spec:
           args:
             - --ip-masq
             - --kube-subnet-mgr
+{{- if .flannel_iface}}
+            - --iface={{.flannel_iface}}
+{{- end}}
+{{- if .flannel_iface_regex}}
+            - --iface-regex={{.flannel_iface_regex}}
+{{- end}}
           resources:
             requests:
               cpu: "100m"
@@ -17,6 +17,18 @@ items:
       labels:
         name: weave-net
       namespace: kube-system
+{{- if .weave_password}}
+  - apiVersion: v1
+    kind: Secret
+    metadata:
+      name: weave-passwd
+      labels:
+        name: weave-net
+      namespace: kube-system
+    type: Opaque
+    data:
+      weave-passwd: {{.weave_password}}
+{{- end}}
   - apiVersion: rbac.authorization.k8s.io/v1beta1
     kind: ClusterRole
     metadata:
@@ -150,6 +162,21 @@ items:
                     fieldRef:
                       apiVersion: v1
                       fieldPath: spec.nodeName
+{{- if .weave_password}}
+                - name: WEAVE_PASSWORD
+                  valueFrom:
+                    secretKeyRef:
+                      name: weave-passwd
+                      key: weave-passwd
+{{- end}}
+{{- if .weave_ipalloc_range}}
+                - name: IPALLOC_RANGE
+                  value: "{{.weave_ipalloc_range}}"
+{{- end}}
+{{- if .weave_mtu}}
+                - name: WEAVE_MTU
+                  value: "{{.weave_mtu}}"
+{{- end}}
               image: 'docker.io/weaveworks/weave-kube:2.5.2'
               readinessProbe:
                 httpGet:
@@ -0,0 +1,123 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssh
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	// namespace where the control plane containers run
+	kubeSystemNamespace = "kube-system"
+
+	// labels set by the kubelet in all the containers
+	labelContainerName = "io.kubernetes.container.name"
+	labelPodNamespace  = "io.kubernetes.pod.namespace"
+)
+
+var (
+	// ErrContainerNotFound is the container has not been found
+	ErrContainerNotFound = errors.New("container not found")
+)
+
+// ContainerRuntime is the container runtime used by the kubelet in the remote machine
+type ContainerRuntime interface {
+	// Name returns the name of the runtime
+	Name() string
+
+	// Service returns the systemd service of the runtime (if known)
+	Service() string
+
+	// GetContainerCmd returns the command for getting the IDs of the running
+	// containers for the Kubernetes container `name` in a `namespace`
+	GetContainerCmd(namespace string, name string) string
+
+	// ExecCmd returns the command for running a shell command in a container
+	ExecCmd(cid string, command string) string
+}
+
+// NewContainerRuntime returns the container runtime for a CRI socket
+// (where an empty socket means the default runtime, docker)
+func NewContainerRuntime(criSocket string) ContainerRuntime {
+	criSocket = strings.TrimSpace(criSocket)
+	if len(criSocket) == 0 || strings.Contains(criSocket, "dockershim") || strings.HasSuffix(criSocket, "docker.sock") {
+		return DockerRuntime{}
+	}
+	return CrictlRuntime{Socket: criSocket}
+}
+
+// GetContainer returns the ID of a running Kubernetes container in the "kube-system" namespace
+func GetContainer(ctx context.Context, runtime ContainerRuntime, name string) (string, error) {
+	cmd := runtime.GetContainerCmd(kubeSystemNamespace, name)
+	var buf bytes.Buffer
+	if err := DoSendingExecOutputToWriter(DoExec(cmd), &buf).Apply(ctx); IsError(err) {
+		return "", err
+	}
+
+	// note: there could be more than one container (ie, while restarting),
+	//       so we just return the first one
+	ids := strings.Fields(buf.String())
+	if len(ids) == 0 {
+		return "", ErrContainerNotFound
+	}
+
+	Debug("GetContainer(%s) with %s: %q", name, runtime.Name(), ids[0])
+	return ids[0], nil
+}
+
+// DoContainerExec runs a command in a Kubernetes container
+func DoContainerExec(runtime ContainerRuntime, name string, command string) Action {
+	return ActionFunc(func(ctx context.Context) Action {
+		cid, err := GetContainer(ctx, runtime, name)
+		if err != nil {
+			return ActionError(fmt.Sprintf("could not find the %q container: %s", name, err))
+		}
+
+		execCommand := runtime.ExecCmd(cid, command)
+
+		Debug("Running command in container %q: '%s'", cid, execCommand)
+		return DoExec(execCommand)
+	})
+}
+
+// CheckContainerRunning checks if a Kubernetes container is running
+func CheckContainerRunning(runtime ContainerRuntime, name string) CheckerFunc {
+	return CheckerFunc(func(ctx context.Context) (bool, error) {
+		cid, err := GetContainer(ctx, runtime, name)
+		if err != nil {
+			return false, nil
+		}
+		if cid == "" {
+			return false, nil
+		}
+		return true, nil
+	})
+}
+
+// DoPrintContainerRuntimeStatus prints the status of the container runtime service
+func DoPrintContainerRuntimeStatus(runtime ContainerRuntime) Action {
+	service := runtime.Service()
+	if len(service) == 0 {
+		return DoMessageWarn("- unknown service for the %s runtime", runtime.Name())
+	}
+	return ActionList{
+		DoMessageWarn("- %s logs:", runtime.Name()),
+		DoExec(fmt.Sprintf("systemctl --no-pager -l status %s", service)),
+	}
+}
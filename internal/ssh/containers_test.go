@@ -0,0 +1,71 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssh
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNewContainerRuntime(t *testing.T) {
+	cases := map[string]string{
+		"":                                    "docker",
+		"/var/run/dockershim.sock":            "docker",
+		"/var/run/crio/crio.sock":             "crio",
+		"/var/run/containerd/containerd.sock": "containerd",
+	}
+	for socket, expected := range cases {
+		if name := NewContainerRuntime(socket).Name(); name != expected {
+			t.Fatalf("Error: unexpected runtime for %q: %q", socket, name)
+		}
+	}
+
+	runtime := NewContainerRuntime("/var/run/containerd/containerd.sock")
+	cmd := runtime.GetContainerCmd("kube-system", "etcd")
+	if !strings.HasPrefix(cmd, "crictl --runtime-endpoint unix:///var/run/containerd/containerd.sock ps") {
+		t.Fatalf("Error: unexpected command: %q", cmd)
+	}
+	if !strings.Contains(cmd, "io.kubernetes.container.name=etcd") {
+		t.Fatalf("Error: container name not used in command: %q", cmd)
+	}
+
+	// we cannot use a TTY in the remote session
+	for _, runtime := range []ContainerRuntime{DockerRuntime{}, CrictlRuntime{Socket: "/var/run/crio/crio.sock"}} {
+		cmd := runtime.ExecCmd("1234", "etcdctl member list")
+		if strings.Contains(cmd, "-t") {
+			t.Fatalf("Error: TTY used in command: %q", cmd)
+		}
+	}
+}
+
+func TestGetContainer(t *testing.T) {
+	responses := []string{
+		"  4a1b2c3d4e5f\r\n9f8e7d6c5b4a\n",
+	}
+
+	ctx := NewTestingContextWithResponses(responses)
+	cid, err := GetContainer(ctx, DockerRuntime{}, "etcd")
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	if cid != "4a1b2c3d4e5f" {
+		t.Fatalf("Error: unexpected container ID: %q", cid)
+	}
+
+	ctx = NewTestingContextWithResponses([]string{})
+	if _, err := GetContainer(ctx, DockerRuntime{}, "etcd"); err != ErrContainerNotFound {
+		t.Fatalf("Error: unexpected error: %v", err)
+	}
+}
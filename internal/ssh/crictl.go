@@ -0,0 +1,62 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssh
+
+import (
+	"fmt"
+	"strings"
+)
+
+// CrictlRuntime is a CRI runtime (ie, containerd or cri-o) managed with `crictl`
+type CrictlRuntime struct {
+	// Socket is the CRI socket
+	Socket string
+}
+
+func (r CrictlRuntime) Name() string {
+	switch {
+	case strings.Contains(r.Socket, "crio"):
+		return "crio"
+	case strings.Contains(r.Socket, "containerd"):
+		return "containerd"
+	}
+	return "cri"
+}
+
+func (r CrictlRuntime) Service() string {
+	switch name := r.Name(); name {
+	case "crio", "containerd":
+		return name
+	}
+	return ""
+}
+
+func (r CrictlRuntime) GetContainerCmd(namespace string, name string) string {
+	return fmt.Sprintf("%s ps --state running --label '%s=%s' --label '%s=%s' -q",
+		r.crictl(), labelContainerName, name, labelPodNamespace, namespace)
+}
+
+func (r CrictlRuntime) ExecCmd(cid string, command string) string {
+	return fmt.Sprintf("%s exec '%s' /bin/sh -c '%s'", r.crictl(), cid, command)
+}
+
+// crictl returns the `crictl` command for talking to the runtime
+func (r CrictlRuntime) crictl() string {
+	endpoint := r.Socket
+	if !strings.Contains(endpoint, "://") {
+		endpoint = "unix://" + endpoint
+	}
+	return fmt.Sprintf("crictl --runtime-endpoint %s", endpoint)
+}
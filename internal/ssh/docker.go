@@ -15,70 +15,26 @@
 package ssh
 
 import (
-	"bytes"
-	"context"
-	"errors"
 	"fmt"
-	"strings"
 )
 
-const (
-	// docker command for getting a container id
-	dockerGetContainer = "docker ps --filter name=^/%s -q"
-)
-
-var (
-	// ErrContainerNotFound is the container has not been found
-	ErrContainerNotFound = errors.New("container not found")
-)
-
-// GetContainer returns the ID of a container
-func GetContainer(ctx context.Context, pattern string) (string, error) {
-
-	cmd := fmt.Sprintf(dockerGetContainer, pattern)
-	var buf bytes.Buffer
-	if err := DoSendingExecOutputToWriter(DoExec(cmd), &buf).Apply(ctx); IsError(err) {
-		return "", err
-	}
+// DockerRuntime is the docker container runtime
+type DockerRuntime struct{}
 
-	output := buf.String()
-	if len(output) == 0 {
-		return "", ErrContainerNotFound
-	}
-
-	output = strings.ReplaceAll(output, "\n", "")
-	output = strings.TrimSpace(output)
-
-	Debug("GetContainer(%s) output: %q", pattern, output)
-	return output, nil
+func (DockerRuntime) Name() string {
+	return "docker"
 }
 
-// DoDockerExec runs a `docker exec` command in a container
-func DoDockerExec(pattern string, command string) Action {
-	return ActionFunc(func(ctx context.Context) Action {
-		cid, err := GetContainer(ctx, pattern)
-		if err != nil {
-			return ActionError(err.Error())
-		}
-
-		// build the full `docker exec` command to run
-		dockerCommand := fmt.Sprintf("docker exec -ti '%s' /bin/sh -c '%s'", cid, command)
+func (DockerRuntime) Service() string {
+	return "docker"
+}
 
-		Debug("Running command in container %q: '%s'", cid, dockerCommand)
-		return DoExec(dockerCommand)
-	})
+func (DockerRuntime) GetContainerCmd(namespace string, name string) string {
+	return fmt.Sprintf("docker ps --filter 'label=%s=%s' --filter 'label=%s=%s' -q",
+		labelContainerName, name, labelPodNamespace, namespace)
 }
 
-// CheckContainerRunning checks if we can get the CID for a pattern
-func CheckContainerRunning(pattern string) CheckerFunc {
-	return CheckerFunc(func(ctx context.Context) (bool, error) {
-		cid, err := GetContainer(ctx, pattern)
-		if err != nil {
-			return false, nil
-		}
-		if cid == "" {
-			return false, nil
-		}
-		return true, nil
-	})
+func (DockerRuntime) ExecCmd(cid string, command string) string {
+	// note: do not use "-t", as there is no TTY in the remote session
+	return fmt.Sprintf("docker exec '%s' /bin/sh -c '%s'", cid, command)
 }
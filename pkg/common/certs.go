@@ -15,12 +15,14 @@
 package common
 
 import (
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"path"
+	"strings"
 
 	"github.com/hashicorp/terraform/helper/schema"
 	certutil "k8s.io/client-go/util/cert"
@@ -28,6 +30,8 @@ import (
 	kubeadmapi "k8s.io/kubernetes/cmd/kubeadm/app/apis/kubeadm"
 	kubeadmconstants "k8s.io/kubernetes/cmd/kubeadm/app/constants"
 	"k8s.io/kubernetes/cmd/kubeadm/app/phases/certs"
+	cryptoutil "k8s.io/kubernetes/cmd/kubeadm/app/util/crypto"
+	"k8s.io/kubernetes/cmd/kubeadm/app/util/pubkeypin"
 
 	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
 )
@@ -182,6 +186,29 @@ func (c *CertsConfig) FromDisk(certsDir string) error {
 	return nil
 }
 
+// CACertHash returns the hash of the public key of the CA certificate
+// (ie, "sha256:..."), as used for pinning the CA when joining the cluster
+func CACertHash(caCrtPEM []byte) (string, error) {
+	caCerts, err := certutil.ParseCertsPEM(caCrtPEM)
+	if err != nil {
+		return "", fmt.Errorf("could not parse CA certificate: %s", err)
+	}
+	return pubkeypin.Hash(caCerts[0]), nil
+}
+
+// CreateEtcdClientCert creates the client certificate used by the API server for
+// accessing the etcd members, signed by the etcd CA in the `certsConfig`.
+// It returns the certificate and the private key, both PEM-encoded.
+func CreateEtcdClientCert(certsConfig *CertsConfig) ([]byte, []byte, error) {
+	if len(certsConfig.EtcdCrt) == 0 || len(certsConfig.EtcdKey) == 0 {
+		return nil, nil, ErrNoCA
+	}
+	return CreateClientCert([]byte(certsConfig.EtcdCrt), []byte(certsConfig.EtcdKey),
+		kubeadmconstants.APIServerEtcdClientCertCommonName,
+		[]string{kubeadmconstants.SystemPrivilegedGroup},
+		DefEtcdClientCertValidity)
+}
+
 ///////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
 
 // CreateCerts creates the certificates in some temporary directory,
@@ -273,3 +300,45 @@ func CreateCerts(d *schema.ResourceData, initCfg *kubeadmapi.InitConfiguration)
 
 	return m, nil
 }
+
+// GetRandomCertificateKey generates a new key for encrypting the certificates
+// uploaded to the cluster with `kubeadm init phase upload-certs`
+func GetRandomCertificateKey() (string, error) {
+	return randBytes(kubeadmconstants.CertificateKeySize)
+}
+
+// KubeadmCertsSecretData returns the data for the Secret where `kubeadm init phase upload-certs`
+// stores the certificates, encrypted with the (hex-encoded) certificate key, so they can be
+// downloaded in a `kubeadm join --certificate-key`.
+// The `extra` certificates (ie, for accessing an external etcd) are added with their names.
+func (c *CertsConfig) KubeadmCertsSecretData(key string, extra map[string][]byte) (map[string][]byte, error) {
+	decodedKey, err := hex.DecodeString(key)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode the certificate key: %s", err)
+	}
+
+	certs := map[string][]byte{}
+	for name, cert := range c.DistributionMap() {
+		certs[name] = []byte(*cert)
+	}
+	for name, cert := range extra {
+		certs[name] = cert
+	}
+
+	data := map[string][]byte{}
+	for name, cert := range certs {
+		// note: kubeadm uses "-" instead of "/" (ie, "etcd/ca.crt" is stored as "etcd-ca.crt")
+		secretName := strings.Replace(name, "/", "-", -1)
+		if len(cert) == 0 {
+			// kubeadm stores empty entries for the certificates that are not present
+			data[secretName] = []byte{}
+			continue
+		}
+		encrypted, err := cryptoutil.EncryptBytes(cert, decodedKey)
+		if err != nil {
+			return nil, fmt.Errorf("could not encrypt %q: %s", name, err)
+		}
+		data[secretName] = encrypted
+	}
+	return data, nil
+}
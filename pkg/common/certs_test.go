@@ -15,10 +15,16 @@
 package common
 
 import (
+	"encoding/hex"
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/davecgh/go-spew/spew"
+	certutil "k8s.io/client-go/util/cert"
+	cryptoutil "k8s.io/kubernetes/cmd/kubeadm/app/util/crypto"
+	"k8s.io/kubernetes/cmd/kubeadm/app/util/pkiutil"
+	"k8s.io/kubernetes/cmd/kubeadm/app/util/pubkeypin"
 )
 
 func TestCertsSerialization(t *testing.T) {
@@ -63,3 +69,87 @@ func TestCertsSerialization(t *testing.T) {
 		t.Fatalf("Error: etcd_crt does not match")
 	}
 }
+
+func TestCACertHash(t *testing.T) {
+	caCert, _, err := pkiutil.NewCertificateAuthority(&certutil.Config{CommonName: "kubernetes"})
+	if err != nil {
+		t.Fatalf("Error: could not create CA: %s", err)
+	}
+
+	hash, err := CACertHash(pkiutil.EncodeCertPEM(caCert))
+	if err != nil {
+		t.Fatalf("Error: could not get CA hash: %s", err)
+	}
+	if !strings.HasPrefix(hash, "sha256:") {
+		t.Fatalf("Error: unexpected hash format: %q", hash)
+	}
+
+	// the hash must be accepted by kubeadm for pinning
+	pinner := pubkeypin.NewSet()
+	if err := pinner.Allow(hash); err != nil {
+		t.Fatalf("Error: hash not accepted for pinning: %s", err)
+	}
+	if err := pinner.Check(caCert); err != nil {
+		t.Fatalf("Error: CA does not match the hash: %s", err)
+	}
+
+	if _, err := CACertHash([]byte("some garbage")); err == nil {
+		t.Fatalf("Error: no error with an invalid certificate")
+	}
+}
+
+func TestKubeadmCertsSecretData(t *testing.T) {
+	key, err := GetRandomCertificateKey()
+	if err != nil {
+		t.Fatalf("Error: could not create certificate key: %s", err)
+	}
+	decodedKey, err := hex.DecodeString(key)
+	if err != nil || len(decodedKey) != 32 {
+		t.Fatalf("Error: invalid certificate key %q", key)
+	}
+
+	certsConfig := CertsConfig{
+		CaCrt:   "-- BEGIN PUBLIC KEY ---\n SOME-CERT ...",
+		CaKey:   "-- BEGIN PRIVATE KEY ---\n SOME-KEY ...",
+		EtcdCrt: "-- BEGIN PUBLIC KEY ---\n SOME-ETCD-CERT ...",
+	}
+	extra := map[string][]byte{
+		"external-etcd.key": []byte("-- BEGIN PRIVATE KEY ---\n SOME-EXTERNAL-KEY ..."),
+	}
+
+	data, err := certsConfig.KubeadmCertsSecretData(key, extra)
+	if err != nil {
+		t.Fatalf("Error: could not get the Secret data: %s", err)
+	}
+
+	expected := map[string]string{
+		"ca.crt":            certsConfig.CaCrt,
+		"ca.key":            certsConfig.CaKey,
+		"etcd-ca.crt":       certsConfig.EtcdCrt,
+		"external-etcd.key": string(extra["external-etcd.key"]),
+		"sa.key":            "",
+	}
+	for name, contents := range expected {
+		encrypted, ok := data[name]
+		if !ok {
+			t.Fatalf("Error: %q not found in Secret data", name)
+		}
+		if len(contents) == 0 {
+			if len(encrypted) > 0 {
+				t.Fatalf("Error: %q should be empty", name)
+			}
+			continue
+		}
+		decrypted, err := cryptoutil.DecryptBytes(encrypted, decodedKey)
+		if err != nil {
+			t.Fatalf("Error: could not decrypt %q: %s", name, err)
+		}
+		if string(decrypted) != contents {
+			t.Fatalf("Error: wrong contents for %q: %q", name, decrypted)
+		}
+	}
+
+	if _, err := certsConfig.KubeadmCertsSecretData("not-an-hex-key", nil); err == nil {
+		t.Fatalf("Error: no error with an invalid certificate key")
+	}
+}
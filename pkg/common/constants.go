@@ -15,6 +15,8 @@
 package common
 
 import (
+	"time"
+
 	"github.com/inercia/terraform-provider-kubeadm/internal/assets"
 	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
 )
@@ -37,6 +39,8 @@ const (
 
 	DefKubeadmJoinConfPath = "/etc/kubernetes/kubeadm-join.conf"
 
+	DefKubeadmEtcdConfPath = "/etc/kubernetes/kubeadm-etcd.conf"
+
 	DefCniConfDir = "/etc/cni/net.d"
 
 	DefCniLookbackConfPath = "/etc/cni/net.d/99-loopback.conf"
@@ -47,6 +51,37 @@ const (
 
 	DefFlannelImageVersion = "v0.11.0"
 
+	DefCalicoMode = "ipip"
+
+	// MTU for Calico with IP-in-IP (the default MTU minus the 20 bytes of the IP-in-IP header)
+	DefCalicoIPIPMTU = 1440
+
+	// MTU for Calico with VXLAN (the default MTU minus the 50 bytes of the VXLAN header)
+	DefCalicoVXLANMTU = 1410
+
+	DefCalicoIPAutodetectionMethod = "first-found"
+
+	DefCalicoImageVersion = "v3.9.1"
+
+	DefCiliumImageVersion = "v1.7.5"
+
+	// Minimum Cilium version for replacing kube-proxy (ie, for the `kube-proxy-replacement` option)
+	MinCiliumKubeProxyReplacementVersion = "v1.7.0"
+
+	// Default mode for distributing the certificates to the masters joining the cluster
+	DefCertDistribution = "ssh"
+
+	// Minimum Kubernetes version for distributing the certificates with kubeadm (ie, for
+	// the `--upload-certs` flag)
+	MinKubeadmCertDistributionVersion = "v1.15.0"
+
+	DefKubeVipImageVersion = "v0.3.8"
+
+	DefKubeVipInterface = "eth0"
+
+	// Full path for the kube-vip static pod manifest in the masters
+	DefKubeVipManifestPath = "/etc/kubernetes/manifests/kube-vip.yaml"
+
 	// Full path where we should upload the kubelet sysconfig file
 	DefKubeletSysconfigPath = "/etc/sysconfig/kubelet"
 
@@ -56,6 +91,9 @@ const (
 	// Full path where we should upload the kubeadm dropin file
 	DefKubeadmDropinPath = "/usr/lib/systemd/system/kubelet.service.d/10-kubeadm.conf"
 
+	// Name of the kubelet dropin used in etcd members (in the same directory as the kubeadm dropin)
+	DefKubeletEtcdDropinName = "20-etcd-service-manager.conf"
+
 	// Default PKI dir
 	DefPKIDir = "/etc/kubernetes/pki"
 
@@ -72,6 +110,72 @@ const (
 
 	// resolv.conf for pods when upstream servers are provided
 	DefResolvUpstreamConf = "/etc/resolv.conf-kubeadm"
+
+	// name of the cluster in the kubeconfig files
+	DefClusterName = "kubernetes"
+
+	// user (ie, the CN in the client certificate) in the admin kubeconfig
+	DefAdminUser = "kubernetes-admin"
+
+	// validity for the client certificate in the admin kubeconfig
+	DefAdminCertValidity = 365 * 24 * time.Hour
+
+	// the client certificate in the admin kubeconfig is regenerated when it expires in less than this
+	DefAdminCertRenewBefore = 30 * 24 * time.Hour
+
+	// time to live for the bootstrap tokens
+	DefTokenTTL = 24 * time.Hour
+
+	// validity for the client certificate used by the API server for accessing the etcd members
+	DefEtcdClientCertValidity = 365 * 24 * time.Hour
+
+	// encryption provider used for encrypting Secrets at rest
+	DefEncryptionProvider = "aescbc"
+
+	// directory (mounted in the API server) for the encryption configuration
+	DefEncryptionConfigDir = "/etc/kubernetes/encryption"
+
+	// full path where we should upload the encryption configuration
+	DefEncryptionConfigPath = DefEncryptionConfigDir + "/config.yaml"
+
+	// directory (mounted in the API server) for the audit policy and webhook configuration
+	DefAuditConfigDir = "/etc/kubernetes/audit"
+
+	// full path where we should upload the audit policy
+	DefAuditPolicyPath = DefAuditConfigDir + "/policy.yaml"
+
+	// full path where we should upload the audit webhook configuration
+	DefAuditWebhookConfigPath = DefAuditConfigDir + "/webhook.yaml"
+
+	// audit log in the control plane machines
+	DefAuditLogPath = "/var/log/kubernetes/audit/audit.log"
+
+	// maximum number of days to retain old audit log files
+	DefAuditLogMaxAge = 30
+
+	// maximum size (in megabytes) of the audit log file before it gets rotated
+	DefAuditLogMaxSize = 100
+
+	// maximum number of old audit log files to retain
+	DefAuditLogMaxBackup = 10
+
+	// directory (in the PKI dir) for the certificates used for accessing an external etcd cluster
+	DefExternalEtcdCertsDir = DefPKIDir + "/etcd-external"
+
+	// full path where we should upload the CA certificate of the external etcd cluster
+	DefExternalEtcdCACrtPath = DefExternalEtcdCertsDir + "/ca.crt"
+
+	// full path where we should upload the client certificate for the external etcd cluster
+	DefExternalEtcdClientCrtPath = DefExternalEtcdCertsDir + "/client.crt"
+
+	// full path where we should upload the client key for the external etcd cluster
+	DefExternalEtcdClientKeyPath = DefExternalEtcdCertsDir + "/client.key"
+
+	// Pod Security Policy granted by default to all the users and service accounts
+	DefPSPDefaultPolicy = "restricted"
+
+	// admission plugins enabled by kubeadm by default
+	DefAdmissionPlugins = "NodeRestriction"
 )
 
 var (
@@ -79,6 +183,8 @@ var (
 	CNIPluginsManifestsTemplates = map[string]ssh.Manifest{
 		"flannel": {Inline: assets.FlannelManifestCode},
 		"weave":   {Inline: assets.WeaveManifestCode},
+		"calico":  {Inline: assets.CalicoManifestCode},
+		"cilium":  {Inline: assets.CiliumManifestCode},
 	}
 
 	// CNIPluginsList gets the list of supported CNI plugins (will be filled by the init())
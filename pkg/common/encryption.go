@@ -0,0 +1,97 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+
+	"github.com/ghodss/yaml"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	apiserverconfigv1 "k8s.io/apiserver/pkg/apis/config/v1"
+)
+
+const (
+	// size (in bytes) of the keys used for encrypting data at rest
+	encryptionKeySize = 32
+
+	// name of the key in the encryption configuration
+	encryptionKeyName = "key1"
+)
+
+// EncryptionProviders is the list of supported encryption providers
+var EncryptionProviders = []string{"aescbc", "secretbox"}
+
+// NewEncryptionKey generates a new random key for encrypting data at rest (base64 encoded)
+func NewEncryptionKey() (string, error) {
+	key := make([]byte, encryptionKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+// ValidateEncryptionKey validates an encryption key (a base64 encoded, 32 bytes key)
+func ValidateEncryptionKey(v interface{}, k string) (ws []string, errors []error) {
+	key, err := base64.StdEncoding.DecodeString(v.(string))
+	if err != nil {
+		errors = append(errors, fmt.Errorf("%q is not base64 encoded: %s", k, err))
+		return
+	}
+	if len(key) != encryptionKeySize {
+		errors = append(errors, fmt.Errorf("%q must be a %d bytes key (got %d bytes)", k, encryptionKeySize, len(key)))
+	}
+	return
+}
+
+// CreateEncryptionConfig creates an `EncryptionConfiguration` for encrypting Secrets
+// at rest with some `provider` (aescbc or secretbox) and a `key`. The `identity`
+// provider is added at the end, so Secrets that were not encrypted can still be read.
+func CreateEncryptionConfig(provider string, key string) ([]byte, error) {
+	if _, errs := ValidateEncryptionKey(key, "key"); len(errs) > 0 {
+		return nil, errs[0]
+	}
+
+	keys := []apiserverconfigv1.Key{{Name: encryptionKeyName, Secret: key}}
+
+	providerConfig := apiserverconfigv1.ProviderConfiguration{}
+	switch provider {
+	case "aescbc":
+		providerConfig.AESCBC = &apiserverconfigv1.AESConfiguration{Keys: keys}
+	case "secretbox":
+		providerConfig.Secretbox = &apiserverconfigv1.SecretboxConfiguration{Keys: keys}
+	default:
+		return nil, fmt.Errorf("unknown encryption provider %q", provider)
+	}
+
+	config := apiserverconfigv1.EncryptionConfiguration{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: apiserverconfigv1.SchemeGroupVersion.String(),
+			Kind:       "EncryptionConfiguration",
+		},
+		Resources: []apiserverconfigv1.ResourceConfiguration{
+			{
+				Resources: []string{"secrets"},
+				Providers: []apiserverconfigv1.ProviderConfiguration{
+					providerConfig,
+					{Identity: &apiserverconfigv1.IdentityConfiguration{}},
+				},
+			},
+		},
+	}
+
+	return yaml.Marshal(config)
+}
@@ -0,0 +1,52 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestCreateEncryptionConfig(t *testing.T) {
+	key, err := NewEncryptionKey()
+	if err != nil {
+		t.Fatalf("Error: could not create key: %s", err)
+	}
+	if _, errs := ValidateEncryptionKey(key, "key"); len(errs) > 0 {
+		t.Fatalf("Error: invalid key generated: %s", errs)
+	}
+
+	for _, provider := range EncryptionProviders {
+		config, err := CreateEncryptionConfig(provider, key)
+		if err != nil {
+			t.Fatalf("Error: could not create encryption config: %s", err)
+		}
+		fmt.Printf("----------------- encryption configuration ---------------- \n%s", config)
+
+		for _, expected := range []string{"kind: EncryptionConfiguration", provider + ":", "identity: {}", key} {
+			if !strings.Contains(string(config), expected) {
+				t.Fatalf("Error: %q not found in encryption config", expected)
+			}
+		}
+	}
+
+	if _, err := CreateEncryptionConfig("aescbc", "c29tZSBrZXk="); err == nil {
+		t.Fatalf("Error: a short key should not be accepted")
+	}
+	if _, err := CreateEncryptionConfig("something", key); err == nil {
+		t.Fatalf("Error: an unknown provider should not be accepted")
+	}
+}
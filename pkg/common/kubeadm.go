@@ -19,9 +19,11 @@ import (
 	"fmt"
 
 	"github.com/hashicorp/terraform/helper/schema"
+	"k8s.io/apimachinery/pkg/runtime"
 	kubeadmapi "k8s.io/kubernetes/cmd/kubeadm/app/apis/kubeadm"
 	kubeadmscheme "k8s.io/kubernetes/cmd/kubeadm/app/apis/kubeadm/scheme"
 	kubeadmapiv1beta1 "k8s.io/kubernetes/cmd/kubeadm/app/apis/kubeadm/v1beta1"
+	"k8s.io/kubernetes/cmd/kubeadm/app/componentconfigs"
 	kubeadmutil "k8s.io/kubernetes/cmd/kubeadm/app/util"
 	"k8s.io/kubernetes/cmd/kubeadm/app/util/config"
 )
@@ -49,6 +51,7 @@ var (
 func YAMLToInitConfig(configBytes []byte) (*kubeadmapi.InitConfiguration, error) {
 	var initConfig *kubeadmapi.InitConfiguration
 	var clusterConfig *kubeadmapi.ClusterConfiguration
+	componentConfigs := map[componentconfigs.RegistrationKind]runtime.Object{}
 
 	objects, err := kubeadmutil.SplitYAMLDocuments(configBytes)
 	if err != nil {
@@ -79,6 +82,13 @@ func YAMLToInitConfig(configBytes []byte) (*kubeadmapi.InitConfiguration, error)
 			}
 
 			clusterConfig = cfg2
+		} else if registration, found := componentconfigs.Known[componentconfigs.RegistrationKind(k.Kind)]; found {
+			// the component configs (kubelet, kube-proxy...) are in separate documents
+			obj, err := registration.Unmarshal(v)
+			if err != nil {
+				return nil, err
+			}
+			componentConfigs[componentconfigs.RegistrationKind(k.Kind)] = obj
 		}
 	}
 
@@ -86,6 +96,14 @@ func YAMLToInitConfig(configBytes []byte) (*kubeadmapi.InitConfiguration, error)
 		initConfig.ClusterConfiguration = *clusterConfig
 	}
 
+	if initConfig != nil {
+		for kind, obj := range componentConfigs {
+			if ok := componentconfigs.Known[kind].SetToInternalConfig(obj, &initConfig.ClusterConfiguration); !ok {
+				return nil, fmt.Errorf("could not set the %s in the init configuration", kind)
+			}
+		}
+	}
+
 	return initConfig, nil
 }
 
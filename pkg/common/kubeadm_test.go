@@ -16,6 +16,7 @@ package common
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 )
 
@@ -80,6 +81,58 @@ networking:
 	}
 }
 
+func TestInitConfigComponentsSerialization(t *testing.T) {
+	configContents := `
+apiVersion: kubeadm.k8s.io/v1beta1
+kind: InitConfiguration
+---
+apiVersion: kubeadm.k8s.io/v1beta1
+kind: ClusterConfiguration
+kubernetesVersion: v1.14.1
+---
+apiVersion: kubelet.config.k8s.io/v1beta1
+kind: KubeletConfiguration
+cgroupDriver: systemd
+maxPods: 50
+---
+apiVersion: kubeproxy.config.k8s.io/v1alpha1
+kind: KubeProxyConfiguration
+mode: ipvs
+`
+
+	initConfig, err := YAMLToInitConfig([]byte(configContents))
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+
+	kubeletConfig := initConfig.ComponentConfigs.Kubelet
+	if kubeletConfig == nil {
+		t.Fatalf("Error: no kubelet configuration found")
+	}
+	if kubeletConfig.CgroupDriver != "systemd" || kubeletConfig.MaxPods != 50 {
+		t.Fatalf("Error: wrong kubelet configuration: %+v", kubeletConfig)
+	}
+	kubeProxyConfig := initConfig.ComponentConfigs.KubeProxy
+	if kubeProxyConfig == nil {
+		t.Fatalf("Error: no kube-proxy configuration found")
+	}
+	if kubeProxyConfig.Mode != "ipvs" {
+		t.Fatalf("Error: wrong kube-proxy mode: %q", kubeProxyConfig.Mode)
+	}
+
+	configContentsAgain, err := InitConfigToYAML(initConfig)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	fmt.Printf("----------------- init configuration ---------------- \n%s", configContentsAgain)
+
+	for _, kind := range []string{"kind: KubeletConfiguration", "kind: KubeProxyConfiguration"} {
+		if !strings.Contains(string(configContentsAgain), kind) {
+			t.Fatalf("Error: %q not found in serialized contents", kind)
+		}
+	}
+}
+
 func TestJoinConfigSerialization(t *testing.T) {
 	configContents := `
 apiVersion: kubeadm.k8s.io/v1beta1
@@ -0,0 +1,160 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"time"
+
+	"k8s.io/client-go/tools/clientcmd"
+	certutil "k8s.io/client-go/util/cert"
+	"k8s.io/client-go/util/keyutil"
+	kubeadmconstants "k8s.io/kubernetes/cmd/kubeadm/app/constants"
+	kubeconfigutil "k8s.io/kubernetes/cmd/kubeadm/app/util/kubeconfig"
+	"k8s.io/kubernetes/cmd/kubeadm/app/util/pkiutil"
+)
+
+var (
+	ErrNoCA = errors.New("no CA certificate/key available")
+)
+
+// AdminCredentials are the credentials for accessing the cluster with administrative privileges
+type AdminCredentials struct {
+	Server     string
+	CaCrt      string
+	ClientCrt  string
+	ClientKey  string
+	Kubeconfig string
+}
+
+// AdminCredentialsOptions are the options for the admin client certificate
+type AdminCredentialsOptions struct {
+	User     string
+	Groups   []string
+	Validity time.Duration
+}
+
+// NewAdminCredentialsOptions returns the default options for the admin credentials:
+// a "kubernetes-admin" user in the "system:masters" group, valid for a year
+func NewAdminCredentialsOptions() AdminCredentialsOptions {
+	return AdminCredentialsOptions{
+		User:     DefAdminUser,
+		Groups:   []string{kubeadmconstants.SystemPrivilegedGroup},
+		Validity: DefAdminCertValidity,
+	}
+}
+
+// CreateAdminCredentials creates a client certificate (signed by the CA in the `certsConfig`)
+// and a kubeconfig for accessing the API server at `server` with administrative privileges
+func CreateAdminCredentials(certsConfig *CertsConfig, server string, opts AdminCredentialsOptions) (*AdminCredentials, error) {
+	if len(certsConfig.CaCrt) == 0 || len(certsConfig.CaKey) == 0 {
+		return nil, ErrNoCA
+	}
+
+	clientCrt, clientKey, err := CreateClientCert([]byte(certsConfig.CaCrt), []byte(certsConfig.CaKey),
+		opts.User, opts.Groups, opts.Validity)
+	if err != nil {
+		return nil, err
+	}
+
+	config := kubeconfigutil.CreateWithCerts(server, DefClusterName, opts.User,
+		[]byte(certsConfig.CaCrt), clientKey, clientCrt)
+	kubeconfig, err := clientcmd.Write(*config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AdminCredentials{
+		Server:     server,
+		CaCrt:      certsConfig.CaCrt,
+		ClientCrt:  string(clientCrt),
+		ClientKey:  string(clientKey),
+		Kubeconfig: string(kubeconfig),
+	}, nil
+}
+
+// CreateClientCert creates a new client certificate for `user` (in some `groups`), signed by a CA.
+// It returns the certificate and the private key, both PEM-encoded.
+func CreateClientCert(caCrtPEM []byte, caKeyPEM []byte, user string, groups []string, validity time.Duration) ([]byte, []byte, error) {
+	caCerts, err := certutil.ParseCertsPEM(caCrtPEM)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not parse CA certificate: %s", err)
+	}
+	caCert := caCerts[0]
+
+	caKeyRaw, err := keyutil.ParsePrivateKeyPEM(caKeyPEM)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not parse CA key: %s", err)
+	}
+	caKey, ok := caKeyRaw.(crypto.Signer)
+	if !ok {
+		return nil, nil, fmt.Errorf("CA key is not a valid signer")
+	}
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).SetInt64(math.MaxInt64))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	certTmpl := x509.Certificate{
+		Subject: pkix.Name{
+			CommonName:   user,
+			Organization: groups,
+		},
+		SerialNumber: serial,
+		NotBefore:    caCert.NotBefore,
+		NotAfter:     time.Now().Add(validity).UTC(),
+		KeyUsage:     x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, &certTmpl, caCert, key.Public(), caKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	cert, err := x509.ParseCertificate(certDER)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	keyPEM, err := keyutil.MarshalPrivateKeyToPEM(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return pkiutil.EncodeCertPEM(cert), keyPEM, nil
+}
+
+// CertExpiresWithin returns true if the (first) certificate in `crtPEM`
+// expires in less than `period`
+func CertExpiresWithin(crtPEM []byte, period time.Duration) (bool, error) {
+	certs, err := certutil.ParseCertsPEM(crtPEM)
+	if err != nil {
+		return false, fmt.Errorf("could not parse certificate: %s", err)
+	}
+	return time.Now().Add(period).After(certs[0].NotAfter), nil
+}
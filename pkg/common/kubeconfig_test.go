@@ -0,0 +1,113 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"crypto/x509"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/tools/clientcmd"
+	certutil "k8s.io/client-go/util/cert"
+	"k8s.io/client-go/util/keyutil"
+	"k8s.io/kubernetes/cmd/kubeadm/app/util/pkiutil"
+)
+
+func TestCreateAdminCredentials(t *testing.T) {
+	caCert, caKey, err := pkiutil.NewCertificateAuthority(&certutil.Config{CommonName: "kubernetes"})
+	if err != nil {
+		t.Fatalf("Error: could not create CA: %s", err)
+	}
+	caKeyPEM, err := keyutil.MarshalPrivateKeyToPEM(caKey)
+	if err != nil {
+		t.Fatalf("Error: could not encode CA key: %s", err)
+	}
+
+	certsConfig := CertsConfig{
+		CaCrt: string(pkiutil.EncodeCertPEM(caCert)),
+		CaKey: string(caKeyPEM),
+	}
+
+	server := "https://some.place:6443"
+	creds, err := CreateAdminCredentials(&certsConfig, server, NewAdminCredentialsOptions())
+	if err != nil {
+		t.Fatalf("Error: could not create admin credentials: %s", err)
+	}
+
+	// check the client certificate has been signed by the CA
+	certs, err := certutil.ParseCertsPEM([]byte(creds.ClientCrt))
+	if err != nil {
+		t.Fatalf("Error: could not parse client certificate: %s", err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(caCert)
+	verifyOpts := x509.VerifyOptions{Roots: roots, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}}
+	if _, err := certs[0].Verify(verifyOpts); err != nil {
+		t.Fatalf("Error: client certificate not signed by the CA: %s", err)
+	}
+	if len(certs[0].Subject.Organization) != 1 || certs[0].Subject.Organization[0] != "system:masters" {
+		t.Fatalf("Error: unexpected groups in client certificate: %+v", certs[0].Subject.Organization)
+	}
+
+	// check the kubeconfig points to the right server
+	config, err := clientcmd.Load([]byte(creds.Kubeconfig))
+	if err != nil {
+		t.Fatalf("Error: could not parse kubeconfig: %s", err)
+	}
+	cluster, ok := config.Clusters[DefClusterName]
+	if !ok {
+		t.Fatalf("Error: no cluster %q in kubeconfig", DefClusterName)
+	}
+	if cluster.Server != server {
+		t.Fatalf("Error: unexpected server in kubeconfig: %q != %q", cluster.Server, server)
+	}
+
+	// the certificate must be renewed only when it is close to its expiration
+	if renew, err := CertExpiresWithin([]byte(creds.ClientCrt), DefAdminCertRenewBefore); err != nil || renew {
+		t.Fatalf("Error: a new client certificate must not be renewed: %t, %v", renew, err)
+	}
+	if renew, err := CertExpiresWithin([]byte(creds.ClientCrt), DefAdminCertValidity+time.Hour); err != nil || !renew {
+		t.Fatalf("Error: the client certificate must be renewed before its expiration: %t, %v", renew, err)
+	}
+
+	// it should fail when we have no CA
+	if _, err := CreateAdminCredentials(&CertsConfig{}, server, NewAdminCredentialsOptions()); err == nil {
+		t.Fatalf("Error: admin credentials created without a CA")
+	}
+
+	// check we can use a custom user/group and validity
+	opts := AdminCredentialsOptions{
+		User:     "someone",
+		Groups:   []string{"some-group"},
+		Validity: 2 * time.Hour,
+	}
+	creds, err = CreateAdminCredentials(&certsConfig, server, opts)
+	if err != nil {
+		t.Fatalf("Error: could not create admin credentials: %s", err)
+	}
+	certs, err = certutil.ParseCertsPEM([]byte(creds.ClientCrt))
+	if err != nil {
+		t.Fatalf("Error: could not parse client certificate: %s", err)
+	}
+	if certs[0].Subject.CommonName != "someone" {
+		t.Fatalf("Error: unexpected user in client certificate: %q", certs[0].Subject.CommonName)
+	}
+	if len(certs[0].Subject.Organization) != 1 || certs[0].Subject.Organization[0] != "some-group" {
+		t.Fatalf("Error: unexpected groups in client certificate: %+v", certs[0].Subject.Organization)
+	}
+	if certs[0].NotAfter.After(time.Now().Add(3 * time.Hour)) {
+		t.Fatalf("Error: unexpected expiration in client certificate: %s", certs[0].NotAfter)
+	}
+}
@@ -0,0 +1,138 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// ParseTaint parses a taint in the `kubectl taint` format (ie, "key=value:NoSchedule" or "key:NoSchedule")
+func ParseTaint(s string) (corev1.Taint, error) {
+	taint := corev1.Taint{}
+
+	parts := strings.Split(strings.TrimSpace(s), ":")
+	if len(parts) != 2 || len(parts[0]) == 0 {
+		return taint, fmt.Errorf("invalid taint %q: expected 'key=value:effect' or 'key:effect'", s)
+	}
+
+	keyValue := strings.SplitN(parts[0], "=", 2)
+	taint.Key = keyValue[0]
+	if errs := validation.IsQualifiedName(taint.Key); len(errs) > 0 {
+		return taint, fmt.Errorf("invalid key in taint %q: %s", s, strings.Join(errs, "; "))
+	}
+	if len(keyValue) == 2 {
+		taint.Value = keyValue[1]
+		if errs := validation.IsValidLabelValue(taint.Value); len(errs) > 0 {
+			return taint, fmt.Errorf("invalid value in taint %q: %s", s, strings.Join(errs, "; "))
+		}
+	}
+
+	taint.Effect = corev1.TaintEffect(parts[1])
+	switch taint.Effect {
+	case corev1.TaintEffectNoSchedule, corev1.TaintEffectPreferNoSchedule, corev1.TaintEffectNoExecute:
+	default:
+		return taint, fmt.Errorf("invalid effect in taint %q: must be one of %s, %s or %s", s,
+			corev1.TaintEffectNoSchedule, corev1.TaintEffectPreferNoSchedule, corev1.TaintEffectNoExecute)
+	}
+
+	return taint, nil
+}
+
+// TaintToString returns a taint in the `kubectl taint` format
+func TaintToString(taint corev1.Taint) string {
+	if len(taint.Value) == 0 {
+		return fmt.Sprintf("%s:%s", taint.Key, taint.Effect)
+	}
+	return fmt.Sprintf("%s=%s:%s", taint.Key, taint.Value, taint.Effect)
+}
+
+// ValidateTaint validates a taint in the `kubectl taint` format
+func ValidateTaint(v interface{}, k string) (ws []string, errors []error) {
+	if _, err := ParseTaint(v.(string)); err != nil {
+		errors = append(errors, fmt.Errorf("%q: %s", k, err))
+	}
+	return
+}
+
+// ValidateLabels validates a map of node labels
+func ValidateLabels(v interface{}, k string) (ws []string, errors []error) {
+	for key, value := range v.(map[string]interface{}) {
+		if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+			errors = append(errors, fmt.Errorf("%q: invalid label key %q: %s", k, key, strings.Join(errs, "; ")))
+		}
+		if errs := validation.IsValidLabelValue(value.(string)); len(errs) > 0 {
+			errors = append(errors, fmt.Errorf("%q: invalid value for label %q: %s", k, key, strings.Join(errs, "; ")))
+		}
+	}
+	return
+}
+
+// LabelsToString returns some labels in the format used in the kubelet
+// `--node-labels` (ie, "key1=value1,key2=value2"), sorted by key
+func LabelsToString(labels map[string]string) string {
+	res := []string{}
+	for k, v := range labels {
+		res = append(res, fmt.Sprintf("%s=%s", k, v))
+	}
+	sort.Strings(res)
+	return strings.Join(res, ",")
+}
+
+// ParseLabels parses some labels in the format used in the kubelet `--node-labels`
+func ParseLabels(s string) (map[string]string, error) {
+	labels := map[string]string{}
+	for _, label := range strings.Split(s, ",") {
+		label = strings.TrimSpace(label)
+		if len(label) == 0 {
+			continue
+		}
+		keyValue := strings.SplitN(label, "=", 2)
+		if len(keyValue) != 2 || len(keyValue[0]) == 0 {
+			return nil, fmt.Errorf("invalid label %q: expected 'key=value'", label)
+		}
+		labels[keyValue[0]] = keyValue[1]
+	}
+	return labels, nil
+}
+
+// TaintsToString returns some taints as a comma-separated list of taints in the `kubectl taint` format
+func TaintsToString(taints []corev1.Taint) string {
+	res := []string{}
+	for _, taint := range taints {
+		res = append(res, TaintToString(taint))
+	}
+	return strings.Join(res, ",")
+}
+
+// ParseTaints parses a comma-separated list of taints in the `kubectl taint` format
+func ParseTaints(s string) ([]corev1.Taint, error) {
+	taints := []corev1.Taint{}
+	for _, t := range strings.Split(s, ",") {
+		if len(strings.TrimSpace(t)) == 0 {
+			continue
+		}
+		taint, err := ParseTaint(t)
+		if err != nil {
+			return nil, err
+		}
+		taints = append(taints, taint)
+	}
+	return taints, nil
+}
@@ -0,0 +1,107 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestParseTaint(t *testing.T) {
+
+	testsCases := []struct {
+		input       string
+		expected    corev1.Taint
+		expectedErr bool
+	}{
+		{"dedicated=gpu:NoSchedule", corev1.Taint{Key: "dedicated", Value: "gpu", Effect: corev1.TaintEffectNoSchedule}, false},
+		{"node-role.kubernetes.io/master:NoSchedule", corev1.Taint{Key: "node-role.kubernetes.io/master", Effect: corev1.TaintEffectNoSchedule}, false},
+		{"spot=true:PreferNoSchedule", corev1.Taint{Key: "spot", Value: "true", Effect: corev1.TaintEffectPreferNoSchedule}, false},
+		{"dedicated=gpu", corev1.Taint{}, true},
+		{"dedicated=gpu:Something", corev1.Taint{}, true},
+		{":NoSchedule", corev1.Taint{}, true},
+		{"in valid=gpu:NoExecute", corev1.Taint{}, true},
+	}
+
+	for _, testCase := range testsCases {
+		taint, err := ParseTaint(testCase.input)
+		if testCase.expectedErr {
+			if err == nil {
+				t.Fatalf("Error: %q should not be a valid taint", testCase.input)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Error: %q should be a valid taint: %s", testCase.input, err)
+		}
+		if taint != testCase.expected {
+			t.Fatalf("Error: %q parsed as %+v, expected %+v", testCase.input, taint, testCase.expected)
+		}
+		if s := TaintToString(taint); s != testCase.input {
+			t.Fatalf("Error: %q serialized as %q", testCase.input, s)
+		}
+	}
+}
+
+func TestValidateLabels(t *testing.T) {
+	if _, errs := ValidateLabels(map[string]interface{}{"node.example.com/pool": "gpu", "tier": ""}, "labels"); len(errs) > 0 {
+		t.Fatalf("Error: labels should be valid: %s", errs)
+	}
+	if _, errs := ValidateLabels(map[string]interface{}{"in valid": "gpu"}, "labels"); len(errs) == 0 {
+		t.Fatalf("Error: label key should not be valid")
+	}
+	if _, errs := ValidateLabels(map[string]interface{}{"pool": "g p u"}, "labels"); len(errs) == 0 {
+		t.Fatalf("Error: label value should not be valid")
+	}
+}
+
+func TestLabelsAndTaintsStrings(t *testing.T) {
+	labels := map[string]string{"zone": "a", "node.example.com/pool": "gpu"}
+	s := LabelsToString(labels)
+	if s != "node.example.com/pool=gpu,zone=a" {
+		t.Fatalf("Error: wrong labels string: %q", s)
+	}
+	parsed, err := ParseLabels(s)
+	if err != nil {
+		t.Fatalf("Error: could not parse labels %q: %s", s, err)
+	}
+	if !reflect.DeepEqual(parsed, labels) {
+		t.Fatalf("Error: wrong labels parsed: %+v", parsed)
+	}
+	if _, err := ParseLabels("zone"); err == nil {
+		t.Fatalf("Error: invalid labels should fail")
+	}
+
+	taints := []corev1.Taint{
+		{Key: "dedicated", Value: "gpu", Effect: corev1.TaintEffectNoSchedule},
+		{Key: "spot", Effect: corev1.TaintEffectPreferNoSchedule},
+	}
+	s = TaintsToString(taints)
+	if s != "dedicated=gpu:NoSchedule,spot:PreferNoSchedule" {
+		t.Fatalf("Error: wrong taints string: %q", s)
+	}
+	parsedTaints, err := ParseTaints(s)
+	if err != nil {
+		t.Fatalf("Error: could not parse taints %q: %s", s, err)
+	}
+	if !reflect.DeepEqual(parsedTaints, taints) {
+		t.Fatalf("Error: wrong taints parsed: %+v", parsedTaints)
+	}
+	if parsedTaints, err := ParseTaints(""); err != nil || len(parsedTaints) > 0 {
+		t.Fatalf("Error: an empty string should not have any taints: %+v (%v)", parsedTaints, err)
+	}
+}
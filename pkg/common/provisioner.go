@@ -51,7 +51,8 @@ var ProvisionerConfigElements = map[string]*schema.Schema{
 	"kubeconfig": {
 		Type: schema.TypeString,
 		// Computed: true,
-		Optional: true,
+		Optional:  true,
+		Sensitive: true,
 	},
 	"token": {
 		Type: schema.TypeString,
@@ -59,6 +60,62 @@ var ProvisionerConfigElements = map[string]*schema.Schema{
 		Optional:  true,
 		Sensitive: true,
 	},
+	"token_ttl": {
+		Type: schema.TypeString,
+		// Computed: true,
+		Optional: true,
+	},
+	"token_usages": {
+		Type: schema.TypeString,
+		// Computed: true,
+		Optional: true,
+	},
+	"token_groups": {
+		Type: schema.TypeString,
+		// Computed: true,
+		Optional: true,
+	},
+	"token_description": {
+		Type: schema.TypeString,
+		// Computed: true,
+		Optional: true,
+	},
+	"masters_labels": {
+		Type: schema.TypeString,
+		// Computed: true,
+		Optional: true,
+	},
+	"masters_taints": {
+		Type: schema.TypeString,
+		// Computed: true,
+		Optional: true,
+	},
+	"cert_distribution": {
+		Type: schema.TypeString,
+		// Computed: true,
+		Optional: true,
+	},
+	"certificate_key": {
+		Type: schema.TypeString,
+		// Computed: true,
+		Optional:  true,
+		Sensitive: true,
+	},
+	"schedulable_masters": {
+		Type: schema.TypeString,
+		// Computed: true,
+		Optional: true,
+	},
+	"workers_labels": {
+		Type: schema.TypeString,
+		// Computed: true,
+		Optional: true,
+	},
+	"workers_taints": {
+		Type: schema.TypeString,
+		// Computed: true,
+		Optional: true,
+	},
 	"cni_plugin": {
 		Type: schema.TypeString,
 		// Computed: true,
@@ -99,6 +156,102 @@ var ProvisionerConfigElements = map[string]*schema.Schema{
 		Optional:    true,
 		Description: "the flannel image version",
 	},
+	"flannel_iface": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "the interface used by flannel",
+	},
+	"flannel_iface_regex": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "the regular expression for the interface used by flannel",
+	},
+	"flannel_mtu": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "the flannel MTU",
+	},
+	"weave_password": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Sensitive:   true,
+		Description: "the weave password (base64-encoded)",
+	},
+	"weave_ipalloc_range": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "the weave IP allocation range",
+	},
+	"weave_mtu": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "the weave MTU",
+	},
+	"calico_backend": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "the calico networking backend (bird or vxlan)",
+	},
+	"calico_ipip_mode": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "the calico IP-in-IP mode for the default IP pool",
+	},
+	"calico_vxlan_mode": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "the calico VXLAN mode for the default IP pool",
+	},
+	"calico_mtu": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "the calico MTU",
+	},
+	"calico_ip_autodetection": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "the calico IP autodetection method",
+	},
+	"calico_version": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "the calico image version",
+	},
+	"cilium_version": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "the cilium image version",
+	},
+	"cilium_kube_proxy_replacement": {
+		Type:        schema.TypeBool,
+		Optional:    true,
+		Description: "replace kube-proxy with cilium (so kube-proxy is not installed)",
+	},
+	"api_host": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "the host of the control plane endpoint",
+	},
+	"api_port": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "the port of the control plane endpoint",
+	},
+	"api_vip": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "the virtual IP of the control plane (announced by kube-vip)",
+	},
+	"api_vip_interface": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "the network interface where the virtual IP is announced",
+	},
+	"api_vip_version": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "the kube-vip image version",
+	},
 	"helm_enabled": {
 		Type: schema.TypeBool,
 		// Computed: true,
@@ -119,6 +272,37 @@ var ProvisionerConfigElements = map[string]*schema.Schema{
 		// Computed: true,
 		Optional: true,
 	},
+	"etcd_ca_crt": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "the CA certificate of the external etcd cluster",
+	},
+	"etcd_client_crt": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "the client certificate for the external etcd cluster",
+	},
+	"etcd_managed": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "the etcd members are bootstrapped by the provisioner",
+	},
+	"etcd_client_key": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Sensitive:   true,
+		Description: "the client key for the external etcd cluster",
+	},
+	"psp_enabled": {
+		Type:        schema.TypeBool,
+		Optional:    true,
+		Description: "load the Pod Security Policies",
+	},
+	"psp_default_policy": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "the Pod Security Policy granted by default to users and service accounts",
+	},
 	"dashboard_enabled": {
 		Type: schema.TypeBool,
 		// Computed: true,
@@ -129,6 +313,23 @@ var ProvisionerConfigElements = map[string]*schema.Schema{
 		// Computed: true,
 		Optional: true,
 	},
+	"encryption_config": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Sensitive:   true,
+		Description: "the configuration for encrypting Secrets at rest",
+	},
+	"audit_policy": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "the audit policy for the API server",
+	},
+	"audit_webhook_config": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Sensitive:   true,
+		Description: "the configuration for the audit webhook backend",
+	},
 	"certs_dir": {
 		Type:        schema.TypeString,
 		Optional:    true,
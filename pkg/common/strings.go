@@ -14,6 +14,13 @@
 
 package common
 
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
 // StringSliceUnique removes duplicates in a string slice
 func StringSliceUnique(slice []string) []string {
 	keys := make(map[string]bool)
@@ -26,3 +33,73 @@ func StringSliceUnique(slice []string) []string {
 	}
 	return list
 }
+
+// StringMap converts a map of interfaces (for example, a Terraform `TypeMap`) to a map of strings
+func StringMap(m map[string]interface{}) map[string]string {
+	res := map[string]string{}
+	for k, v := range m {
+		res[k] = fmt.Sprintf("%v", v)
+	}
+	return res
+}
+
+// CopyStringMap returns a copy of a map of strings
+func CopyStringMap(m map[string]string) map[string]string {
+	res := make(map[string]string, len(m))
+	for k, v := range m {
+		res[k] = v
+	}
+	return res
+}
+
+// BoolMap converts a map of interfaces (for example, a Terraform `TypeMap` of bools) to a map of bools
+func BoolMap(m map[string]interface{}) map[string]bool {
+	res := map[string]bool{}
+	for k, v := range m {
+		switch b := v.(type) {
+		case bool:
+			res[k] = b
+		case string:
+			res[k] = strings.ToLower(b) == "true"
+		}
+	}
+	return res
+}
+
+// FeatureGatesToString converts a map of feature gates to the format used in
+// the command line flags (ie, "SomeFeature=true,OtherFeature=false"), sorted by name
+func FeatureGatesToString(gates map[string]bool) string {
+	names := make([]string, 0, len(gates))
+	for name := range gates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pairs := make([]string, 0, len(names))
+	for _, name := range names {
+		pairs = append(pairs, fmt.Sprintf("%s=%t", name, gates[name]))
+	}
+	return strings.Join(pairs, ",")
+}
+
+// FeatureGatesFromString parses the feature gates in the format used in
+// the command line flags (ie, "SomeFeature=true,OtherFeature=false")
+func FeatureGatesFromString(s string) (map[string]bool, error) {
+	gates := map[string]bool{}
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if len(pair) == 0 {
+			continue
+		}
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid feature gate %q: must be in the form 'Name=true|false'", pair)
+		}
+		value, err := strconv.ParseBool(strings.TrimSpace(kv[1]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for feature gate %q: %s", kv[0], err)
+		}
+		gates[strings.TrimSpace(kv[0])] = value
+	}
+	return gates, nil
+}
@@ -57,3 +57,44 @@ func TestStringSliceUnique(t *testing.T) {
 		}
 	}
 }
+
+func TestCopyStringMap(t *testing.T) {
+	orig := map[string]string{"some": "value"}
+
+	res := CopyStringMap(orig)
+	res["other"] = "value"
+	if _, ok := orig["other"]; ok || len(orig) != 1 {
+		t.Fatalf("Error: the original map has been modified: %v", orig)
+	}
+	if res["some"] != "value" {
+		t.Fatalf("Error: %q not copied: %v", "some", res)
+	}
+}
+
+func TestFeatureGatesToString(t *testing.T) {
+	gates := BoolMap(map[string]interface{}{
+		"SomeFeature":  true,
+		"OtherFeature": false,
+	})
+
+	expected := "OtherFeature=false,SomeFeature=true"
+	if out := FeatureGatesToString(gates); out != expected {
+		t.Fatalf("Error: expected output does not match: %q != %q", out, expected)
+	}
+}
+
+func TestFeatureGatesFromString(t *testing.T) {
+	gates, err := FeatureGatesFromString("SomeFeature=true, OtherFeature=false")
+	if err != nil {
+		t.Fatalf("Error: could not parse feature gates: %s", err)
+	}
+	if len(gates) != 2 || !gates["SomeFeature"] || gates["OtherFeature"] {
+		t.Fatalf("Error: wrong feature gates: %v", gates)
+	}
+
+	for _, invalid := range []string{"SomeFeature", "SomeFeature=maybe"} {
+		if _, err := FeatureGatesFromString(invalid); err == nil {
+			t.Fatalf("Error: %q should not be valid", invalid)
+		}
+	}
+}
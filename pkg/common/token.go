@@ -18,8 +18,13 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"fmt"
+	"time"
 
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 	kubeadmapi "k8s.io/kubernetes/cmd/kubeadm/app/apis/kubeadm"
+	kubeadmvalidation "k8s.io/kubernetes/cmd/kubeadm/app/apis/kubeadm/validation"
+	kubeadmconstants "k8s.io/kubernetes/cmd/kubeadm/app/constants"
 )
 
 const (
@@ -73,3 +78,42 @@ func NewRandomBootstrapToken() (kubeadmapi.BootstrapToken, error) {
 	}
 	return NewBootstrapToken(t)
 }
+
+// TokenOptions are the options for a bootstrap token
+type TokenOptions struct {
+	TTL         time.Duration
+	Usages      []string
+	Groups      []string
+	Description string
+}
+
+// NewTokenOptions returns the default options for the bootstrap tokens:
+// valid for 24h, for signing and authentication, in the kubeadm default group
+func NewTokenOptions() TokenOptions {
+	return TokenOptions{
+		TTL:    DefTokenTTL,
+		Usages: append([]string{}, kubeadmconstants.DefaultTokenUsages...),
+		Groups: append([]string{}, kubeadmconstants.DefaultTokenGroups...),
+	}
+}
+
+// NewBootstrapTokenWithOptions creates a bootstrap token with some options,
+// checking the usages and groups are valid.
+// Note well: the expiration time is computed from the TTL when the token is created.
+func NewBootstrapTokenWithOptions(token string, opts TokenOptions) (kubeadmapi.BootstrapToken, error) {
+	bto, err := NewBootstrapToken(token)
+	if err != nil {
+		return kubeadmapi.BootstrapToken{}, err
+	}
+	bto.TTL = &metav1.Duration{Duration: opts.TTL}
+	bto.Expires = nil
+	bto.Usages = opts.Usages
+	bto.Groups = opts.Groups
+	bto.Description = opts.Description
+
+	errs := kubeadmvalidation.ValidateBootstrapTokens([]kubeadmapi.BootstrapToken{bto}, field.NewPath("token"))
+	if len(errs) > 0 {
+		return kubeadmapi.BootstrapToken{}, errs.ToAggregate()
+	}
+	return bto, nil
+}
@@ -20,8 +20,10 @@ import (
 	"net/url"
 	"path/filepath"
 	"regexp"
+	"time"
 
 	"github.com/hashicorp/terraform/helper/validation"
+	"k8s.io/apimachinery/pkg/util/version"
 )
 
 const dnsRegex = `^(([a-zA-Z0-9]|[a-zA-Z0-9][a-zA-Z0-9\-]*[a-zA-Z0-9])\.)*([A-Za-z0-9]|[A-Za-z0-9][A-Za-z0-9\-]*[A-Za-z0-9])$`
@@ -43,8 +45,9 @@ func ValidateAbsPath(v interface{}, k string) (ws []string, errors []error) {
 }
 
 func ValidateHostPort(v interface{}, k string) (ws []string, errors []error) {
-	_, _, err := net.SplitHostPort(v.(string))
-	errors = append(errors, fmt.Errorf("%q is not an valid 'expectedHost:expectedPort': %s", k, err))
+	if _, _, err := net.SplitHostPort(v.(string)); err != nil {
+		errors = append(errors, fmt.Errorf("%q is not an valid 'expectedHost:expectedPort': %s", k, err))
+	}
 	return
 }
 
@@ -55,3 +58,49 @@ func ValidateURL(v interface{}, k string) (ws []string, errors []error) {
 	}
 	return
 }
+
+// ValidateDuration validates a duration (ie, "8760h")
+func ValidateDuration(v interface{}, k string) (ws []string, errors []error) {
+	if _, err := time.ParseDuration(v.(string)); err != nil {
+		errors = append(errors, fmt.Errorf("%q is not a valid duration: %s", k, err))
+	}
+	return
+}
+
+// ValidateVersionUpgrade checks that we can upgrade a cluster from one Kubernetes
+// version to another: kubeadm does not support downgrades, and it can only
+// upgrade from one minor version to the next one.
+func ValidateVersionUpgrade(from, to string) error {
+	fromVersion, err := version.ParseGeneric(from)
+	if err != nil {
+		return fmt.Errorf("could not parse current version %q: %s", from, err)
+	}
+	toVersion, err := version.ParseGeneric(to)
+	if err != nil {
+		return fmt.Errorf("could not parse new version %q: %s", to, err)
+	}
+
+	if toVersion.LessThan(fromVersion) {
+		return fmt.Errorf("cannot downgrade from %s to %s", from, to)
+	}
+	if toVersion.Major() != fromVersion.Major() || toVersion.Minor() > fromVersion.Minor()+1 {
+		return fmt.Errorf("cannot upgrade from %s to %s: kubeadm can only upgrade to the next minor version", from, to)
+	}
+	return nil
+}
+
+// ValidateMinVersion validates that a Kubernetes version is at least some minimum version
+func ValidateMinVersion(v, min string) error {
+	ver, err := version.ParseGeneric(v)
+	if err != nil {
+		return fmt.Errorf("could not parse version %q: %s", v, err)
+	}
+	minVersion, err := version.ParseGeneric(min)
+	if err != nil {
+		return fmt.Errorf("could not parse version %q: %s", min, err)
+	}
+	if ver.LessThan(minVersion) {
+		return fmt.Errorf("version %s is older than %s", v, min)
+	}
+	return nil
+}
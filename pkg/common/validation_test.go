@@ -0,0 +1,93 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"testing"
+)
+
+func TestValidateVersionUpgrade(t *testing.T) {
+
+	testsCases := []struct {
+		from        string
+		to          string
+		expectedErr bool
+	}{
+		{"v1.15.0", "v1.15.0", false},
+		{"v1.15.0", "v1.15.3", false},
+		{"v1.15.3", "v1.16.0", false},
+		{"1.15.3", "v1.16.2", false},
+		{"v1.15.3", "v1.15.1", true},
+		{"v1.16.0", "v1.15.3", true},
+		{"v1.15.0", "v1.17.0", true},
+		{"v1.15.0", "latest", true},
+	}
+
+	for _, testCase := range testsCases {
+		err := ValidateVersionUpgrade(testCase.from, testCase.to)
+		if testCase.expectedErr && err == nil {
+			t.Fatalf("Error: upgrade from %q to %q should have failed", testCase.from, testCase.to)
+		}
+		if !testCase.expectedErr && err != nil {
+			t.Fatalf("Error: upgrade from %q to %q failed: %s", testCase.from, testCase.to, err)
+		}
+	}
+}
+
+func TestValidateMinVersion(t *testing.T) {
+
+	testsCases := []struct {
+		version     string
+		expectedErr bool
+	}{
+		{"v1.15.0", false},
+		{"1.16.2", false},
+		{"v1.14.3", true},
+		{"latest", true},
+	}
+
+	for _, testCase := range testsCases {
+		err := ValidateMinVersion(testCase.version, "v1.15.0")
+		if testCase.expectedErr && err == nil {
+			t.Fatalf("Error: %q should not be accepted", testCase.version)
+		}
+		if !testCase.expectedErr && err != nil {
+			t.Fatalf("Error: %q not accepted: %s", testCase.version, err)
+		}
+	}
+}
+
+func TestValidateHostPort(t *testing.T) {
+
+	testsCases := []struct {
+		input       string
+		expectedErr bool
+	}{
+		{"127.0.0.1:6443", false},
+		{"0.0.0.0:10249", false},
+		{"some.place:6443", false},
+		{"some.place", true},
+	}
+
+	for _, testCase := range testsCases {
+		_, errs := ValidateHostPort(testCase.input, "listen")
+		if testCase.expectedErr && len(errs) == 0 {
+			t.Fatalf("Error: %q should not be valid", testCase.input)
+		}
+		if !testCase.expectedErr && len(errs) > 0 {
+			t.Fatalf("Error: %q should be valid: %s", testCase.input, errs)
+		}
+	}
+}
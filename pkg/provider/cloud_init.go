@@ -0,0 +1,41 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/hashicorp/terraform/helper/schema"
+
+	"github.com/inercia/terraform-provider-kubeadm/pkg/provisioner"
+)
+
+// dataSourceKubeadmCloudInitRead renders a cloud-config document for
+// provisioning a machine without the kubeadm provisioner
+func dataSourceKubeadmCloudInitRead(d *schema.ResourceData, meta interface{}) error {
+	rendered, err := provisioner.RenderCloudInit(d)
+	if err != nil {
+		return err
+	}
+
+	if err := d.Set("rendered", string(rendered)); err != nil {
+		return err
+	}
+
+	sum := sha256.Sum256(rendered)
+	d.SetId(hex.EncodeToString(sum[:]))
+	return nil
+}
@@ -0,0 +1,187 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/terraform/helper/schema"
+	kubeadmapi "k8s.io/kubernetes/cmd/kubeadm/app/apis/kubeadm"
+	kubeadmapiv1beta1 "k8s.io/kubernetes/cmd/kubeadm/app/apis/kubeadm/v1beta1"
+	"k8s.io/kubernetes/cmd/kubeadm/app/componentconfigs"
+	kubeproxyconfig "k8s.io/kubernetes/pkg/proxy/apis/config"
+
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
+)
+
+// dataSourceToFeatureGates sets the feature gates for kubeadm and for
+// the control plane components (API server, controller manager and scheduler).
+// The feature gates for the kubelet and kube-proxy are set in their component configs.
+func dataSourceToFeatureGates(d *schema.ResourceData, initConfig *kubeadmapi.InitConfiguration) error {
+	if _, ok := d.GetOk("feature_gates.0"); !ok {
+		return nil
+	}
+
+	// note: the feature gates are not validated here, as they depend
+	// on the kubeadm version installed in the machines
+	if gates, ok := d.GetOk("feature_gates.0.kubeadm"); ok {
+		initConfig.FeatureGates = common.BoolMap(gates.(map[string]interface{}))
+	}
+
+	if gatesRaw, ok := d.GetOk("feature_gates.0.components"); ok {
+		gates := common.BoolMap(gatesRaw.(map[string]interface{}))
+		ssh.Debug("setting feature gates for components: %s", common.FeatureGatesToString(gates))
+
+		for _, component := range []*kubeadmapi.ControlPlaneComponent{
+			&initConfig.APIServer.ControlPlaneComponent,
+			&initConfig.ControllerManager,
+			&initConfig.Scheduler,
+		} {
+			if component.ExtraArgs == nil {
+				component.ExtraArgs = map[string]string{}
+			}
+
+			// merge with the feature gates in the `runtime.extra_args`
+			merged, err := common.FeatureGatesFromString(component.ExtraArgs["feature-gates"])
+			if err != nil {
+				return fmt.Errorf("invalid 'feature-gates' in 'runtime.extra_args': %s", err)
+			}
+			for name, value := range gates {
+				if prev, ok := merged[name]; ok && prev != value {
+					return fmt.Errorf("feature gate %q has different values in 'feature_gates.components' and 'runtime.extra_args'", name)
+				}
+				merged[name] = value
+			}
+			component.ExtraArgs["feature-gates"] = common.FeatureGatesToString(merged)
+		}
+	}
+
+	return nil
+}
+
+// dataSourceToKubeletConfig sets the kubelet component config from the `kubelet_config` block
+// (and the feature gates for components)
+func dataSourceToKubeletConfig(d *schema.ResourceData, initConfig *kubeadmapi.InitConfiguration) error {
+	_, hasConfig := d.GetOk("kubelet_config.0")
+	gates, hasGates := d.GetOk("feature_gates.0.components")
+	if !hasConfig && !hasGates {
+		return nil
+	}
+
+	// start from the defaults kubeadm would use
+	componentconfigs.Known[componentconfigs.KubeletConfigurationKind].DefaulterFunc(&initConfig.ClusterConfiguration)
+	kubeletConfig := initConfig.ComponentConfigs.Kubelet
+
+	if hasGates {
+		kubeletConfig.FeatureGates = common.BoolMap(gates.(map[string]interface{}))
+	}
+
+	if hasConfig {
+		if arg, ok := d.GetOk("kubelet_config.0.cgroup_driver"); ok {
+			kubeletConfig.CgroupDriver = arg.(string)
+		}
+		if arg, ok := d.GetOk("kubelet_config.0.max_pods"); ok {
+			kubeletConfig.MaxPods = int32(arg.(int))
+		}
+		kubeletConfig.FailSwapOn = d.Get("kubelet_config.0.fail_swap_on").(bool)
+		kubeletConfig.ProtectKernelDefaults = d.Get("kubelet_config.0.protect_kernel_defaults").(bool)
+		if arg, ok := d.GetOk("kubelet_config.0.image_gc_high_threshold"); ok {
+			kubeletConfig.ImageGCHighThresholdPercent = int32(arg.(int))
+		}
+		if arg, ok := d.GetOk("kubelet_config.0.image_gc_low_threshold"); ok {
+			kubeletConfig.ImageGCLowThresholdPercent = int32(arg.(int))
+		}
+		if arg, ok := d.GetOk("kubelet_config.0.eviction_hard"); ok {
+			kubeletConfig.EvictionHard = common.StringMap(arg.(map[string]interface{}))
+		}
+		if arg, ok := d.GetOk("kubelet_config.0.eviction_soft"); ok {
+			kubeletConfig.EvictionSoft = common.StringMap(arg.(map[string]interface{}))
+		}
+		if arg, ok := d.GetOk("kubelet_config.0.eviction_soft_grace_period"); ok {
+			kubeletConfig.EvictionSoftGracePeriod = common.StringMap(arg.(map[string]interface{}))
+		}
+		if arg, ok := d.GetOk("kubelet_config.0.system_reserved"); ok {
+			kubeletConfig.SystemReserved = common.StringMap(arg.(map[string]interface{}))
+		}
+		if arg, ok := d.GetOk("kubelet_config.0.kube_reserved"); ok {
+			kubeletConfig.KubeReserved = common.StringMap(arg.(map[string]interface{}))
+		}
+	}
+
+	return nil
+}
+
+// dataSourceToKubeProxyConfig sets the kube-proxy component config from the `kube_proxy_config` block
+// (and the feature gates for components)
+func dataSourceToKubeProxyConfig(d *schema.ResourceData, initConfig *kubeadmapi.InitConfiguration) error {
+	_, hasConfig := d.GetOk("kube_proxy_config.0")
+	gates, hasGates := d.GetOk("feature_gates.0.components")
+	if !hasConfig && !hasGates {
+		return nil
+	}
+
+	// start from the defaults kubeadm would use
+	componentconfigs.Known[componentconfigs.KubeProxyConfigurationKind].DefaulterFunc(&initConfig.ClusterConfiguration)
+	kubeProxyConfig := initConfig.ComponentConfigs.KubeProxy
+
+	if hasGates {
+		kubeProxyConfig.FeatureGates = common.BoolMap(gates.(map[string]interface{}))
+	}
+
+	if hasConfig {
+		if arg, ok := d.GetOk("kube_proxy_config.0.mode"); ok {
+			kubeProxyConfig.Mode = kubeproxyconfig.ProxyMode(arg.(string))
+		}
+		if arg, ok := d.GetOk("kube_proxy_config.0.ipvs_scheduler"); ok {
+			kubeProxyConfig.IPVS.Scheduler = arg.(string)
+		}
+		if arg, ok := d.GetOk("kube_proxy_config.0.ipvs_exclude_cidrs"); ok {
+			for _, cidr := range arg.([]interface{}) {
+				kubeProxyConfig.IPVS.ExcludeCIDRs = append(kubeProxyConfig.IPVS.ExcludeCIDRs, cidr.(string))
+			}
+		}
+		kubeProxyConfig.IPTables.MasqueradeAll = d.Get("kube_proxy_config.0.masquerade_all").(bool)
+		if arg, ok := d.GetOk("kube_proxy_config.0.conntrack_max_per_core"); ok {
+			maxPerCore := int32(arg.(int))
+			kubeProxyConfig.Conntrack.MaxPerCore = &maxPerCore
+		}
+		if arg, ok := d.GetOk("kube_proxy_config.0.metrics_bind_address"); ok {
+			kubeProxyConfig.MetricsBindAddress = arg.(string)
+		}
+	}
+
+	return nil
+}
+
+// dataSourceToComponentConfigs sets all the component configs (kubelet, kube-proxy...)
+// Note well: this must be done once the networking configuration has been set, as
+// the defaults for some components (ie, the cluster DNS) depend on it.
+func dataSourceToComponentConfigs(d *schema.ResourceData, initConfig *kubeadmapi.InitConfiguration) error {
+	if initConfig.Networking.DNSDomain == "" {
+		initConfig.Networking.DNSDomain = kubeadmapiv1beta1.DefaultServiceDNSDomain
+	}
+	if initConfig.Networking.ServiceSubnet == "" {
+		initConfig.Networking.ServiceSubnet = kubeadmapiv1beta1.DefaultServicesSubnet
+	}
+
+	if err := dataSourceToKubeletConfig(d, initConfig); err != nil {
+		return err
+	}
+	if err := dataSourceToKubeProxyConfig(d, initConfig); err != nil {
+		return err
+	}
+	return nil
+}
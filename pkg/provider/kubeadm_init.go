@@ -17,10 +17,15 @@ package provider
 import (
 	"fmt"
 	"net"
+	"path"
 	"regexp"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/hashicorp/terraform/helper/schema"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	kubeadmapi "k8s.io/kubernetes/cmd/kubeadm/app/apis/kubeadm"
 
 	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
@@ -34,14 +39,14 @@ func dataSourceToInitConfig(d *schema.ResourceData, token string) (*kubeadmapi.I
 
 	initConfig := &kubeadmapi.InitConfiguration{
 		ClusterConfiguration: kubeadmapi.ClusterConfiguration{
-			// FeatureGates:         featureGates,
 			APIServer: kubeadmapi.APIServer{
 				CertSANs: []string{},
 			},
 			UseHyperKubeImage: true,
 		},
 		NodeRegistration: kubeadmapi.NodeRegistrationOptions{
-			KubeletExtraArgs: common.DefKubeletSettings,
+			// note: copy the defaults, as the map is modified later on
+			KubeletExtraArgs: common.CopyStringMap(common.DefKubeletSettings),
 		},
 	}
 
@@ -68,6 +73,16 @@ func dataSourceToInitConfig(d *schema.ResourceData, token string) (*kubeadmapi.I
 			initConfig.ClusterConfiguration.APIServer.CertSANs = append(initConfig.ClusterConfiguration.APIServer.CertSANs, host)
 		}
 
+		// the virtual IP is announced by the masters, so it can be used as a stable endpoint
+		// (note: it points to the API server in one of the masters, so it uses the same port)
+		if vip, ok := d.GetOk("api.0.vip"); ok {
+			port := int(initConfig.LocalAPIEndpoint.BindPort)
+			if port == 0 {
+				port = common.DefAPIServerPort
+			}
+			initConfig.ControlPlaneEndpoint = net.JoinHostPort(vip.(string), strconv.Itoa(port))
+		}
+
 		if altNames, ok := d.GetOk("api.0.alt_names"); ok {
 			initConfig.APIServer.CertSANs = append(initConfig.APIServer.CertSANs, altNames.([]string)...)
 		}
@@ -135,16 +150,18 @@ func dataSourceToInitConfig(d *schema.ResourceData, token string) (*kubeadmapi.I
 
 		if _, ok := d.GetOk("runtime.0.extra_args.0"); ok {
 			if args, ok := d.GetOk("runtime.0.extra_args.0.api_server"); ok {
-				initConfig.ClusterConfiguration.APIServer.ExtraArgs = args.(map[string]string)
+				initConfig.ClusterConfiguration.APIServer.ExtraArgs = common.StringMap(args.(map[string]interface{}))
 			}
 			if args, ok := d.GetOk("runtime.0.extra_args.0.controller_manager"); ok {
-				initConfig.ClusterConfiguration.ControllerManager.ExtraArgs = args.(map[string]string)
+				initConfig.ClusterConfiguration.ControllerManager.ExtraArgs = common.StringMap(args.(map[string]interface{}))
 			}
 			if args, ok := d.GetOk("runtime.0.extra_args.0.scheduler"); ok {
-				initConfig.ClusterConfiguration.Scheduler.ExtraArgs = args.(map[string]string)
+				initConfig.ClusterConfiguration.Scheduler.ExtraArgs = common.StringMap(args.(map[string]interface{}))
 			}
 			if args, ok := d.GetOk("runtime.0.extra_args.0.kubelet"); ok {
-				initConfig.NodeRegistration.KubeletExtraArgs = args.(map[string]string)
+				for k, v := range common.StringMap(args.(map[string]interface{})) {
+					initConfig.NodeRegistration.KubeletExtraArgs[k] = v
+				}
 			}
 		}
 	}
@@ -183,22 +200,159 @@ func dataSourceToInitConfig(d *schema.ResourceData, token string) (*kubeadmapi.I
 	}
 
 	if _, ok := d.GetOk("etcd.0"); ok {
-		if etcdServersLst, ok := d.GetOk("etcd.0.endpoints"); ok {
-			if initConfig.Etcd.External == nil {
-				initConfig.Etcd.External = &kubeadmapi.ExternalEtcd{}
-			}
-			initConfig.Etcd.External.Endpoints = etcdServersLst.([]string)
+		if err := dataSourceToExternalEtcd(d, initConfig); err != nil {
+			return nil, err
 		}
 	}
 
+	// when encrypting Secrets, the API server must be able to read the encryption
+	// configuration (that will be uploaded by the provisioner)
+	if _, ok := d.GetOk("encryption.0"); ok {
+		if initConfig.APIServer.ExtraArgs == nil {
+			initConfig.APIServer.ExtraArgs = map[string]string{}
+		}
+		initConfig.APIServer.ExtraArgs["encryption-provider-config"] = common.DefEncryptionConfigPath
+		initConfig.APIServer.ExtraVolumes = append(initConfig.APIServer.ExtraVolumes, kubeadmapi.HostPathMount{
+			Name:      "encryption-config",
+			HostPath:  common.DefEncryptionConfigDir,
+			MountPath: common.DefEncryptionConfigDir,
+			ReadOnly:  true,
+			PathType:  v1.HostPathDirectoryOrCreate,
+		})
+	}
+
+	// the API server must be able to read the audit policy (that will be uploaded
+	// by the provisioner) and write the audit log
+	if _, ok := d.GetOk("audit.0"); ok {
+		if initConfig.APIServer.ExtraArgs == nil {
+			initConfig.APIServer.ExtraArgs = map[string]string{}
+		}
+		initConfig.APIServer.ExtraArgs["audit-policy-file"] = common.DefAuditPolicyPath
+		initConfig.APIServer.ExtraVolumes = append(initConfig.APIServer.ExtraVolumes, kubeadmapi.HostPathMount{
+			Name:      "audit-config",
+			HostPath:  common.DefAuditConfigDir,
+			MountPath: common.DefAuditConfigDir,
+			ReadOnly:  true,
+			PathType:  v1.HostPathDirectoryOrCreate,
+		})
+
+		logPath := d.Get("audit.0.log_path").(string)
+		initConfig.APIServer.ExtraArgs["audit-log-path"] = logPath
+		if logPath != "-" {
+			initConfig.APIServer.ExtraArgs["audit-log-maxage"] = strconv.Itoa(d.Get("audit.0.max_age").(int))
+			initConfig.APIServer.ExtraArgs["audit-log-maxsize"] = strconv.Itoa(d.Get("audit.0.max_size").(int))
+			initConfig.APIServer.ExtraArgs["audit-log-maxbackup"] = strconv.Itoa(d.Get("audit.0.max_backup").(int))
+
+			logDir := path.Dir(logPath)
+			initConfig.APIServer.ExtraVolumes = append(initConfig.APIServer.ExtraVolumes, kubeadmapi.HostPathMount{
+				Name:      "audit-log",
+				HostPath:  logDir,
+				MountPath: logDir,
+				ReadOnly:  false,
+				PathType:  v1.HostPathDirectoryOrCreate,
+			})
+		}
+
+		if webhookConfig, ok := d.GetOk("audit.0.webhook_config"); ok && len(webhookConfig.(string)) > 0 {
+			initConfig.APIServer.ExtraArgs["audit-webhook-config-file"] = common.DefAuditWebhookConfigPath
+		}
+	}
+
+	// enable the PodSecurityPolicy admission plugin (the policies will be loaded by the provisioner)
+	if _, ok := d.GetOk("pod_security.0"); ok {
+		if initConfig.APIServer.ExtraArgs == nil {
+			initConfig.APIServer.ExtraArgs = map[string]string{}
+		}
+		plugins := common.DefAdmissionPlugins
+		if current, ok := initConfig.APIServer.ExtraArgs["enable-admission-plugins"]; ok && len(current) > 0 {
+			plugins = current
+		}
+		if !strings.Contains(plugins, "PodSecurityPolicy") {
+			plugins = plugins + ",PodSecurityPolicy"
+		}
+		initConfig.APIServer.ExtraArgs["enable-admission-plugins"] = plugins
+	}
+
+	if err := dataSourceToFeatureGates(d, initConfig); err != nil {
+		return nil, err
+	}
+
+	if err := dataSourceToComponentConfigs(d, initConfig); err != nil {
+		return nil, err
+	}
+
 	if len(token) > 0 {
-		t, err := common.NewBootstrapToken(token)
+		opts, err := getTokenOptions(d, "token.0.")
+		if err != nil {
+			return nil, err
+		}
+		t, err := common.NewBootstrapTokenWithOptions(token, opts)
 		if err != nil {
 			return nil, err
 		}
-		t.Expires = nil
+		// the token in the join attributes expires at a known time
+		// (instead of some time after the `kubeadm init`)
+		if opts.TTL > 0 {
+			t.Expires = &metav1.Time{Time: time.Now().Add(opts.TTL)}
+		}
 		initConfig.BootstrapTokens = []kubeadmapi.BootstrapToken{t}
 	}
 
 	return initConfig, nil
 }
+
+// dataSourceToExternalEtcd sets the configuration for using an external etcd cluster.
+// The certificates (when provided) will be uploaded by the provisioner to the
+// control plane machines, so we just set the paths here.
+func dataSourceToExternalEtcd(d *schema.ResourceData, initConfig *kubeadmapi.InitConfiguration) error {
+	endpoints := []string{}
+	if etcdServersLst, ok := d.GetOk("etcd.0.endpoints"); ok {
+		for _, endpoint := range etcdServersLst.([]interface{}) {
+			endpoints = append(endpoints, endpoint.(string))
+		}
+	}
+
+	_, hasCA := d.GetOk("etcd.0.ca_crt")
+	_, hasCrt := d.GetOk("etcd.0.client_crt")
+	_, hasKey := d.GetOk("etcd.0.client_key")
+	managed := d.Get("etcd.0.managed").(bool)
+	if len(endpoints) == 0 {
+		if hasCA || hasCrt || hasKey {
+			return fmt.Errorf("etcd certificates provided but no etcd endpoints")
+		}
+		if managed {
+			return fmt.Errorf("a managed etcd cluster requires some etcd endpoints")
+		}
+		return nil
+	}
+	if (hasCrt || hasKey) && !(hasCA && hasCrt && hasKey) {
+		return fmt.Errorf("'ca_crt', 'client_crt' and 'client_key' must be provided for using etcd client certificates")
+	}
+
+	// when the etcd members are bootstrapped by the provisioner (with `role = "etcd"`),
+	// the certificates are signed by our etcd CA
+	if managed {
+		if hasCA {
+			return fmt.Errorf("etcd certificates cannot be provided for a managed etcd cluster")
+		}
+		for _, endpoint := range endpoints {
+			if !strings.HasPrefix(endpoint, "https://") {
+				return fmt.Errorf("all the endpoints of a managed etcd cluster must use 'https://' (found %q)", endpoint)
+			}
+		}
+	}
+
+	initConfig.Etcd.Local = nil
+	initConfig.Etcd.External = &kubeadmapi.ExternalEtcd{
+		Endpoints: endpoints,
+	}
+	if hasCA || managed {
+		initConfig.Etcd.External.CAFile = common.DefExternalEtcdCACrtPath
+	}
+	if (hasCrt && hasKey) || managed {
+		initConfig.Etcd.External.CertFile = common.DefExternalEtcdClientCrtPath
+		initConfig.Etcd.External.KeyFile = common.DefExternalEtcdClientKeyPath
+	}
+
+	return nil
+}
@@ -17,6 +17,7 @@ package provider
 import (
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/hashicorp/terraform/helper/schema"
 
@@ -47,3 +48,358 @@ func TestKubeadmInitConfigSerialization(t *testing.T) {
 	fmt.Printf("----------------- init configuration ---------------- \n%s", initConfigBytes)
 
 }
+
+func TestKubeadmInitConfigComponents(t *testing.T) {
+	raw := map[string]interface{}{
+		"network": []interface{}{
+			map[string]interface{}{
+				"services": "10.25.0.0/16",
+			},
+		},
+		"feature_gates": []interface{}{
+			map[string]interface{}{
+				"components": map[string]interface{}{
+					"SomeFeature": true,
+				},
+			},
+		},
+		"kubelet_config": []interface{}{
+			map[string]interface{}{
+				"cgroup_driver": "systemd",
+				"eviction_hard": map[string]interface{}{
+					"memory.available": "100Mi",
+				},
+			},
+		},
+		"kube_proxy_config": []interface{}{
+			map[string]interface{}{
+				"mode": "ipvs",
+			},
+		},
+		"runtime": []interface{}{
+			map[string]interface{}{
+				"extra_args": []interface{}{
+					map[string]interface{}{
+						"api_server": map[string]interface{}{
+							"some-arg": "some-value",
+						},
+						"controller_manager": map[string]interface{}{
+							"feature-gates": "OtherFeature=false",
+						},
+						"kubelet": map[string]interface{}{
+							"some-kubelet-arg": "some-value",
+						},
+					},
+				},
+			},
+		},
+	}
+	d := schema.TestResourceDataRaw(t, dataSourceKubeadm().Schema, raw)
+
+	initConfig, err := dataSourceToInitConfig(d, "")
+	if err != nil {
+		t.Fatalf("could not create initConfig from dataSource: %s", err)
+	}
+
+	if initConfig.APIServer.ExtraArgs["some-arg"] != "some-value" {
+		t.Fatalf("Error: wrong API server extra args: %v", initConfig.APIServer.ExtraArgs)
+	}
+	if initConfig.NodeRegistration.KubeletExtraArgs["some-kubelet-arg"] != "some-value" {
+		t.Fatalf("Error: wrong kubelet extra args: %v", initConfig.NodeRegistration.KubeletExtraArgs)
+	}
+	if _, ok := common.DefKubeletSettings["some-kubelet-arg"]; ok {
+		t.Fatalf("Error: the kubelet extra args have been added to the defaults: %v", common.DefKubeletSettings)
+	}
+	if initConfig.APIServer.ExtraArgs["feature-gates"] != "SomeFeature=true" {
+		t.Fatalf("Error: wrong API server feature gates: %v", initConfig.APIServer.ExtraArgs)
+	}
+	if initConfig.ControllerManager.ExtraArgs["feature-gates"] != "OtherFeature=false,SomeFeature=true" {
+		t.Fatalf("Error: wrong controller manager feature gates: %v", initConfig.ControllerManager.ExtraArgs)
+	}
+
+	kubeletConfig := initConfig.ComponentConfigs.Kubelet
+	if kubeletConfig == nil {
+		t.Fatalf("Error: no kubelet configuration")
+	}
+	if kubeletConfig.CgroupDriver != "systemd" {
+		t.Fatalf("Error: wrong cgroup driver: %q", kubeletConfig.CgroupDriver)
+	}
+	if kubeletConfig.EvictionHard["memory.available"] != "100Mi" {
+		t.Fatalf("Error: wrong eviction thresholds: %v", kubeletConfig.EvictionHard)
+	}
+	if !kubeletConfig.FeatureGates["SomeFeature"] {
+		t.Fatalf("Error: wrong kubelet feature gates: %v", kubeletConfig.FeatureGates)
+	}
+	if len(kubeletConfig.ClusterDNS) != 1 || kubeletConfig.ClusterDNS[0] != "10.25.0.10" {
+		t.Fatalf("Error: wrong cluster DNS: %v", kubeletConfig.ClusterDNS)
+	}
+
+	kubeProxyConfig := initConfig.ComponentConfigs.KubeProxy
+	if kubeProxyConfig == nil {
+		t.Fatalf("Error: no kube-proxy configuration")
+	}
+	if kubeProxyConfig.Mode != "ipvs" {
+		t.Fatalf("Error: wrong kube-proxy mode: %q", kubeProxyConfig.Mode)
+	}
+
+	initConfigBytes, err := common.InitConfigToYAML(initConfig)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	fmt.Printf("----------------- init configuration ---------------- \n%s", initConfigBytes)
+}
+
+func TestKubeadmInitConfigEncryption(t *testing.T) {
+	raw := map[string]interface{}{
+		"encryption": []interface{}{
+			map[string]interface{}{
+				"provider": "aescbc",
+			},
+		},
+	}
+	d := schema.TestResourceDataRaw(t, dataSourceKubeadm().Schema, raw)
+
+	initConfig, err := dataSourceToInitConfig(d, "")
+	if err != nil {
+		t.Fatalf("could not create initConfig from dataSource: %s", err)
+	}
+
+	if initConfig.APIServer.ExtraArgs["encryption-provider-config"] != common.DefEncryptionConfigPath {
+		t.Fatalf("Error: wrong API server extra args: %v", initConfig.APIServer.ExtraArgs)
+	}
+	if len(initConfig.APIServer.ExtraVolumes) != 1 || initConfig.APIServer.ExtraVolumes[0].HostPath != common.DefEncryptionConfigDir {
+		t.Fatalf("Error: wrong API server extra volumes: %v", initConfig.APIServer.ExtraVolumes)
+	}
+}
+
+func TestKubeadmInitConfigAudit(t *testing.T) {
+	raw := map[string]interface{}{
+		"audit": []interface{}{
+			map[string]interface{}{
+				"log_path":       "/var/log/audit/k8s.log",
+				"max_age":        7,
+				"webhook_config": "some-kubeconfig",
+			},
+		},
+	}
+	d := schema.TestResourceDataRaw(t, dataSourceKubeadm().Schema, raw)
+
+	initConfig, err := dataSourceToInitConfig(d, "")
+	if err != nil {
+		t.Fatalf("could not create initConfig from dataSource: %s", err)
+	}
+
+	expectedArgs := map[string]string{
+		"audit-policy-file":         common.DefAuditPolicyPath,
+		"audit-log-path":            "/var/log/audit/k8s.log",
+		"audit-log-maxage":          "7",
+		"audit-log-maxsize":         fmt.Sprintf("%d", common.DefAuditLogMaxSize),
+		"audit-webhook-config-file": common.DefAuditWebhookConfigPath,
+	}
+	for k, v := range expectedArgs {
+		if initConfig.APIServer.ExtraArgs[k] != v {
+			t.Fatalf("Error: wrong value for %q in API server extra args: %v", k, initConfig.APIServer.ExtraArgs)
+		}
+	}
+
+	mounts := map[string]bool{}
+	for _, volume := range initConfig.APIServer.ExtraVolumes {
+		mounts[volume.MountPath] = volume.ReadOnly
+	}
+	if readOnly, ok := mounts[common.DefAuditConfigDir]; !ok || !readOnly {
+		t.Fatalf("Error: audit config dir not mounted read-only: %v", initConfig.APIServer.ExtraVolumes)
+	}
+	if readOnly, ok := mounts["/var/log/audit"]; !ok || readOnly {
+		t.Fatalf("Error: audit log dir not mounted read-write: %v", initConfig.APIServer.ExtraVolumes)
+	}
+}
+
+func TestKubeadmInitConfigPodSecurity(t *testing.T) {
+	raw := map[string]interface{}{
+		"pod_security": []interface{}{
+			map[string]interface{}{
+				"default_policy": "restricted",
+			},
+		},
+		"runtime": []interface{}{
+			map[string]interface{}{
+				"extra_args": []interface{}{
+					map[string]interface{}{
+						"api_server": map[string]interface{}{
+							"enable-admission-plugins": "NodeRestriction,AlwaysPullImages",
+						},
+					},
+				},
+			},
+		},
+	}
+	d := schema.TestResourceDataRaw(t, dataSourceKubeadm().Schema, raw)
+
+	initConfig, err := dataSourceToInitConfig(d, "")
+	if err != nil {
+		t.Fatalf("could not create initConfig from dataSource: %s", err)
+	}
+
+	expected := "NodeRestriction,AlwaysPullImages,PodSecurityPolicy"
+	if plugins := initConfig.APIServer.ExtraArgs["enable-admission-plugins"]; plugins != expected {
+		t.Fatalf("Error: wrong admission plugins: %q != %q", plugins, expected)
+	}
+}
+
+func TestKubeadmInitConfigExternalEtcd(t *testing.T) {
+	raw := map[string]interface{}{
+		"etcd": []interface{}{
+			map[string]interface{}{
+				"endpoints":  []interface{}{"https://etcd1.com:2379", "https://etcd2.com:2379"},
+				"ca_crt":     "some-ca",
+				"client_crt": "some-crt",
+				"client_key": "some-key",
+			},
+		},
+	}
+	d := schema.TestResourceDataRaw(t, dataSourceKubeadm().Schema, raw)
+
+	initConfig, err := dataSourceToInitConfig(d, "")
+	if err != nil {
+		t.Fatalf("could not create initConfig from dataSource: %s", err)
+	}
+
+	external := initConfig.Etcd.External
+	if external == nil || initConfig.Etcd.Local != nil {
+		t.Fatalf("Error: external etcd not configured: %+v", initConfig.Etcd)
+	}
+	if len(external.Endpoints) != 2 || external.Endpoints[1] != "https://etcd2.com:2379" {
+		t.Fatalf("Error: wrong etcd endpoints: %v", external.Endpoints)
+	}
+	if external.CAFile != common.DefExternalEtcdCACrtPath ||
+		external.CertFile != common.DefExternalEtcdClientCrtPath ||
+		external.KeyFile != common.DefExternalEtcdClientKeyPath {
+		t.Fatalf("Error: wrong etcd certificates: %+v", external)
+	}
+
+	// a client certificate without a key must be rejected
+	raw = map[string]interface{}{
+		"etcd": []interface{}{
+			map[string]interface{}{
+				"endpoints":  []interface{}{"https://etcd1.com:2379"},
+				"ca_crt":     "some-ca",
+				"client_crt": "some-crt",
+			},
+		},
+	}
+	d = schema.TestResourceDataRaw(t, dataSourceKubeadm().Schema, raw)
+	if _, err := dataSourceToInitConfig(d, ""); err == nil {
+		t.Fatalf("Error: no error for a client certificate without a key")
+	}
+
+	// an etcd cluster without certificates must not use our etcd CA unless it is managed
+	for _, managed := range []bool{false, true} {
+		raw = map[string]interface{}{
+			"etcd": []interface{}{
+				map[string]interface{}{
+					"endpoints": []interface{}{"https://etcd1.com:2379"},
+					"managed":   managed,
+				},
+			},
+		}
+		d = schema.TestResourceDataRaw(t, dataSourceKubeadm().Schema, raw)
+		initConfig, err = dataSourceToInitConfig(d, "")
+		if err != nil {
+			t.Fatalf("could not create initConfig from dataSource: %s", err)
+		}
+		if hasCerts := len(initConfig.Etcd.External.CAFile) > 0 && len(initConfig.Etcd.External.CertFile) > 0; hasCerts != managed {
+			t.Fatalf("Error: wrong etcd certificates (managed: %t): %+v", managed, initConfig.Etcd.External)
+		}
+	}
+
+	// a managed etcd cluster must use https
+	raw = map[string]interface{}{
+		"etcd": []interface{}{
+			map[string]interface{}{
+				"endpoints": []interface{}{"http://etcd1.com:2379"},
+				"managed":   true,
+			},
+		},
+	}
+	d = schema.TestResourceDataRaw(t, dataSourceKubeadm().Schema, raw)
+	if _, err := dataSourceToInitConfig(d, ""); err == nil {
+		t.Fatalf("Error: no error for a managed etcd cluster without https")
+	}
+}
+
+func TestKubeadmInitConfigToken(t *testing.T) {
+	token := "82eb2m.999999idy9l74yha"
+
+	// no `token` block: the token must expire with the default TTL
+	d := schema.TestResourceDataRaw(t, dataSourceKubeadm().Schema, map[string]interface{}{})
+	initConfig, err := dataSourceToInitConfig(d, token)
+	if err != nil {
+		t.Fatalf("could not create initConfig from dataSource: %s", err)
+	}
+	bt := initConfig.BootstrapTokens[0]
+	if bt.TTL == nil || bt.TTL.Duration != common.DefTokenTTL {
+		t.Fatalf("Error: wrong TTL for the bootstrap token: %v", bt.TTL)
+	}
+	if bt.Expires == nil {
+		t.Fatalf("Error: no expiration time for the bootstrap token")
+	}
+
+	raw := map[string]interface{}{
+		"token": []interface{}{
+			map[string]interface{}{
+				"ttl":         "2h",
+				"usages":      []interface{}{"authentication"},
+				"groups":      []interface{}{"system:bootstrappers:workers"},
+				"description": "token for the workers",
+			},
+		},
+	}
+	d = schema.TestResourceDataRaw(t, dataSourceKubeadm().Schema, raw)
+	initConfig, err = dataSourceToInitConfig(d, token)
+	if err != nil {
+		t.Fatalf("could not create initConfig from dataSource: %s", err)
+	}
+	bt = initConfig.BootstrapTokens[0]
+	if bt.TTL == nil || bt.TTL.Duration != 2*time.Hour {
+		t.Fatalf("Error: wrong TTL for the bootstrap token: %v", bt.TTL)
+	}
+	if bt.Expires == nil || bt.Expires.Time.After(time.Now().Add(2*time.Hour)) {
+		t.Fatalf("Error: the expiration time must be computed from the TTL: %v", bt.Expires)
+	}
+	if len(bt.Usages) != 1 || bt.Usages[0] != "authentication" {
+		t.Fatalf("Error: wrong usages for the bootstrap token: %v", bt.Usages)
+	}
+	if len(bt.Groups) != 1 || bt.Groups[0] != "system:bootstrappers:workers" {
+		t.Fatalf("Error: wrong groups for the bootstrap token: %v", bt.Groups)
+	}
+	if bt.Description != "token for the workers" {
+		t.Fatalf("Error: wrong description for the bootstrap token: %q", bt.Description)
+	}
+
+	// a zero TTL must be explicitly requested for a token that never expires
+	raw["token"] = []interface{}{
+		map[string]interface{}{
+			"ttl": "0",
+		},
+	}
+	d = schema.TestResourceDataRaw(t, dataSourceKubeadm().Schema, raw)
+	initConfig, err = dataSourceToInitConfig(d, token)
+	if err != nil {
+		t.Fatalf("could not create initConfig from dataSource: %s", err)
+	}
+	bt = initConfig.BootstrapTokens[0]
+	if bt.TTL == nil || bt.TTL.Duration != 0 || bt.Expires != nil {
+		t.Fatalf("Error: the bootstrap token must never expire: %v, %v", bt.TTL, bt.Expires)
+	}
+
+	// groups must be in the "system:bootstrappers:" namespace
+	raw["token"] = []interface{}{
+		map[string]interface{}{
+			"groups": []interface{}{"some-group"},
+		},
+	}
+	d = schema.TestResourceDataRaw(t, dataSourceKubeadm().Schema, raw)
+	if _, err := dataSourceToInitConfig(d, token); err == nil {
+		t.Fatalf("Error: invalid token groups not detected")
+	}
+}
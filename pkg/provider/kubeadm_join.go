@@ -16,6 +16,7 @@ package provider
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/hashicorp/terraform/helper/schema"
 	kubeadmapi "k8s.io/kubernetes/cmd/kubeadm/app/apis/kubeadm"
@@ -25,19 +26,30 @@ import (
 )
 
 // dataSourceToJoinConfig copies some settings to a Join configuration
-func dataSourceToJoinConfig(d *schema.ResourceData, token string) (*kubeadmapi.JoinConfiguration, error) {
+// the `caCertHash` is used for pinning the CA when discovering the cluster
+func dataSourceToJoinConfig(d *schema.ResourceData, token string, caCertHash string) (*kubeadmapi.JoinConfiguration, error) {
 	joinConfig := &kubeadmapi.JoinConfiguration{
 		NodeRegistration: kubeadmapi.NodeRegistrationOptions{
-			KubeletExtraArgs: common.DefKubeletSettings,
+			// note: copy the defaults, as the map is modified later on
+			KubeletExtraArgs: common.CopyStringMap(common.DefKubeletSettings),
 		},
 		Discovery: kubeadmapi.Discovery{
 			BootstrapToken: &kubeadmapi.BootstrapTokenDiscovery{
-				Token:                    token,
-				UnsafeSkipCAVerification: true,
+				Token: token,
 			},
 		},
 	}
 
+	if d.Get("unsafe_skip_ca_verification").(bool) {
+		ssh.Debug("WARNING: CA verification disabled for discovery")
+		joinConfig.Discovery.BootstrapToken.UnsafeSkipCAVerification = true
+	} else {
+		if len(caCertHash) == 0 {
+			return nil, fmt.Errorf("no CA certificate hash available for pinning the CA")
+		}
+		joinConfig.Discovery.BootstrapToken.CACertHashes = []string{caCertHash}
+	}
+
 	if _, ok := d.GetOk("runtime.0"); ok {
 		if runtimeEngineOpt, ok := d.GetOk("runtime.0.engine"); ok {
 			if socket, ok := common.DefCriSocket[runtimeEngineOpt.(string)]; ok {
@@ -51,7 +63,9 @@ func dataSourceToJoinConfig(d *schema.ResourceData, token string) (*kubeadmapi.J
 
 		if _, ok := d.GetOk("runtime.0.extra_args.0"); ok {
 			if args, ok := d.GetOk("runtime.0.extra_args.0.kubelet"); ok {
-				joinConfig.NodeRegistration.KubeletExtraArgs = args.(map[string]string)
+				for k, v := range common.StringMap(args.(map[string]interface{})) {
+					joinConfig.NodeRegistration.KubeletExtraArgs[k] = v
+				}
 			}
 		}
 	}
@@ -74,3 +88,85 @@ func dataSourceToJoinConfig(d *schema.ResourceData, token string) (*kubeadmapi.J
 
 	return joinConfig, nil
 }
+
+// setJoinAttributes sets the computed attributes with everything needed for joining
+// the cluster without the provisioner (ie, from the user-data of machines in some
+// autoscaling group): a `join_command`, the join configurations for workers and
+// control plane machines, and the hash of the CA certificate.
+// The attributes will be empty when there is no API server address available.
+func setJoinAttributes(d *schema.ResourceData, initConfig *kubeadmapi.InitConfiguration, joinConfigBytes []byte) error {
+	attrs := map[string]string{
+		"join_command":              "",
+		"join_config":               "",
+		"join_config_control_plane": "",
+		"ca_cert_hash":              "",
+	}
+
+	joinConfig, err := common.YAMLToJoinConfig(joinConfigBytes)
+	if err != nil {
+		return err
+	}
+
+	discovery := joinConfig.Discovery.BootstrapToken
+	if len(discovery.CACertHashes) > 0 {
+		attrs["ca_cert_hash"] = discovery.CACertHashes[0]
+	}
+
+	endpoint := getAPIServerEndpoint(initConfig)
+	if len(endpoint) == 0 {
+		ssh.Debug("no API server address available: join attributes will not be generated")
+	} else {
+		discovery.APIServerEndpoint = endpoint
+		attrs["join_command"] = getJoinCommand(joinConfig)
+
+		workerConfigBytes, err := common.JoinConfigToYAML(joinConfig)
+		if err != nil {
+			return err
+		}
+		attrs["join_config"] = string(workerConfigBytes)
+
+		// additional control plane machines need a stable control plane endpoint
+		if len(initConfig.ControlPlaneEndpoint) > 0 {
+			port := initConfig.LocalAPIEndpoint.BindPort
+			if port == 0 {
+				port = common.DefAPIServerPort
+			}
+			joinConfig.ControlPlane = &kubeadmapi.JoinControlPlane{
+				LocalAPIEndpoint: kubeadmapi.APIEndpoint{BindPort: port},
+			}
+			controlPlaneConfigBytes, err := common.JoinConfigToYAML(joinConfig)
+			if err != nil {
+				return err
+			}
+			attrs["join_config_control_plane"] = string(controlPlaneConfigBytes)
+		}
+	}
+
+	for k, v := range attrs {
+		if err := d.Set(k, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getJoinCommand returns a `kubeadm join` command line for a join configuration
+func getJoinCommand(joinConfig *kubeadmapi.JoinConfiguration) string {
+	discovery := joinConfig.Discovery.BootstrapToken
+
+	args := []string{
+		"kubeadm join",
+		discovery.APIServerEndpoint,
+		fmt.Sprintf("--token=%s", discovery.Token),
+	}
+	if discovery.UnsafeSkipCAVerification {
+		args = append(args, "--discovery-token-unsafe-skip-ca-verification")
+	}
+	for _, hash := range discovery.CACertHashes {
+		args = append(args, fmt.Sprintf("--discovery-token-ca-cert-hash=%s", hash))
+	}
+	if len(joinConfig.NodeRegistration.CRISocket) > 0 {
+		args = append(args, fmt.Sprintf("--cri-socket=%s", joinConfig.NodeRegistration.CRISocket))
+	}
+	return strings.Join(args, " ")
+}
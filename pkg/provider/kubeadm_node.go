@@ -0,0 +1,189 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/hashicorp/terraform/helper/schema"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientset "k8s.io/client-go/kubernetes"
+	kubeconfigutil "k8s.io/kubernetes/cmd/kubeadm/app/util/kubeconfig"
+
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
+	"github.com/inercia/terraform-provider-kubeadm/pkg/provisioner"
+)
+
+// resourceKubeadmNodeCreate provisions a machine and adds it to the cluster
+func resourceKubeadmNodeCreate(d *schema.ResourceData, meta interface{}) error {
+	nodename, err := provisioner.CreateNode(d, getConnInfoFromResourceData(d))
+	if err != nil {
+		return err
+	}
+
+	if len(nodename) > 0 {
+		d.SetId(nodename)
+		if err := d.Set("nodename", nodename); err != nil {
+			return err
+		}
+	} else {
+		// etcd members are not Kubernetes nodes: use the address of the machine
+		d.SetId(d.Get("ssh.0.host").(string))
+	}
+
+	return resourceKubeadmNodeRead(d, meta)
+}
+
+// resourceKubeadmNodeRead reads the Node from the cluster, forgetting about
+// it when it has been removed from the cluster
+func resourceKubeadmNodeRead(d *schema.ResourceData, meta interface{}) error {
+	// etcd members are not Kubernetes nodes
+	if d.Get("role").(string) == "etcd" {
+		return nil
+	}
+
+	client, err := getClientFromNodeResourceData(d)
+	if err != nil {
+		return err
+	}
+
+	node, err := getNode(client, d.Id())
+	if err != nil {
+		return err
+	}
+	if node == nil {
+		ssh.Debug("node %q not found in the cluster: removing from state", d.Id())
+		d.SetId("")
+		return nil
+	}
+
+	return setNodeAttributes(d, node)
+}
+
+// resourceKubeadmNodeUpdate upgrades the node when the Kubernetes version in
+// the `config` changes, and updates the labels, taints and kubelet arguments
+// of the node (as well as the control plane taint when the `schedulable_masters`
+// changes). Any other change is only used in future operations on the node.
+func resourceKubeadmNodeUpdate(d *schema.ResourceData, meta interface{}) error {
+	if d.HasChange("config.kube_version") {
+		if err := provisioner.UpgradeNode(d, getConnInfoFromResourceData(d)); err != nil {
+			return err
+		}
+	}
+
+	if d.HasChange("labels") || d.HasChange("taints") || d.HasChange("kubelet_args") || d.HasChange("config.schedulable_masters") {
+		if err := provisioner.UpdateNode(d, getConnInfoFromResourceData(d), d.Id()); err != nil {
+			return err
+		}
+	}
+
+	return resourceKubeadmNodeRead(d, meta)
+}
+
+// resourceKubeadmNodeDelete drains the node and removes it from the cluster
+func resourceKubeadmNodeDelete(d *schema.ResourceData, meta interface{}) error {
+	if err := provisioner.DeleteNode(d, getConnInfoFromResourceData(d)); err != nil {
+		return err
+	}
+
+	d.SetId("")
+	return nil
+}
+
+// getConnInfoFromResourceData returns the connection info for the
+// SSH communicator from the `ssh` block
+func getConnInfoFromResourceData(d *schema.ResourceData) map[string]string {
+	connInfo := map[string]string{
+		"type": "ssh",
+		"port": strconv.Itoa(d.Get("ssh.0.port").(int)),
+	}
+	for _, key := range []string{"host", "user", "password", "private_key", "timeout", "bastion_host", "bastion_user", "bastion_private_key"} {
+		if v, ok := d.GetOk("ssh.0." + key); ok {
+			connInfo[key] = v.(string)
+		}
+	}
+	if v, ok := d.GetOk("ssh.0.bastion_port"); ok {
+		connInfo["bastion_port"] = strconv.Itoa(v.(int))
+	}
+	// note: the communicator uses the SSH agent by default, so we must
+	//       only set it when it has been explicitly provided
+	if v, ok := d.GetOkExists("ssh.0.agent"); ok {
+		connInfo["agent"] = strconv.FormatBool(v.(bool))
+	}
+	return connInfo
+}
+
+// getClientFromNodeResourceData returns a client for the cluster, using the
+// admin kubeconfig in the `config`
+func getClientFromNodeResourceData(d *schema.ResourceData) (clientset.Interface, error) {
+	if raw, ok := d.GetOk("config.kubeconfig"); ok {
+		contents, err := common.FromTerraformSafeString(raw.(string))
+		if err != nil {
+			return nil, fmt.Errorf("could not decode the kubeconfig in 'config': %s", err)
+		}
+		return getClientFromKubeconfig(contents)
+	}
+
+	if path, ok := d.GetOk("config.config_path"); ok {
+		return kubeconfigutil.ClientSetFromFile(path.(string))
+	}
+
+	return nil, fmt.Errorf("no kubeconfig found in 'config' for accessing the cluster")
+}
+
+// getNode gets a Node from the cluster, returning `nil` when the node does not exist
+func getNode(client clientset.Interface, nodename string) (*corev1.Node, error) {
+	node, err := client.CoreV1().Nodes().Get(nodename, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("could not get node %q: %s", nodename, err)
+	}
+	return node, nil
+}
+
+// setNodeAttributes sets the `labels` and `taints` from the Node object.
+// Only the labels and taints managed by this resource are considered, as
+// Kubernetes (and other tools) add many other labels and taints to nodes.
+func setNodeAttributes(d *schema.ResourceData, node *corev1.Node) error {
+	labels := map[string]string{}
+	for key := range d.Get("labels").(map[string]interface{}) {
+		if value, ok := node.Labels[key]; ok {
+			labels[key] = value
+		}
+	}
+
+	taints := []string{}
+	for _, t := range d.Get("taints").([]interface{}) {
+		managed, err := common.ParseTaint(t.(string))
+		if err != nil {
+			return err
+		}
+		for _, taint := range node.Spec.Taints {
+			if taint.Key == managed.Key && taint.Effect == managed.Effect {
+				taints = append(taints, common.TaintToString(taint))
+			}
+		}
+	}
+
+	if err := d.Set("labels", labels); err != nil {
+		return err
+	}
+	return d.Set("taints", taints)
+}
@@ -0,0 +1,104 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package provider
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/terraform/helper/schema"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestNodeAttributes(t *testing.T) {
+	client := fake.NewSimpleClientset(&corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "worker-0",
+			Labels: map[string]string{
+				"kubernetes.io/hostname": "worker-0",
+				"pool":                   "gpu",
+			},
+		},
+		Spec: corev1.NodeSpec{
+			Taints: []corev1.Taint{
+				{Key: "dedicated", Value: "ml", Effect: corev1.TaintEffectNoSchedule},
+				{Key: "node.kubernetes.io/unreachable", Effect: corev1.TaintEffectNoExecute},
+			},
+		},
+	})
+
+	d := schema.TestResourceDataRaw(t, resourceKubeadmNode().Schema, map[string]interface{}{
+		"labels": map[string]interface{}{
+			"pool": "cpu",
+			"zone": "a",
+		},
+		"taints": []interface{}{"dedicated=gpu:NoSchedule", "spot:PreferNoSchedule"},
+	})
+
+	node, err := getNode(client, "worker-0")
+	if err != nil {
+		t.Fatalf("could not get node: %s", err)
+	}
+	if node == nil {
+		t.Fatalf("Error: node not found")
+	}
+
+	if err := setNodeAttributes(d, node); err != nil {
+		t.Fatalf("could not set node attributes: %s", err)
+	}
+
+	// only the labels and taints managed by the resource must be present
+	labels := d.Get("labels").(map[string]interface{})
+	if !reflect.DeepEqual(labels, map[string]interface{}{"pool": "gpu"}) {
+		t.Fatalf("Error: unexpected labels: %+v", labels)
+	}
+	taints := d.Get("taints").([]interface{})
+	if !reflect.DeepEqual(taints, []interface{}{"dedicated=ml:NoSchedule"}) {
+		t.Fatalf("Error: unexpected taints: %+v", taints)
+	}
+
+	node, err = getNode(client, "worker-1")
+	if err != nil {
+		t.Fatalf("could not get node: %s", err)
+	}
+	if node != nil {
+		t.Fatalf("Error: node %q should not exist", node.Name)
+	}
+}
+
+func TestNodeConnInfo(t *testing.T) {
+	d := schema.TestResourceDataRaw(t, resourceKubeadmNode().Schema, map[string]interface{}{
+		"ssh": []interface{}{
+			map[string]interface{}{
+				"host":         "10.0.0.2",
+				"user":         "ubuntu",
+				"bastion_host": "bastion.example.com",
+			},
+		},
+	})
+
+	expected := map[string]string{
+		"type":         "ssh",
+		"host":         "10.0.0.2",
+		"port":         "22",
+		"user":         "ubuntu",
+		"timeout":      "5m",
+		"bastion_host": "bastion.example.com",
+	}
+	if connInfo := getConnInfoFromResourceData(d); !reflect.DeepEqual(connInfo, expected) {
+		t.Fatalf("Error: unexpected connection info: %+v", connInfo)
+	}
+}
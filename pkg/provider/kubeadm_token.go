@@ -0,0 +1,237 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform/helper/schema"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+	kubeadmapi "k8s.io/kubernetes/cmd/kubeadm/app/apis/kubeadm"
+	tokenphase "k8s.io/kubernetes/cmd/kubeadm/app/phases/bootstraptoken/node"
+	kubeconfigutil "k8s.io/kubernetes/cmd/kubeadm/app/util/kubeconfig"
+
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
+)
+
+const (
+	// prefix for the name of the Secrets where bootstrap tokens are stored (in the "kube-system" namespace)
+	bootstrapTokenSecretPrefix = "bootstrap-token-"
+)
+
+// resourceKubeadmTokenCreate creates a new bootstrap token in the cluster
+func resourceKubeadmTokenCreate(d *schema.ResourceData, meta interface{}) error {
+	opts, err := getTokenOptions(d, "")
+	if err != nil {
+		return err
+	}
+
+	client, err := getClientFromResourceData(d)
+	if err != nil {
+		return err
+	}
+
+	token, err := createBootstrapToken(client, opts)
+	if err != nil {
+		return err
+	}
+	ssh.Debug("bootstrap token %q created", token.Token.ID)
+
+	d.SetId(token.Token.ID)
+	if err := d.Set("token", token.Token.String()); err != nil {
+		return err
+	}
+
+	return resourceKubeadmTokenRead(d, meta)
+}
+
+// resourceKubeadmTokenRead reads the bootstrap token from the cluster, forgetting
+// about it when it has been removed or it has expired
+func resourceKubeadmTokenRead(d *schema.ResourceData, meta interface{}) error {
+	client, err := getClientFromResourceData(d)
+	if err != nil {
+		return err
+	}
+
+	token, err := getBootstrapToken(client, d.Id())
+	if err != nil {
+		return err
+	}
+	if token == nil {
+		ssh.Debug("bootstrap token %q not found in the cluster: removing from state", d.Id())
+		d.SetId("")
+		return nil
+	}
+	if token.Expires != nil && time.Now().After(token.Expires.Time) {
+		ssh.Debug("bootstrap token %q has expired: removing from state", d.Id())
+		d.SetId("")
+		return nil
+	}
+
+	expires := ""
+	if token.Expires != nil {
+		expires = token.Expires.UTC().Format(time.RFC3339)
+	}
+
+	if err := d.Set("token", token.Token.String()); err != nil {
+		return err
+	}
+	if err := d.Set("expires", expires); err != nil {
+		return err
+	}
+	if err := d.Set("usages", token.Usages); err != nil {
+		return err
+	}
+	if err := d.Set("groups", token.Groups); err != nil {
+		return err
+	}
+	return d.Set("description", token.Description)
+}
+
+// resourceKubeadmTokenUpdate only needs to re-read the token, as any change
+// in the token itself forces a new token
+func resourceKubeadmTokenUpdate(d *schema.ResourceData, meta interface{}) error {
+	return resourceKubeadmTokenRead(d, meta)
+}
+
+// resourceKubeadmTokenDelete revokes the bootstrap token
+func resourceKubeadmTokenDelete(d *schema.ResourceData, meta interface{}) error {
+	client, err := getClientFromResourceData(d)
+	if err != nil {
+		return err
+	}
+
+	if err := deleteBootstrapToken(client, d.Id()); err != nil {
+		return err
+	}
+	ssh.Debug("bootstrap token %q revoked", d.Id())
+
+	d.SetId("")
+	return nil
+}
+
+// getTokenOptions returns the options for a bootstrap token from the attributes
+// found at `prefix` (ie, "token.0." for the `token` block in the kubeadm resource),
+// using the defaults for anything not specified
+func getTokenOptions(d *schema.ResourceData, prefix string) (common.TokenOptions, error) {
+	opts := common.NewTokenOptions()
+
+	if v, ok := d.GetOk(prefix + "ttl"); ok {
+		ttl, err := time.ParseDuration(v.(string))
+		if err != nil {
+			return common.TokenOptions{}, fmt.Errorf("invalid token TTL %q: %s", v.(string), err)
+		}
+		opts.TTL = ttl
+	}
+	if v, ok := d.GetOk(prefix + "usages"); ok && len(v.([]interface{})) > 0 {
+		opts.Usages = []string{}
+		for _, usage := range v.([]interface{}) {
+			opts.Usages = append(opts.Usages, usage.(string))
+		}
+	}
+	if v, ok := d.GetOk(prefix + "groups"); ok && len(v.([]interface{})) > 0 {
+		opts.Groups = []string{}
+		for _, group := range v.([]interface{}) {
+			opts.Groups = append(opts.Groups, group.(string))
+		}
+	}
+	if v, ok := d.GetOk(prefix + "description"); ok {
+		opts.Description = v.(string)
+	}
+
+	return opts, nil
+}
+
+// tokenOptionsToProvisionerConfig sets the token options in the config for the
+// provisioner, so new tokens are created with the same options
+func tokenOptionsToProvisionerConfig(opts common.TokenOptions, provConfig map[string]interface{}) {
+	provConfig["token_ttl"] = opts.TTL.String()
+	provConfig["token_usages"] = strings.Join(opts.Usages, ",")
+	provConfig["token_groups"] = strings.Join(opts.Groups, ",")
+	provConfig["token_description"] = opts.Description
+}
+
+// getClientFromResourceData returns a client for the cluster, using the
+// kubeconfig contents in `kubeconfig_raw` or the kubeconfig file in `config_path`
+func getClientFromResourceData(d *schema.ResourceData) (clientset.Interface, error) {
+	if raw, ok := d.GetOk("kubeconfig_raw"); ok {
+		return getClientFromKubeconfig([]byte(raw.(string)))
+	}
+
+	if path, ok := d.GetOk("config_path"); ok {
+		return kubeconfigutil.ClientSetFromFile(path.(string))
+	}
+
+	return nil, fmt.Errorf("either 'config_path' or 'kubeconfig_raw' must be provided for accessing the cluster")
+}
+
+// getClientFromKubeconfig returns a client for the cluster from the contents of a kubeconfig
+func getClientFromKubeconfig(contents []byte) (clientset.Interface, error) {
+	config, err := clientcmd.Load(contents)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse the kubeconfig: %s", err)
+	}
+	return kubeconfigutil.ToClientSet(config)
+}
+
+// createBootstrapToken creates a new random bootstrap token in the cluster
+func createBootstrapToken(client clientset.Interface, opts common.TokenOptions) (*kubeadmapi.BootstrapToken, error) {
+	token, err := common.GetRandomToken()
+	if err != nil {
+		return nil, err
+	}
+
+	bt, err := common.NewBootstrapTokenWithOptions(token, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tokenphase.CreateNewTokens(client, []kubeadmapi.BootstrapToken{bt}); err != nil {
+		return nil, fmt.Errorf("could not create bootstrap token: %s", err)
+	}
+	return &bt, nil
+}
+
+// getBootstrapToken gets a bootstrap token from the cluster, returning `nil`
+// when the token does not exist
+func getBootstrapToken(client clientset.Interface, id string) (*kubeadmapi.BootstrapToken, error) {
+	secret, err := client.CoreV1().Secrets(metav1.NamespaceSystem).Get(bootstrapTokenSecretPrefix+id, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("could not get bootstrap token %q: %s", id, err)
+	}
+
+	token, err := kubeadmapi.BootstrapTokenFromSecret(secret)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse bootstrap token %q: %s", id, err)
+	}
+	return token, nil
+}
+
+// deleteBootstrapToken revokes a bootstrap token, ignoring tokens that do not exist
+func deleteBootstrapToken(client clientset.Interface, id string) error {
+	err := client.CoreV1().Secrets(metav1.NamespaceSystem).Delete(bootstrapTokenSecretPrefix+id, &metav1.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("could not delete bootstrap token %q: %s", id, err)
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"testing"
+	"time"
+
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
+)
+
+func TestBootstrapTokenLifecycle(t *testing.T) {
+	client := fake.NewSimpleClientset()
+
+	opts := common.NewTokenOptions()
+	opts.TTL = 2 * time.Hour
+	opts.Description = "token for the workers"
+
+	created, err := createBootstrapToken(client, opts)
+	if err != nil {
+		t.Fatalf("could not create bootstrap token: %s", err)
+	}
+	id := created.Token.ID
+
+	token, err := getBootstrapToken(client, id)
+	if err != nil {
+		t.Fatalf("could not get bootstrap token: %s", err)
+	}
+	if token == nil {
+		t.Fatalf("Error: bootstrap token %q not found", id)
+	}
+	if token.Token.String() != created.Token.String() {
+		t.Fatalf("Error: wrong bootstrap token: %q != %q", token.Token.String(), created.Token.String())
+	}
+	if token.Description != opts.Description {
+		t.Fatalf("Error: wrong description: %q", token.Description)
+	}
+	if token.Expires == nil {
+		t.Fatalf("Error: no expiration time for the bootstrap token")
+	}
+	if remaining := time.Until(token.Expires.Time); remaining <= time.Hour || remaining > 2*time.Hour {
+		t.Fatalf("Error: wrong expiration time for the bootstrap token: %s", token.Expires)
+	}
+
+	if err := deleteBootstrapToken(client, id); err != nil {
+		t.Fatalf("could not delete bootstrap token: %s", err)
+	}
+	token, err = getBootstrapToken(client, id)
+	if err != nil {
+		t.Fatalf("could not get bootstrap token: %s", err)
+	}
+	if token != nil {
+		t.Fatalf("Error: bootstrap token %q still found after deleting it", id)
+	}
+
+	// deleting a token that does not exist is not an error
+	if err := deleteBootstrapToken(client, id); err != nil {
+		t.Fatalf("Error: deleting a missing token failed: %s", err)
+	}
+}
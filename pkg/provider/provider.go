@@ -16,13 +16,22 @@ package provider
 
 import (
 	"crypto/md5"
+	"encoding/base64"
 	"encoding/hex"
 	"fmt"
+	"io/ioutil"
+	"net"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/davecgh/go-spew/spew"
 	"github.com/hashicorp/terraform/helper/schema"
+	kubeadmapi "k8s.io/kubernetes/cmd/kubeadm/app/apis/kubeadm"
 
+	"github.com/inercia/terraform-provider-kubeadm/internal/assets"
 	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
 	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
 )
@@ -71,7 +80,14 @@ func dataSourceKubeadmDelete(d *schema.ResourceData, meta interface{}) error {
 // dataSourceKubeadmUpdate is responsible for updating things
 func dataSourceKubeadmUpdate(d *schema.ResourceData, meta interface{}) error {
 	// TODO: pass the responsability for creating the new token to the provisioner
-	return nil
+	if d.HasChange("version") || d.HasChange("kubeconfig") || d.HasChange("unsafe_skip_ca_verification") || d.HasChange("token") || d.HasChange("nodes") || d.HasChange("schedulable_masters") || d.HasChange("cert_distribution") || mustRenewAdminCredentials(d) {
+		ssh.Debug("some attributes have changed: updating configuration...")
+		if err := updateConfigForProvisioner(d); err != nil {
+			return err
+		}
+	}
+
+	return dataSourceKubeadmRead(d, meta)
 }
 
 // dataSourceKubeadmExists checks if the kubeadm configuration already exists
@@ -111,33 +127,24 @@ func createConfigForProvisioner(d *schema.ResourceData) error {
 	}
 	ssh.Debug("kubeadm token = %s", token)
 
-	ssh.Debug("creating kubeadm configuration for init and join")
 	initConfig, err := dataSourceToInitConfig(d, token)
 	if err != nil {
 		return err
 	}
-	joinConfig, err := dataSourceToJoinConfig(d, token)
+
+	// create all the certs, as we need the CA for creating the join configuration
+	certConfig, err := common.CreateCerts(d, initConfig)
 	if err != nil {
 		return err
 	}
 
-	initConfigBytes, err := common.InitConfigToYAML(initConfig)
+	initConfigBytes, joinConfigBytes, err := createKubeadmConfigs(d, initConfig, token, certConfig["ca_crt"])
 	if err != nil {
 		return err
 	}
-	ssh.Debug("init configuration:")
-	ssh.Debug("------------------------")
-	ssh.Debug("\n%s", string(initConfigBytes))
-	ssh.Debug("------------------------")
-
-	joinConfigBytes, err := common.JoinConfigToYAML(joinConfig)
-	if err != nil {
+	if err := setJoinAttributes(d, initConfig, joinConfigBytes); err != nil {
 		return err
 	}
-	ssh.Debug("join configuration:")
-	ssh.Debug("------------------------")
-	ssh.Debug("\n%s", string(joinConfigBytes))
-	ssh.Debug("------------------------")
 
 	kubeconfig := d.Get("config_path").(string)
 
@@ -155,9 +162,29 @@ func createConfigForProvisioner(d *schema.ResourceData) error {
 		"cni_plugin_manifest": d.Get("cni.0.plugin_manifest").(string),
 		"helm_enabled":        fmt.Sprintf("%t", d.Get("helm.0.install").(bool)),
 		"dashboard_enabled":   fmt.Sprintf("%t", d.Get("dashboard.0.install").(bool)),
+		"psp_enabled":         "false",
 		"certs_dir":           initConfig.CertificatesDir,
 	}
 
+	// the options for the tokens created by the provisioner when the current one has expired
+	tokenOpts, err := getTokenOptions(d, "token.0.")
+	if err != nil {
+		return err
+	}
+	tokenOptionsToProvisionerConfig(tokenOpts, provConfig)
+
+	// the labels and taints for the nodes, depending on their role
+	nodesDefaultsToProvisionerConfig(d, provConfig)
+
+	// the key for encrypting the certificates uploaded with `kubeadm init phase upload-certs`
+	// note: we always create a key, so the distribution mode can be changed later on
+	certificateKey, err := common.GetRandomCertificateKey()
+	if err != nil {
+		return err
+	}
+	provConfig["certificate_key"] = certificateKey
+	provConfig["cert_distribution"] = d.Get("cert_distribution").(string)
+
 	if cniConfigDir, ok := d.GetOk("cni.0.conf_dir"); ok {
 		provConfig["cni_conf_dir"] = cniConfigDir.(string)
 	} else {
@@ -188,6 +215,98 @@ func createConfigForProvisioner(d *schema.ResourceData) error {
 		provConfig["flannel_image_version"] = common.DefFlannelImageVersion
 	}
 
+	provConfig["flannel_iface"] = d.Get("cni.0.flannel.0.iface").(string)
+	provConfig["flannel_iface_regex"] = d.Get("cni.0.flannel.0.iface_regex").(string)
+	provConfig["flannel_mtu"] = ""
+	if v, ok := d.GetOk("cni.0.flannel.0.mtu"); ok {
+		provConfig["flannel_mtu"] = strconv.Itoa(v.(int))
+	}
+
+	// note: the password is base64-encoded, as it is used in a Secret
+	provConfig["weave_password"] = ""
+	if v, ok := d.GetOk("cni.0.weave.0.password"); ok {
+		provConfig["weave_password"] = base64.StdEncoding.EncodeToString([]byte(v.(string)))
+	}
+	provConfig["weave_ipalloc_range"] = d.Get("cni.0.weave.0.ipalloc_range").(string)
+	provConfig["weave_mtu"] = ""
+	if v, ok := d.GetOk("cni.0.weave.0.mtu"); ok {
+		provConfig["weave_mtu"] = strconv.Itoa(v.(int))
+	}
+
+	calicoMode := common.DefCalicoMode
+	if v, ok := d.GetOk("cni.0.calico.0.mode"); ok {
+		calicoMode = strings.ToLower(v.(string))
+	}
+	calicoMTU := common.DefCalicoIPIPMTU
+	switch calicoMode {
+	case "vxlan":
+		provConfig["calico_backend"] = "vxlan"
+		provConfig["calico_ipip_mode"] = "Never"
+		provConfig["calico_vxlan_mode"] = "Always"
+		calicoMTU = common.DefCalicoVXLANMTU
+	case "ipip-crosssubnet":
+		provConfig["calico_backend"] = "bird"
+		provConfig["calico_ipip_mode"] = "CrossSubnet"
+		provConfig["calico_vxlan_mode"] = "Never"
+	default:
+		provConfig["calico_backend"] = "bird"
+		provConfig["calico_ipip_mode"] = "Always"
+		provConfig["calico_vxlan_mode"] = "Never"
+	}
+
+	if v, ok := d.GetOk("cni.0.calico.0.mtu"); ok {
+		provConfig["calico_mtu"] = strconv.Itoa(v.(int))
+	} else {
+		provConfig["calico_mtu"] = strconv.Itoa(calicoMTU)
+	}
+
+	if v, ok := d.GetOk("cni.0.calico.0.ip_autodetection_method"); ok {
+		provConfig["calico_ip_autodetection"] = v.(string)
+	} else {
+		provConfig["calico_ip_autodetection"] = common.DefCalicoIPAutodetectionMethod
+	}
+
+	if v, ok := d.GetOk("cni.0.calico.0.version"); ok {
+		provConfig["calico_version"] = v.(string)
+	} else {
+		provConfig["calico_version"] = common.DefCalicoImageVersion
+	}
+
+	if v, ok := d.GetOk("cni.0.cilium.0.version"); ok {
+		provConfig["cilium_version"] = v.(string)
+	} else {
+		provConfig["cilium_version"] = common.DefCiliumImageVersion
+	}
+
+	provConfig["cilium_kube_proxy_replacement"] = "false"
+	if strings.ToLower(d.Get("cni.0.plugin").(string)) == "cilium" && d.Get("cni.0.cilium.0.kube_proxy_replacement").(bool) {
+		ciliumVersion := provConfig["cilium_version"].(string)
+		if err := common.ValidateMinVersion(ciliumVersion, common.MinCiliumKubeProxyReplacementVersion); err != nil {
+			return fmt.Errorf("the kube-proxy replacement is not supported with Cilium %s: %s", ciliumVersion, err)
+		}
+		provConfig["cilium_kube_proxy_replacement"] = "true"
+	}
+
+	// the control plane endpoint, for components that cannot rely on
+	// kube-proxy for reaching the API server
+	if endpoint := getAPIServerEndpoint(initConfig); len(endpoint) > 0 {
+		host, port, err := net.SplitHostPort(endpoint)
+		if err != nil {
+			return err
+		}
+		provConfig["api_host"] = host
+		provConfig["api_port"] = port
+	} else if provConfig["cilium_kube_proxy_replacement"] == "true" {
+		return fmt.Errorf("the kube-proxy replacement requires a control plane endpoint: please set 'api.external' or 'api.internal'")
+	}
+
+	// the virtual IP of the control plane, announced by kube-vip in the masters
+	if vip, ok := d.GetOk("api.0.vip"); ok {
+		provConfig["api_vip"] = vip.(string)
+		provConfig["api_vip_interface"] = d.Get("api.0.vip_interface").(string)
+		provConfig["api_vip_version"] = common.DefKubeVipImageVersion
+	}
+
 	if v, ok := d.GetOk("network.0.dns.0.upstream"); ok {
 		dnsUp := v.([]interface{})
 		if len(dnsUp) > 0 {
@@ -199,11 +318,7 @@ func createConfigForProvisioner(d *schema.ResourceData) error {
 		}
 	}
 
-	if version, ok := d.GetOk("version"); ok {
-		provConfig["kube_version"] = version.(string)
-	} else {
-		provConfig["kube_version"] = common.DefKubernetesVersion
-	}
+	provConfig["kube_version"] = getKubeVersion(d)
 
 	if cloudProviderRaw, ok := d.GetOk("cloud.0.provider"); ok && len(cloudProviderRaw.(string)) > 0 {
 		cloudProvider := cloudProviderRaw.(string)
@@ -222,16 +337,64 @@ func createConfigForProvisioner(d *schema.ResourceData) error {
 		}
 	}
 
-	// create all the certs and set them in some `d.config` fields, so the provisioner
-	// can upload them to the machines in the Control Plane
-	certConfig, err := common.CreateCerts(d, initConfig)
+	if _, ok := d.GetOk("pod_security.0"); ok {
+		provConfig["psp_enabled"] = "true"
+		provConfig["psp_default_policy"] = d.Get("pod_security.0.default_policy").(string)
+	}
+
+	if _, ok := d.GetOk("audit.0"); ok {
+		auditPolicy, err := getAuditPolicy(d)
+		if err != nil {
+			return err
+		}
+		provConfig["audit_policy"] = common.ToTerraformSafeString([]byte(auditPolicy))
+
+		if webhookConfig, ok := d.GetOk("audit.0.webhook_config"); ok && len(webhookConfig.(string)) > 0 {
+			provConfig["audit_webhook_config"] = common.ToTerraformSafeString([]byte(webhookConfig.(string)))
+		}
+	}
+
+	encryptionConfig, err := createEncryptionConfig(d)
 	if err != nil {
 		return err
 	}
+	if encryptionConfig != nil {
+		provConfig["encryption_config"] = common.ToTerraformSafeString(encryptionConfig)
+	}
+
+	// the certificates for accessing an external etcd cluster
+	etcdCerts, err := getExternalEtcdCerts(d, initConfig, certConfig)
+	if err != nil {
+		return err
+	}
+	for k, v := range etcdCerts {
+		provConfig[k] = common.ToTerraformSafeString(v)
+	}
+	provConfig["etcd_managed"] = fmt.Sprintf("%t", d.Get("etcd.0.managed").(bool))
+
+	// set all the certs in some `d.config` fields, so the provisioner
+	// can upload them to the machines in the Control Plane
 	for k, v := range certConfig {
 		provConfig[k] = v
 	}
 
+	// create some admin credentials, so other providers can access the cluster
+	certsConfig := common.CertsConfig{
+		CaCrt: certConfig["ca_crt"],
+		CaKey: certConfig["ca_key"],
+	}
+	creds, err := setAdminCredentials(d, initConfig, &certsConfig)
+	if err != nil {
+		return err
+	}
+	if creds != nil {
+		// save the kubeconfig, so the provisioner does not have to download it
+		provConfig["kubeconfig"] = common.ToTerraformSafeString([]byte(creds.Kubeconfig))
+		if err := writeLocalKubeconfig(kubeconfig, creds.Kubeconfig); err != nil {
+			return err
+		}
+	}
+
 	if err = d.Set("config", provConfig); err != nil {
 		return err
 	}
@@ -251,6 +414,501 @@ func createConfigForProvisioner(d *schema.ResourceData) error {
 	return nil
 }
 
+// updateConfigForProvisioner regenerates the kubeadm configurations in the
+// config for the provisioner, preserving the token and the certificates
+// previously generated.
+func updateConfigForProvisioner(d *schema.ResourceData) error {
+	// note: the "config" could have been marked as "computed" in the
+	// plan, so we must start from the previous value
+	oldConfigRaw, _ := d.GetChange("config")
+
+	provConfig := map[string]interface{}{}
+	for k, v := range oldConfigRaw.(map[string]interface{}) {
+		provConfig[k] = v
+	}
+
+	token, ok := provConfig["token"]
+	if !ok {
+		return fmt.Errorf("no token found in previous configuration")
+	}
+
+	initConfig, err := dataSourceToInitConfig(d, token.(string))
+	if err != nil {
+		return err
+	}
+
+	caCrt, _ := provConfig["ca_crt"].(string)
+	initConfigBytes, joinConfigBytes, err := createKubeadmConfigs(d, initConfig, token.(string), caCrt)
+	if err != nil {
+		return err
+	}
+
+	if d.HasChange("version") || d.HasChange("unsafe_skip_ca_verification") {
+		provConfig["init"] = common.ToTerraformSafeString(initConfigBytes[:])
+		provConfig["join"] = common.ToTerraformSafeString(joinConfigBytes[:])
+		provConfig["kube_version"] = getKubeVersion(d)
+	}
+
+	// the join attributes are marked as "computed" (see customizeDiffJoinAttributes),
+	// so they must be set again or they would be lost
+	if hasJoinAttributesChange(d) {
+		if err := setJoinAttributes(d, initConfig, joinConfigBytes); err != nil {
+			return err
+		}
+	}
+
+	if d.HasChange("token") {
+		tokenOpts, err := getTokenOptions(d, "token.0.")
+		if err != nil {
+			return err
+		}
+		tokenOptionsToProvisionerConfig(tokenOpts, provConfig)
+	}
+
+	if d.HasChange("nodes") || d.HasChange("schedulable_masters") {
+		nodesDefaultsToProvisionerConfig(d, provConfig)
+	}
+
+	if d.HasChange("cert_distribution") {
+		// (clusters created before the distribution modes existed have no key)
+		if key, ok := provConfig["certificate_key"]; !ok || len(key.(string)) == 0 {
+			certificateKey, err := common.GetRandomCertificateKey()
+			if err != nil {
+				return err
+			}
+			provConfig["certificate_key"] = certificateKey
+		}
+		provConfig["cert_distribution"] = d.Get("cert_distribution").(string)
+	}
+
+	if d.HasChange("kubeconfig") || mustRenewAdminCredentials(d) {
+		ssh.Debug("creating new admin credentials")
+		certsConfig := common.CertsConfig{}
+		if err := certsConfig.FromMap(provConfig); err != nil {
+			return err
+		}
+		creds, err := setAdminCredentials(d, initConfig, &certsConfig)
+		if err != nil {
+			return err
+		}
+		if creds != nil {
+			provConfig["kubeconfig"] = common.ToTerraformSafeString([]byte(creds.Kubeconfig))
+			if err := writeLocalKubeconfig(provConfig["config_path"].(string), creds.Kubeconfig); err != nil {
+				return err
+			}
+		}
+	}
+
+	return d.Set("config", provConfig)
+}
+
+// nodesDefaultsToProvisionerConfig sets the labels and taints for the masters
+// and the workers (from the `nodes` block) in the config for the provisioner,
+// as well as if the masters must be schedulable
+func nodesDefaultsToProvisionerConfig(d *schema.ResourceData, provConfig map[string]interface{}) {
+	provConfig["schedulable_masters"] = fmt.Sprintf("%t", d.Get("schedulable_masters").(bool))
+
+	for _, role := range []string{"master", "worker"} {
+		labels := map[string]string{}
+		for k, v := range d.Get(fmt.Sprintf("nodes.0.%s_labels", role)).(map[string]interface{}) {
+			labels[k] = v.(string)
+		}
+		taints := []string{}
+		for _, t := range d.Get(fmt.Sprintf("nodes.0.%s_taints", role)).([]interface{}) {
+			taints = append(taints, t.(string))
+		}
+		provConfig[role+"s_labels"] = common.LabelsToString(labels)
+		provConfig[role+"s_taints"] = strings.Join(taints, ",")
+	}
+}
+
+// getExternalEtcdCerts returns the certificates for accessing an external etcd cluster:
+// the certificates provided by the user or, when the etcd members are bootstrapped
+// by the provisioner, a client certificate signed by our etcd CA
+func getExternalEtcdCerts(d *schema.ResourceData, initConfig *kubeadmapi.InitConfiguration, certConfig map[string]string) (map[string][]byte, error) {
+	if initConfig.Etcd.External == nil || len(initConfig.Etcd.External.CAFile) == 0 {
+		return nil, nil
+	}
+
+	res := map[string][]byte{}
+	if caCrt, ok := d.GetOk("etcd.0.ca_crt"); ok {
+		res["etcd_ca_crt"] = []byte(caCrt.(string))
+		if crt, ok := d.GetOk("etcd.0.client_crt"); ok {
+			res["etcd_client_crt"] = []byte(crt.(string))
+		}
+		if key, ok := d.GetOk("etcd.0.client_key"); ok {
+			res["etcd_client_key"] = []byte(key.(string))
+		}
+		return res, nil
+	}
+
+	ssh.Debug("creating a client certificate for the etcd members")
+	certsConfig := common.CertsConfig{
+		EtcdCrt: certConfig["etcd_crt"],
+		EtcdKey: certConfig["etcd_key"],
+	}
+	crt, key, err := common.CreateEtcdClientCert(&certsConfig)
+	if err != nil {
+		return nil, err
+	}
+	res["etcd_ca_crt"] = []byte(certsConfig.EtcdCrt)
+	res["etcd_client_crt"] = crt
+	res["etcd_client_key"] = key
+	return res, nil
+}
+
+// getAuditPolicy returns the audit policy: the policy provided inline,
+// the contents of the policy file or the default policy
+func getAuditPolicy(d *schema.ResourceData) (string, error) {
+	if policy, ok := d.GetOk("audit.0.policy"); ok && len(policy.(string)) > 0 {
+		return policy.(string), nil
+	}
+
+	if policyFile, ok := d.GetOk("audit.0.policy_file"); ok && len(policyFile.(string)) > 0 {
+		ssh.Debug("reading audit policy from %q", policyFile.(string))
+		contents, err := ioutil.ReadFile(policyFile.(string))
+		if err != nil {
+			return "", fmt.Errorf("could not read audit policy file %q: %s", policyFile.(string), err)
+		}
+		return string(contents), nil
+	}
+
+	ssh.Debug("using the default audit policy")
+	return assets.AuditPolicyCode, nil
+}
+
+// createEncryptionConfig creates the configuration for encrypting Secrets at rest,
+// generating a new key when no key has been provided. It returns nil if encryption is not enabled.
+func createEncryptionConfig(d *schema.ResourceData) ([]byte, error) {
+	if _, ok := d.GetOk("encryption.0"); !ok {
+		return nil, nil
+	}
+
+	provider := d.Get("encryption.0.provider").(string)
+	key := d.Get("encryption.0.key").(string)
+	if len(key) == 0 {
+		ssh.Debug("generating a random key for encrypting Secrets...")
+		newKey, err := common.NewEncryptionKey()
+		if err != nil {
+			return nil, err
+		}
+		key = newKey
+
+		encryption := map[string]interface{}{
+			"provider": provider,
+			"key":      key,
+		}
+		if err := d.Set("encryption", []interface{}{encryption}); err != nil {
+			return nil, err
+		}
+	}
+
+	return common.CreateEncryptionConfig(provider, key)
+}
+
+// createKubeadmConfigs creates the kubeadm configuration for join, returning
+// the YAML serialization of the (given) init configuration and the join configuration.
+// The CA certificate is used for pinning the CA in the join configuration.
+func createKubeadmConfigs(d *schema.ResourceData, initConfig *kubeadmapi.InitConfiguration, token string, caCrt string) ([]byte, []byte, error) {
+	ssh.Debug("creating kubeadm configuration for init and join")
+	var err error
+
+	caCertHash := ""
+	if len(caCrt) > 0 {
+		caCertHash, err = common.CACertHash([]byte(caCrt))
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	joinConfig, err := dataSourceToJoinConfig(d, token, caCertHash)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	initConfigBytes, err := common.InitConfigToYAML(initConfig)
+	if err != nil {
+		return nil, nil, err
+	}
+	ssh.Debug("init configuration:")
+	ssh.Debug("------------------------")
+	ssh.Debug("\n%s", string(initConfigBytes))
+	ssh.Debug("------------------------")
+
+	joinConfigBytes, err := common.JoinConfigToYAML(joinConfig)
+	if err != nil {
+		return nil, nil, err
+	}
+	ssh.Debug("join configuration:")
+	ssh.Debug("------------------------")
+	ssh.Debug("\n%s", string(joinConfigBytes))
+	ssh.Debug("------------------------")
+
+	return initConfigBytes, joinConfigBytes, nil
+}
+
+// setAdminCredentials creates some credentials for accessing the API server with
+// administrative privileges and sets them in the computed attributes of the resource
+func setAdminCredentials(d *schema.ResourceData, initConfig *kubeadmapi.InitConfiguration, certsConfig *common.CertsConfig) (*common.AdminCredentials, error) {
+	server := getAPIServerURL(initConfig)
+	if len(server) == 0 {
+		ssh.Debug("no API server address available: admin credentials will not be generated")
+		return nil, nil
+	}
+
+	opts, err := getAdminCredentialsOptions(d)
+	if err != nil {
+		return nil, err
+	}
+
+	ssh.Debug("creating admin credentials for %q (user:%s, groups:%v, validity:%s)", server, opts.User, opts.Groups, opts.Validity)
+	creds, err := common.CreateAdminCredentials(certsConfig, server, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	attrs := map[string]string{
+		"host":                   creds.Server,
+		"cluster_ca_certificate": creds.CaCrt,
+		"client_certificate":     creds.ClientCrt,
+		"client_key":             creds.ClientKey,
+		"kubeconfig_raw":         creds.Kubeconfig,
+	}
+	for k, v := range attrs {
+		if err := d.Set(k, v); err != nil {
+			return nil, err
+		}
+	}
+	return creds, nil
+}
+
+// getAdminCredentialsOptions returns the options for the admin credentials from the `kubeconfig` block
+func getAdminCredentialsOptions(d *schema.ResourceData) (common.AdminCredentialsOptions, error) {
+	opts := common.NewAdminCredentialsOptions()
+
+	if user, ok := d.GetOk("kubeconfig.0.user"); ok && len(user.(string)) > 0 {
+		opts.User = user.(string)
+	}
+
+	if groupsRaw, ok := d.GetOk("kubeconfig.0.groups"); ok {
+		groups := []string{}
+		for _, group := range groupsRaw.([]interface{}) {
+			groups = append(groups, group.(string))
+		}
+		if len(groups) > 0 {
+			opts.Groups = groups
+		}
+	}
+
+	if validity, ok := d.GetOk("kubeconfig.0.validity"); ok && len(validity.(string)) > 0 {
+		duration, err := time.ParseDuration(validity.(string))
+		if err != nil {
+			return opts, err
+		}
+		opts.Validity = duration
+	}
+
+	return opts, nil
+}
+
+// writeLocalKubeconfig writes the kubeconfig in the local `path`, doing
+// a backup of any previous file
+func writeLocalKubeconfig(path string, kubeconfig string) error {
+	if len(path) == 0 {
+		return nil
+	}
+
+	if _, err := os.Stat(path); err == nil {
+		ssh.Debug("backing up previous kubeconfig file %q", path)
+		if err := os.Rename(path, path+".bak"); err != nil {
+			return err
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	ssh.Debug("writing kubeconfig to %q", path)
+	return ioutil.WriteFile(path, []byte(kubeconfig), 0600)
+}
+
+// getAPIServerEndpoint returns the "host:port" for accessing the API server: the
+// external address (when provided) or the address of the seeder
+func getAPIServerEndpoint(initConfig *kubeadmapi.InitConfiguration) string {
+	if len(initConfig.ControlPlaneEndpoint) > 0 {
+		return initConfig.ControlPlaneEndpoint
+	}
+
+	if len(initConfig.LocalAPIEndpoint.AdvertiseAddress) > 0 {
+		port := int(initConfig.LocalAPIEndpoint.BindPort)
+		if port == 0 {
+			port = common.DefAPIServerPort
+		}
+		return net.JoinHostPort(initConfig.LocalAPIEndpoint.AdvertiseAddress, strconv.Itoa(port))
+	}
+
+	return ""
+}
+
+// getAPIServerURL returns the URL for accessing the API server
+func getAPIServerURL(initConfig *kubeadmapi.InitConfiguration) string {
+	if endpoint := getAPIServerEndpoint(initConfig); len(endpoint) > 0 {
+		return fmt.Sprintf("https://%s", endpoint)
+	}
+	return ""
+}
+
+// getKubeVersion returns the Kubernetes version for the cluster
+func getKubeVersion(d *schema.ResourceData) string {
+	if version, ok := d.GetOk("version"); ok && len(version.(string)) > 0 {
+		return version.(string)
+	}
+	return common.DefKubernetesVersion
+}
+
+// customizeDiffVersion validates a change in the Kubernetes version and,
+// in that case, marks the config as "computed", as it will be regenerated.
+func customizeDiffVersion(d *schema.ResourceDiff, meta interface{}) error {
+	if d.Id() == "" || !d.HasChange("version") {
+		return nil
+	}
+
+	oldVersion, newVersion := d.GetChange("version")
+	if err := common.ValidateVersionUpgrade(oldVersion.(string), newVersion.(string)); err != nil {
+		return err
+	}
+
+	ssh.Debug("Kubernetes version will change from %s to %s", oldVersion.(string), newVersion.(string))
+	return customizeDiffJoinAttributes(d)
+}
+
+// customizeDiffDiscovery marks the config (and the join attributes) as "computed"
+// when the CA verification changes, as the join configuration will be regenerated.
+func customizeDiffDiscovery(d *schema.ResourceDiff, meta interface{}) error {
+	if d.Id() == "" || !d.HasChange("unsafe_skip_ca_verification") {
+		return nil
+	}
+	return customizeDiffJoinAttributes(d)
+}
+
+// customizeDiffToken marks the config (and the join attributes) as "computed"
+// when the options for the bootstrap tokens change, as they are passed to the
+// provisioner.
+func customizeDiffToken(d *schema.ResourceDiff, meta interface{}) error {
+	if d.Id() == "" || !d.HasChange("token") {
+		return nil
+	}
+	return customizeDiffJoinAttributes(d)
+}
+
+// customizeDiffNodes marks the config (and the join attributes) as "computed"
+// when the defaults for the nodes (or the schedulable masters) change, as they
+// are passed to the provisioner.
+func customizeDiffNodes(d *schema.ResourceDiff, meta interface{}) error {
+	if d.Id() == "" || !(d.HasChange("nodes") || d.HasChange("schedulable_masters")) {
+		return nil
+	}
+	return customizeDiffJoinAttributes(d)
+}
+
+// joinAttributes are the attributes that are regenerated in an Update
+// that changes the "config"
+var joinAttributes = []string{
+	"config",
+	"join_command",
+	"join_config",
+	"join_config_control_plane",
+	"ca_cert_hash",
+}
+
+// customizeDiffJoinAttributes marks all the joinAttributes as "computed"
+func customizeDiffJoinAttributes(d *schema.ResourceDiff) error {
+	for _, k := range joinAttributes {
+		if err := d.SetNewComputed(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// hasJoinAttributesChange returns true when any of the changes that
+// mark the joinAttributes as "computed" is present
+func hasJoinAttributesChange(d *schema.ResourceData) bool {
+	for _, k := range []string{"version", "unsafe_skip_ca_verification", "token", "nodes", "schedulable_masters"} {
+		if d.HasChange(k) {
+			return true
+		}
+	}
+	return false
+}
+
+// customizeDiffCertDistribution validates the certificates distribution mode and,
+// when it changes, marks the config as "computed", as it is passed to the provisioner.
+func customizeDiffCertDistribution(d *schema.ResourceDiff, meta interface{}) error {
+	if d.Get("cert_distribution").(string) == "kubeadm" {
+		kubeVersion := common.DefKubernetesVersion
+		if v, ok := d.GetOk("version"); ok && len(v.(string)) > 0 {
+			kubeVersion = v.(string)
+		}
+		if err := common.ValidateMinVersion(kubeVersion, common.MinKubeadmCertDistributionVersion); err != nil {
+			return fmt.Errorf("'cert_distribution = \"kubeadm\"' cannot be used: %s. Please use the \"ssh\" mode", err)
+		}
+	}
+
+	if d.Id() == "" || !d.HasChange("cert_distribution") {
+		return nil
+	}
+	return d.SetNewComputed("config")
+}
+
+// customizeDiffKubeconfig marks the admin credentials as "computed" when
+// the options for the kubeconfig change or when the client certificate
+// is close to its expiration, as they will be regenerated.
+func customizeDiffKubeconfig(d *schema.ResourceDiff, meta interface{}) error {
+	if d.Id() == "" {
+		return nil
+	}
+	if !d.HasChange("kubeconfig") && !adminCertExpiresSoon(d.Get("client_certificate").(string), d.Get("kubeconfig.0.validity").(string)) {
+		return nil
+	}
+
+	ssh.Debug("kubeconfig options have changed (or the client certificate is about to expire): admin credentials will be regenerated")
+	for _, k := range []string{"config", "client_certificate", "client_key", "kubeconfig_raw"} {
+		if err := d.SetNewComputed(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// adminCertExpiresSoon returns true if the client certificate in the admin kubeconfig
+// must be renewed: when it expires in less than common.DefAdminCertRenewBefore (or a
+// third of the `validity`, for shorter validities)
+func adminCertExpiresSoon(crt string, validity string) bool {
+	if len(crt) == 0 {
+		return false
+	}
+	renewBefore := common.DefAdminCertRenewBefore
+	if v, err := time.ParseDuration(validity); err == nil && v/3 < renewBefore {
+		renewBefore = v / 3
+	}
+	renew, err := common.CertExpiresWithin([]byte(crt), renewBefore)
+	if err != nil {
+		ssh.Debug("could not check the expiration of the admin client certificate: %s", err)
+		return false
+	}
+	return renew
+}
+
+// mustRenewAdminCredentials returns true if the admin credentials must be
+// regenerated because the previous client certificate is about to expire
+// (note: the certificate is "computed" in the plan, so we must check the previous value)
+func mustRenewAdminCredentials(d *schema.ResourceData) bool {
+	oldCrt, _ := d.GetChange("client_certificate")
+	oldValidity, _ := d.GetChange("kubeconfig.0.validity")
+	return adminCertExpiresSoon(oldCrt.(string), oldValidity.(string))
+}
+
 // dataSourceVerify verifies the config
 func dataSourceVerify(d *schema.ResourceData) error {
 	ssh.Debug("verifying configuration...")
@@ -17,28 +17,690 @@ package provider
 import (
 	"fmt"
 	"log"
+	"regexp"
 	"testing"
+	"time"
 
 	"github.com/hashicorp/terraform/helper/resource"
 	"github.com/hashicorp/terraform/terraform"
+	certutil "k8s.io/client-go/util/cert"
+	"k8s.io/client-go/util/keyutil"
+	"k8s.io/kubernetes/cmd/kubeadm/app/util/pkiutil"
+
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
 )
 
-func TestKubeadm_basic(t *testing.T) {
-	const testAccKubeadm_basic = `
+func TestKubeadm_basic(t *testing.T) {
+	const testAccKubeadm_basic = `
+        resource "kubeadm" "k8s" {
+        	config_path = "/tmp/kubeconfig"
+        	
+        	network {
+        		services = "10.25.0.0/16"
+        	}
+        	
+            api {
+              external = "loadbalancer.external.com"
+            }
+	
+	        cni {
+				plugin = "flannel"
+	        }
+        }`
+
+	resource.UnitTest(t, resource.TestCase{
+		PreCheck:  func() { testAccPreCheck(t) },
+		Providers: testAccProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccKubeadm_basic,
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckState("kubeadm.k8s"),
+					resource.TestCheckResourceAttr("kubeadm.k8s",
+						"config.config_path",
+						"/tmp/kubeconfig"),
+					resource.TestCheckResourceAttr("kubeadm.k8s",
+						"config.cni_plugin",
+						"flannel"),
+					resource.TestCheckResourceAttrSet("kubeadm.k8s",
+						"config.init"),
+					resource.TestCheckResourceAttrSet("kubeadm.k8s",
+						"config.join"),
+					resource.TestCheckResourceAttr("kubeadm.k8s",
+						"host",
+						"https://loadbalancer.external.com:6443"),
+					resource.TestCheckResourceAttrSet("kubeadm.k8s",
+						"cluster_ca_certificate"),
+					resource.TestCheckResourceAttrSet("kubeadm.k8s",
+						"client_certificate"),
+					resource.TestCheckResourceAttrSet("kubeadm.k8s",
+						"client_key"),
+					resource.TestCheckResourceAttrSet("kubeadm.k8s",
+						"kubeconfig_raw"),
+					testAccCheckJoinDiscovery("kubeadm.k8s", true),
+				),
+			},
+		},
+	})
+}
+
+func TestKubeadm_encryption(t *testing.T) {
+	const testAccKubeadm_encryption = `
+        resource "kubeadm" "k8s" {
+            config_path = "/tmp/kubeconfig"
+
+            api {
+              external = "loadbalancer.external.com"
+            }
+
+            encryption {
+              provider = "secretbox"
+            }
+        }`
+
+	resource.UnitTest(t, resource.TestCase{
+		PreCheck:  func() { testAccPreCheck(t) },
+		Providers: testAccProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccKubeadm_encryption,
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckState("kubeadm.k8s"),
+					resource.TestCheckResourceAttr("kubeadm.k8s",
+						"encryption.0.provider",
+						"secretbox"),
+					resource.TestCheckResourceAttrSet("kubeadm.k8s",
+						"encryption.0.key"),
+					resource.TestCheckResourceAttrSet("kubeadm.k8s",
+						"config.encryption_config"),
+				),
+			},
+		},
+	})
+}
+
+func TestKubeadm_audit(t *testing.T) {
+	const testAccKubeadm_audit = `
+        resource "kubeadm" "k8s" {
+            config_path = "/tmp/kubeconfig"
+
+            api {
+              external = "loadbalancer.external.com"
+            }
+
+            audit {
+              max_age = 7
+            }
+        }`
+
+	resource.UnitTest(t, resource.TestCase{
+		PreCheck:  func() { testAccPreCheck(t) },
+		Providers: testAccProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccKubeadm_audit,
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckState("kubeadm.k8s"),
+					resource.TestCheckResourceAttrSet("kubeadm.k8s",
+						"config.audit_policy"),
+					resource.TestCheckNoResourceAttr("kubeadm.k8s",
+						"config.audit_webhook_config"),
+				),
+			},
+		},
+	})
+}
+
+func TestKubeadm_podSecurity(t *testing.T) {
+	const testAccKubeadm_podSecurity = `
+        resource "kubeadm" "k8s" {
+            config_path = "/tmp/kubeconfig"
+
+            api {
+              external = "loadbalancer.external.com"
+            }
+
+            pod_security {
+              default_policy = "privileged"
+            }
+        }`
+
+	resource.UnitTest(t, resource.TestCase{
+		PreCheck:  func() { testAccPreCheck(t) },
+		Providers: testAccProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccKubeadm_podSecurity,
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckState("kubeadm.k8s"),
+					resource.TestCheckResourceAttr("kubeadm.k8s",
+						"config.psp_enabled",
+						"true"),
+					resource.TestCheckResourceAttr("kubeadm.k8s",
+						"config.psp_default_policy",
+						"privileged"),
+				),
+			},
+		},
+	})
+}
+
+func TestKubeadm_flannel(t *testing.T) {
+	const testAccKubeadm_flannel = `
+        resource "kubeadm" "k8s" {
+            config_path = "/tmp/kubeconfig"
+
+            cni {
+              plugin = "flannel"
+
+              flannel {
+                version     = "v0.11.0"
+                iface_regex = "^eth1|^ens"
+                mtu         = 1400
+              }
+            }
+        }`
+
+	resource.UnitTest(t, resource.TestCase{
+		PreCheck:  func() { testAccPreCheck(t) },
+		Providers: testAccProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccKubeadm_flannel,
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckState("kubeadm.k8s"),
+					resource.TestCheckResourceAttr("kubeadm.k8s",
+						"config.flannel_image_version",
+						"v0.11.0"),
+					resource.TestCheckResourceAttr("kubeadm.k8s",
+						"config.flannel_backend",
+						common.DefFlannelBackend),
+					resource.TestCheckResourceAttr("kubeadm.k8s",
+						"config.flannel_iface",
+						""),
+					resource.TestCheckResourceAttr("kubeadm.k8s",
+						"config.flannel_iface_regex",
+						"^eth1|^ens"),
+					resource.TestCheckResourceAttr("kubeadm.k8s",
+						"config.flannel_mtu",
+						"1400"),
+				),
+			},
+		},
+	})
+}
+
+func TestKubeadm_weave(t *testing.T) {
+	const testAccKubeadm_weave = `
+        resource "kubeadm" "k8s" {
+            config_path = "/tmp/kubeconfig"
+
+            cni {
+              plugin = "weave"
+
+              weave {
+                password      = "some-secret"
+                ipalloc_range = "10.244.0.0/16"
+              }
+            }
+        }`
+
+	resource.UnitTest(t, resource.TestCase{
+		PreCheck:  func() { testAccPreCheck(t) },
+		Providers: testAccProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccKubeadm_weave,
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckState("kubeadm.k8s"),
+					resource.TestCheckResourceAttr("kubeadm.k8s",
+						"config.weave_password",
+						"c29tZS1zZWNyZXQ="),
+					resource.TestCheckResourceAttr("kubeadm.k8s",
+						"config.weave_ipalloc_range",
+						"10.244.0.0/16"),
+					resource.TestCheckResourceAttr("kubeadm.k8s",
+						"config.weave_mtu",
+						""),
+				),
+			},
+		},
+	})
+}
+
+func TestKubeadm_calico(t *testing.T) {
+	const testAccKubeadm_calico = `
+        resource "kubeadm" "k8s" {
+            config_path = "/tmp/kubeconfig"
+
+            network {
+              pods = "192.168.0.0/16"
+            }
+
+            cni {
+              plugin = "calico"
+
+              calico {
+                mode = "vxlan"
+                mtu  = 1410
+              }
+            }
+        }`
+
+	const testAccKubeadm_calicoDefaultMTU = `
+        resource "kubeadm" "vxlan" {
+            config_path = "/tmp/kubeconfig"
+
+            cni {
+              plugin = "calico"
+
+              calico {
+                mode = "vxlan"
+              }
+            }
+        }
+
+        resource "kubeadm" "ipip" {
+            config_path = "/tmp/kubeconfig"
+
+            cni {
+              plugin = "calico"
+
+              calico {
+                mode = "ipip"
+              }
+            }
+        }`
+
+	resource.UnitTest(t, resource.TestCase{
+		PreCheck:  func() { testAccPreCheck(t) },
+		Providers: testAccProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccKubeadm_calico,
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckState("kubeadm.k8s"),
+					resource.TestCheckResourceAttr("kubeadm.k8s",
+						"config.cni_plugin",
+						"calico"),
+					resource.TestCheckResourceAttr("kubeadm.k8s",
+						"config.cni_pod_cidr",
+						"192.168.0.0/16"),
+					resource.TestCheckResourceAttr("kubeadm.k8s",
+						"config.calico_backend",
+						"vxlan"),
+					resource.TestCheckResourceAttr("kubeadm.k8s",
+						"config.calico_ipip_mode",
+						"Never"),
+					resource.TestCheckResourceAttr("kubeadm.k8s",
+						"config.calico_vxlan_mode",
+						"Always"),
+					resource.TestCheckResourceAttr("kubeadm.k8s",
+						"config.calico_mtu",
+						"1410"),
+					resource.TestCheckResourceAttr("kubeadm.k8s",
+						"config.calico_ip_autodetection",
+						common.DefCalicoIPAutodetectionMethod),
+				),
+			},
+			{
+				Config: testAccKubeadm_calicoDefaultMTU,
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckState("kubeadm.vxlan"),
+					resource.TestCheckResourceAttr("kubeadm.vxlan",
+						"config.calico_mtu",
+						"1410"),
+					testAccCheckState("kubeadm.ipip"),
+					resource.TestCheckResourceAttr("kubeadm.ipip",
+						"config.calico_mtu",
+						"1440"),
+				),
+			},
+		},
+	})
+}
+
+func TestKubeadm_cilium(t *testing.T) {
+	const testAccKubeadm_cilium = `
+        resource "kubeadm" "k8s" {
+            config_path = "/tmp/kubeconfig"
+
+            api {
+              external = "loadbalancer.external.com"
+            }
+
+            cni {
+              plugin = "cilium"
+
+              cilium {
+                kube_proxy_replacement = true
+              }
+            }
+        }`
+
+	const testAccKubeadm_ciliumNoEndpoint = `
+        resource "kubeadm" "k8s" {
+            config_path = "/tmp/kubeconfig"
+
+            cni {
+              plugin = "cilium"
+
+              cilium {
+                kube_proxy_replacement = true
+              }
+            }
+        }`
+
+	const testAccKubeadm_ciliumOldVersion = `
+        resource "kubeadm" "k8s" {
+            config_path = "/tmp/kubeconfig"
+
+            api {
+              external = "loadbalancer.external.com"
+            }
+
+            cni {
+              plugin = "cilium"
+
+              cilium {
+                version                = "v1.6.5"
+                kube_proxy_replacement = true
+              }
+            }
+        }`
+
+	resource.UnitTest(t, resource.TestCase{
+		PreCheck:  func() { testAccPreCheck(t) },
+		Providers: testAccProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccKubeadm_cilium,
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckState("kubeadm.k8s"),
+					resource.TestCheckResourceAttr("kubeadm.k8s",
+						"config.cni_plugin",
+						"cilium"),
+					resource.TestCheckResourceAttr("kubeadm.k8s",
+						"config.cilium_kube_proxy_replacement",
+						"true"),
+					resource.TestCheckResourceAttr("kubeadm.k8s",
+						"config.cilium_version",
+						common.DefCiliumImageVersion),
+					resource.TestCheckResourceAttr("kubeadm.k8s",
+						"config.api_host",
+						"loadbalancer.external.com"),
+					resource.TestCheckResourceAttr("kubeadm.k8s",
+						"config.api_port",
+						"6443"),
+				),
+			},
+		},
+	})
+
+	resource.UnitTest(t, resource.TestCase{
+		PreCheck:  func() { testAccPreCheck(t) },
+		Providers: testAccProviders,
+		Steps: []resource.TestStep{
+			{
+				Config:      testAccKubeadm_ciliumNoEndpoint,
+				ExpectError: regexp.MustCompile("requires a control plane endpoint"),
+			},
+		},
+	})
+
+	resource.UnitTest(t, resource.TestCase{
+		PreCheck:  func() { testAccPreCheck(t) },
+		Providers: testAccProviders,
+		Steps: []resource.TestStep{
+			{
+				Config:      testAccKubeadm_ciliumOldVersion,
+				ExpectError: regexp.MustCompile("not supported with Cilium v1.6.5"),
+			},
+		},
+	})
+}
+
+func TestKubeadm_externalEtcd(t *testing.T) {
+	const testAccKubeadm_externalEtcd = `
+        resource "kubeadm" "k8s" {
+            config_path = "/tmp/kubeconfig"
+
+            etcd {
+              endpoints = ["https://10.0.0.1:2379", "https://10.0.0.2:2379", "https://10.0.0.3:2379"]
+              managed   = true
+            }
+        }
+
+        resource "kubeadm" "unmanaged" {
+            config_path = "/tmp/kubeconfig"
+
+            etcd {
+              endpoints = ["https://10.0.0.1:2379"]
+            }
+        }`
+
+	resource.UnitTest(t, resource.TestCase{
+		PreCheck:  func() { testAccPreCheck(t) },
+		Providers: testAccProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccKubeadm_externalEtcd,
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckState("kubeadm.k8s"),
+					// the client certificate for the API server is signed by our etcd CA
+					testAccCheckExternalEtcdCA("kubeadm.k8s"),
+					resource.TestCheckResourceAttrSet("kubeadm.k8s",
+						"config.etcd_client_crt"),
+					resource.TestCheckResourceAttrSet("kubeadm.k8s",
+						"config.etcd_client_key"),
+					resource.TestCheckResourceAttr("kubeadm.k8s",
+						"config.etcd_managed",
+						"true"),
+					// no certificates are generated for an etcd cluster we do not manage
+					testAccCheckState("kubeadm.unmanaged"),
+					resource.TestCheckNoResourceAttr("kubeadm.unmanaged",
+						"config.etcd_client_crt"),
+					resource.TestCheckResourceAttr("kubeadm.unmanaged",
+						"config.etcd_managed",
+						"false"),
+				),
+			},
+		},
+	})
+}
+
+// testAccCheckExternalEtcdCA checks the CA for the external etcd is our etcd CA
+func testAccCheckExternalEtcdCA(id string) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		rs, ok := s.RootModule().Resources[id]
+		if !ok {
+			return fmt.Errorf("Not found: %s", id)
+		}
+
+		etcdCACrt, err := common.FromTerraformSafeString(rs.Primary.Attributes["config.etcd_ca_crt"])
+		if err != nil {
+			return err
+		}
+		if string(etcdCACrt) != rs.Primary.Attributes["config.etcd_crt"] {
+			return fmt.Errorf("the CA for the external etcd is not the etcd CA")
+		}
+		return nil
+	}
+}
+
+func TestKubeadm_unsafeDiscovery(t *testing.T) {
+	const testAccKubeadm_unsafeDiscovery = `
+        resource "kubeadm" "k8s" {
+            config_path = "/tmp/kubeconfig"
+
+            api {
+              external = "loadbalancer.external.com"
+            }
+
+            unsafe_skip_ca_verification = true
+        }`
+
+	resource.UnitTest(t, resource.TestCase{
+		PreCheck:  func() { testAccPreCheck(t) },
+		Providers: testAccProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccKubeadm_unsafeDiscovery,
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckState("kubeadm.k8s"),
+					testAccCheckJoinDiscovery("kubeadm.k8s", false),
+					resource.TestMatchResourceAttr("kubeadm.k8s",
+						"join_command",
+						regexp.MustCompile("--discovery-token-unsafe-skip-ca-verification")),
+				),
+			},
+		},
+	})
+}
+
+func TestKubeadm_joinAttributes(t *testing.T) {
+	const testAccKubeadm_join = `
+        resource "kubeadm" "k8s" {
+            config_path = "/tmp/kubeconfig"
+
+            api {
+              external = "loadbalancer.external.com"
+            }
+        }`
+
+	const testAccKubeadm_joinUpdate = `
+        resource "kubeadm" "k8s" {
+            config_path = "/tmp/kubeconfig"
+
+            api {
+              external = "loadbalancer.external.com"
+            }
+
+            token {
+              ttl = "2h"
+            }
+        }`
+
+	resource.UnitTest(t, resource.TestCase{
+		PreCheck:  func() { testAccPreCheck(t) },
+		Providers: testAccProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccKubeadm_join,
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckState("kubeadm.k8s"),
+					resource.TestMatchResourceAttr("kubeadm.k8s",
+						"ca_cert_hash",
+						regexp.MustCompile("^sha256:[0-9a-f]{64}$")),
+					resource.TestMatchResourceAttr("kubeadm.k8s",
+						"join_command",
+						regexp.MustCompile(`^kubeadm join loadbalancer.external.com:6443 --token=`+common.TokenRegex+` --discovery-token-ca-cert-hash=sha256:[0-9a-f]{64}$`)),
+					resource.TestMatchResourceAttr("kubeadm.k8s",
+						"join_config",
+						regexp.MustCompile("apiServerEndpoint: loadbalancer.external.com:6443")),
+					resource.TestMatchResourceAttr("kubeadm.k8s",
+						"join_config_control_plane",
+						regexp.MustCompile("controlPlane:")),
+				),
+			},
+			{
+				// the join attributes must be preserved when the config is updated
+				Config: testAccKubeadm_joinUpdate,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("kubeadm.k8s",
+						"config.token_ttl",
+						"2h0m0s"),
+					resource.TestMatchResourceAttr("kubeadm.k8s",
+						"ca_cert_hash",
+						regexp.MustCompile("^sha256:[0-9a-f]{64}$")),
+					resource.TestMatchResourceAttr("kubeadm.k8s",
+						"join_command",
+						regexp.MustCompile(`^kubeadm join loadbalancer.external.com:6443 --token=`+common.TokenRegex)),
+					resource.TestMatchResourceAttr("kubeadm.k8s",
+						"join_config",
+						regexp.MustCompile("apiServerEndpoint: loadbalancer.external.com:6443")),
+				),
+			},
+		},
+	})
+}
+
+func TestKubeadm_vip(t *testing.T) {
+	const testAccKubeadm_vip = `
         resource "kubeadm" "k8s" {
-        	config_path = "/tmp/kubeconfig"
-        	
-        	network {
-        		services = "10.25.0.0/16"
-        	}
-        	
+            config_path = "/tmp/kubeconfig"
+
+            api {
+              vip           = "10.0.0.100"
+              vip_interface = "ens3"
+            }
+        }
+
+        data "kubeadm_cloud_init" "master" {
+            config = "${kubeadm.k8s.config}"
+            join   = "10.0.0.1"
+            role   = "master"
+        }
+
+        data "kubeadm_cloud_init" "worker" {
+            config = "${kubeadm.k8s.config}"
+            join   = "10.0.0.1"
+            role   = "worker"
+        }`
+
+	resource.UnitTest(t, resource.TestCase{
+		PreCheck:  func() { testAccPreCheck(t) },
+		Providers: testAccProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccKubeadm_vip,
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckState("kubeadm.k8s"),
+					resource.TestCheckResourceAttr("kubeadm.k8s",
+						"config.api_vip",
+						"10.0.0.100"),
+					resource.TestCheckResourceAttr("kubeadm.k8s",
+						"config.api_vip_interface",
+						"ens3"),
+					resource.TestMatchResourceAttr("kubeadm.k8s",
+						"join_config",
+						regexp.MustCompile("apiServerEndpoint: 10.0.0.100:6443")),
+					resource.TestMatchResourceAttr("data.kubeadm_cloud_init.master",
+						"rendered",
+						regexp.MustCompile("path: /etc/kubernetes/manifests/kube-vip.yaml")),
+					resource.TestMatchResourceAttr("data.kubeadm_cloud_init.master",
+						"rendered",
+						regexp.MustCompile("(?s)kubeadm join .*DirAvailable--etc-kubernetes-manifests")),
+					resource.TestCheckResourceAttrSet("data.kubeadm_cloud_init.worker",
+						"rendered"),
+					testAccCheckNoMatch("data.kubeadm_cloud_init.worker", "rendered",
+						regexp.MustCompile("kube-vip")),
+				),
+			},
+		},
+	})
+}
+
+func TestKubeadm_certDistribution(t *testing.T) {
+	const testAccKubeadm_certDistribution = `
+        resource "kubeadm" "k8s" {
+            config_path       = "/tmp/kubeconfig"
+            version           = "%s"
+            cert_distribution = "kubeadm"
+
             api {
               external = "loadbalancer.external.com"
             }
-	
-	        cni {
-				plugin = "flannel"
-	        }
+        }
+
+        data "kubeadm_cloud_init" "seeder" {
+            config = "${kubeadm.k8s.config}"
+        }
+
+        data "kubeadm_cloud_init" "master" {
+            config = "${kubeadm.k8s.config}"
+            join   = "10.0.0.1"
+            role   = "master"
         }`
 
 	resource.UnitTest(t, resource.TestCase{
@@ -46,19 +708,175 @@ func TestKubeadm_basic(t *testing.T) {
 		Providers: testAccProviders,
 		Steps: []resource.TestStep{
 			{
-				Config: testAccKubeadm_basic,
+				Config: fmt.Sprintf(testAccKubeadm_certDistribution, "v1.15.0"),
 				Check: resource.ComposeTestCheckFunc(
 					testAccCheckState("kubeadm.k8s"),
 					resource.TestCheckResourceAttr("kubeadm.k8s",
-						"config.config_path",
-						"/tmp/kubeconfig"),
+						"config.cert_distribution",
+						"kubeadm"),
+					resource.TestMatchResourceAttr("kubeadm.k8s",
+						"config.certificate_key",
+						regexp.MustCompile("^[0-9a-f]{64}$")),
+					resource.TestMatchResourceAttr("data.kubeadm_cloud_init.seeder",
+						"rendered",
+						regexp.MustCompile(`(?s)kubeadm init .*--upload-certs\s+--certificate-key=[0-9a-f]{64}`)),
+					resource.TestMatchResourceAttr("data.kubeadm_cloud_init.master",
+						"rendered",
+						regexp.MustCompile(`(?s)kubeadm join .*--certificate-key=[0-9a-f]{64}`)),
+					testAccCheckNoMatch("data.kubeadm_cloud_init.master", "rendered",
+						regexp.MustCompile("path: /etc/kubernetes/pki/ca.key")),
+				),
+			},
+			{
+				Config:      fmt.Sprintf(testAccKubeadm_certDistribution, "v1.14.3"),
+				ExpectError: regexp.MustCompile("cannot be used"),
+			},
+		},
+	})
+}
+
+func TestKubeadm_cloudInit(t *testing.T) {
+	const testAccKubeadm_cloudInit = `
+        resource "kubeadm" "k8s" {
+            config_path = "/tmp/kubeconfig"
+
+            api {
+              external = "loadbalancer.external.com"
+            }
+        }
+
+        data "kubeadm_cloud_init" "seeder" {
+            config = "${kubeadm.k8s.config}"
+        }
+
+        data "kubeadm_cloud_init" "worker" {
+            config = "${kubeadm.k8s.config}"
+            join   = "10.0.0.1"
+            role   = "worker"
+
+            install {
+              auto = true
+            }
+        }`
+
+	const testAccKubeadm_cloudInitEtcd = `
+        resource "kubeadm" "k8s" {
+            config_path = "/tmp/kubeconfig"
+        }
+
+        data "kubeadm_cloud_init" "etcd" {
+            config = "${kubeadm.k8s.config}"
+            role   = "etcd"
+        }`
+
+	resource.UnitTest(t, resource.TestCase{
+		PreCheck:  func() { testAccPreCheck(t) },
+		Providers: testAccProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccKubeadm_cloudInit,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestMatchResourceAttr("data.kubeadm_cloud_init.seeder",
+						"rendered",
+						regexp.MustCompile("^#cloud-config\n")),
+					resource.TestMatchResourceAttr("data.kubeadm_cloud_init.seeder",
+						"rendered",
+						regexp.MustCompile("(?s)kubeadm init .*--config=/etc/kubernetes/kubeadm-init.conf")),
+					resource.TestMatchResourceAttr("data.kubeadm_cloud_init.seeder",
+						"rendered",
+						regexp.MustCompile("path: /etc/kubernetes/pki/ca.crt")),
+					resource.TestMatchResourceAttr("data.kubeadm_cloud_init.worker",
+						"rendered",
+						regexp.MustCompile("sh /usr/local/bin/kubeadm-setup.sh")),
+					resource.TestMatchResourceAttr("data.kubeadm_cloud_init.worker",
+						"rendered",
+						regexp.MustCompile("(?s)kubeadm join .*--config=/etc/kubernetes/kubeadm-join.conf")),
+				),
+			},
+		},
+	})
+
+	// etcd members cannot be provisioned with cloud-init
+	resource.UnitTest(t, resource.TestCase{
+		PreCheck:  func() { testAccPreCheck(t) },
+		Providers: testAccProviders,
+		Steps: []resource.TestStep{
+			{
+				Config:      testAccKubeadm_cloudInitEtcd,
+				ExpectError: regexp.MustCompile(`expected role to be one of \[master worker\]`),
+			},
+		},
+	})
+}
+
+func TestKubeadm_schedulableMasters(t *testing.T) {
+	const testAccKubeadm_schedulableMasters = `
+        resource "kubeadm" "k8s" {
+            config_path         = "/tmp/kubeconfig"
+            schedulable_masters = true
+        }
+
+        data "kubeadm_cloud_init" "seeder" {
+            config   = "${kubeadm.k8s.config}"
+            nodename = "edge-0"
+        }`
+
+	resource.UnitTest(t, resource.TestCase{
+		PreCheck:  func() { testAccPreCheck(t) },
+		Providers: testAccProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccKubeadm_schedulableMasters,
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckState("kubeadm.k8s"),
 					resource.TestCheckResourceAttr("kubeadm.k8s",
-						"config.cni_plugin",
-						"flannel"),
-					resource.TestCheckResourceAttrSet("kubeadm.k8s",
-						"config.init"),
-					resource.TestCheckResourceAttrSet("kubeadm.k8s",
-						"config.join"),
+						"config.schedulable_masters",
+						"true"),
+					resource.TestMatchResourceAttr("data.kubeadm_cloud_init.seeder",
+						"rendered",
+						regexp.MustCompile("(?s)kubeadm init .*taint node edge-0 node-role.kubernetes.io/master:NoSchedule-")),
+				),
+			},
+		},
+	})
+}
+
+func TestKubeadm_nodes(t *testing.T) {
+	const testAccKubeadm_nodes = `
+        resource "kubeadm" "k8s" {
+            config_path = "/tmp/kubeconfig"
+
+            nodes {
+              master_taints = ["dedicated=control-plane:NoExecute"]
+
+              worker_labels = {
+                "node.example.com/pool" = "default"
+                "tier"                  = "backend"
+              }
+              worker_taints = ["dedicated=ml:NoSchedule", "spot:PreferNoSchedule"]
+            }
+        }`
+
+	resource.UnitTest(t, resource.TestCase{
+		PreCheck:  func() { testAccPreCheck(t) },
+		Providers: testAccProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccKubeadm_nodes,
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckState("kubeadm.k8s"),
+					resource.TestCheckResourceAttr("kubeadm.k8s",
+						"config.masters_labels",
+						""),
+					resource.TestCheckResourceAttr("kubeadm.k8s",
+						"config.masters_taints",
+						"dedicated=control-plane:NoExecute"),
+					resource.TestCheckResourceAttr("kubeadm.k8s",
+						"config.workers_labels",
+						"node.example.com/pool=default,tier=backend"),
+					resource.TestCheckResourceAttr("kubeadm.k8s",
+						"config.workers_taints",
+						"dedicated=ml:NoSchedule,spot:PreferNoSchedule"),
 				),
 			},
 		},
@@ -138,6 +956,95 @@ EOF
 	})
 }
 
+func TestKubeadm_upgrade(t *testing.T) {
+	const testAccKubeadm_version = `
+        resource "kubeadm" "k8s" {
+        	config_path = "/tmp/kubeconfig"
+        	version     = "%s"
+        }`
+
+	token := ""
+
+	resource.UnitTest(t, resource.TestCase{
+		PreCheck:  func() { testAccPreCheck(t) },
+		Providers: testAccProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(testAccKubeadm_version, "v1.15.0"),
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckState("kubeadm.k8s"),
+					resource.TestCheckResourceAttr("kubeadm.k8s",
+						"config.kube_version",
+						"v1.15.0"),
+					testAccGetAttr("kubeadm.k8s", "config.token", &token),
+				),
+			},
+			{
+				Config: fmt.Sprintf(testAccKubeadm_version, "v1.16.2"),
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckState("kubeadm.k8s"),
+					resource.TestCheckResourceAttr("kubeadm.k8s",
+						"config.kube_version",
+						"v1.16.2"),
+					resource.TestCheckResourceAttrPtr("kubeadm.k8s",
+						"config.token",
+						&token),
+				),
+			},
+			{
+				Config:      fmt.Sprintf(testAccKubeadm_version, "v1.15.3"),
+				ExpectError: regexp.MustCompile("cannot downgrade"),
+			},
+		},
+	})
+}
+
+func TestKubeadm_kubeconfig(t *testing.T) {
+	const testAccKubeadm_kubeconfig = `
+        resource "kubeadm" "k8s" {
+        	config_path = "/tmp/kubeconfig"
+
+            api {
+              external = "loadbalancer.external.com"
+            }
+
+            kubeconfig {
+              user     = "someone"
+              groups   = ["some-group"]
+              validity = "%s"
+            }
+        }`
+
+	clientCertificate := ""
+
+	resource.UnitTest(t, resource.TestCase{
+		PreCheck:  func() { testAccPreCheck(t) },
+		Providers: testAccProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(testAccKubeadm_kubeconfig, "24h"),
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckState("kubeadm.k8s"),
+					resource.TestCheckResourceAttrSet("kubeadm.k8s",
+						"config.kubeconfig"),
+					resource.TestMatchResourceAttr("kubeadm.k8s",
+						"kubeconfig_raw",
+						regexp.MustCompile("someone")),
+					testAccGetAttr("kubeadm.k8s", "client_certificate", &clientCertificate),
+				),
+			},
+			{
+				// a new validity must generate a new client certificate
+				Config: fmt.Sprintf(testAccKubeadm_kubeconfig, "48h"),
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckState("kubeadm.k8s"),
+					testAccCheckAttrChanged("kubeadm.k8s", "client_certificate", &clientCertificate),
+				),
+			},
+		},
+	})
+}
+
 // check that a key exists in the state
 func testAccCheckState(id string) resource.TestCheckFunc {
 	return func(s *terraform.State) error {
@@ -171,3 +1078,105 @@ func testAccCheckAttr(id string, attr string) resource.TestCheckFunc {
 		return nil
 	}
 }
+
+// get the value of an attribute in the state
+func testAccGetAttr(id string, attr string, value *string) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		rs, ok := s.RootModule().Resources[id]
+		if !ok {
+			return fmt.Errorf("Not found: %s", id)
+		}
+		v, ok := rs.Primary.Attributes[attr]
+		if !ok {
+			return fmt.Errorf("No attribute %q found in %q", attr, id)
+		}
+		*value = v
+		return nil
+	}
+}
+
+// check that an attribute does not match a regular expression
+func testAccCheckNoMatch(id string, attr string, r *regexp.Regexp) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		rs, ok := s.RootModule().Resources[id]
+		if !ok {
+			return fmt.Errorf("Not found: %s", id)
+		}
+		if v := rs.Primary.Attributes[attr]; r.MatchString(v) {
+			return fmt.Errorf("Attribute %q in %q matches %q", attr, id, r.String())
+		}
+		return nil
+	}
+}
+
+// check that an attribute has changed from some previous value
+func testAccCheckAttrChanged(id string, attr string, previous *string) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		current := ""
+		if err := testAccGetAttr(id, attr, &current)(s); err != nil {
+			return err
+		}
+		if current == *previous {
+			return fmt.Errorf("Attribute %q in %q has not changed", attr, id)
+		}
+		return nil
+	}
+}
+
+// check the discovery in the join configuration pins the CA (or not)
+func testAccCheckJoinDiscovery(id string, pinned bool) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		joinStr := ""
+		if err := testAccGetAttr(id, "config.join", &joinStr)(s); err != nil {
+			return err
+		}
+		joinBytes, err := common.FromTerraformSafeString(joinStr)
+		if err != nil {
+			return err
+		}
+		joinConfig, err := common.YAMLToJoinConfig(joinBytes)
+		if err != nil {
+			return err
+		}
+
+		discovery := joinConfig.Discovery.BootstrapToken
+		if discovery == nil {
+			return fmt.Errorf("No bootstrap token discovery in join configuration")
+		}
+		if pinned {
+			if discovery.UnsafeSkipCAVerification || len(discovery.CACertHashes) == 0 {
+				return fmt.Errorf("CA not pinned in join configuration: %+v", discovery)
+			}
+		} else {
+			if !discovery.UnsafeSkipCAVerification {
+				return fmt.Errorf("CA verification not skipped in join configuration: %+v", discovery)
+			}
+		}
+		return nil
+	}
+}
+
+func TestAdminCertExpiresSoon(t *testing.T) {
+	caCert, caKey, err := pkiutil.NewCertificateAuthority(&certutil.Config{CommonName: "kubernetes"})
+	if err != nil {
+		t.Fatalf("Error: could not create CA: %s", err)
+	}
+	caKeyPEM, err := keyutil.MarshalPrivateKeyToPEM(caKey)
+	if err != nil {
+		t.Fatalf("Error: could not encode CA key: %s", err)
+	}
+	crt, _, err := common.CreateClientCert(pkiutil.EncodeCertPEM(caCert), caKeyPEM, "someone", nil, 10*24*time.Hour)
+	if err != nil {
+		t.Fatalf("Error: could not create client certificate: %s", err)
+	}
+
+	if !adminCertExpiresSoon(string(crt), "8760h") {
+		t.Fatalf("Error: a certificate that expires in 10 days must be renewed")
+	}
+	if adminCertExpiresSoon(string(crt), "240h") {
+		t.Fatalf("Error: a short-lived certificate must only be renewed in the last third of its validity")
+	}
+	if adminCertExpiresSoon("", "8760h") {
+		t.Fatalf("Error: no certificate must not be renewed")
+	}
+}
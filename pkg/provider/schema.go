@@ -18,9 +18,11 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/hashicorp/terraform/helper/customdiff"
 	"github.com/hashicorp/terraform/helper/schema"
 	"github.com/hashicorp/terraform/helper/validation"
 	"github.com/hashicorp/terraform/terraform"
+	kubeadmconstants "k8s.io/kubernetes/cmd/kubeadm/app/constants"
 
 	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
 )
@@ -30,9 +32,18 @@ func dataSourceKubeadm() *schema.Resource {
 		Create: dataSourceKubeadmCreate,
 		Read:   dataSourceKubeadmRead,
 		Delete: dataSourceKubeadmDelete,
-		//Update: dataSourceKubeadmUpdate,
+		Update: dataSourceKubeadmUpdate,
 		Exists: dataSourceKubeadmExists,
 
+		CustomizeDiff: customdiff.All(
+			customizeDiffVersion,
+			customizeDiffKubeconfig,
+			customizeDiffDiscovery,
+			customizeDiffToken,
+			customizeDiffNodes,
+			customizeDiffCertDistribution,
+		),
+
 		Schema: map[string]*schema.Schema{
 			"config_path": {
 				Type:        schema.TypeString,
@@ -40,6 +51,85 @@ func dataSourceKubeadm() *schema.Resource {
 				ForceNew:    true,
 				Description: "A local copy of the kubeconfig",
 			},
+			"kubeconfig": {
+				Type:     schema.TypeList,
+				Optional: true,
+				MaxItems: 1,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"user": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							Default:     common.DefAdminUser,
+							Description: "user (ie, the CN of the client certificate) in the admin kubeconfig",
+						},
+						"groups": {
+							Type:        schema.TypeList,
+							Elem:        &schema.Schema{Type: schema.TypeString},
+							Optional:    true,
+							Description: "list of groups for the user in the admin kubeconfig (defaults to system:masters)",
+						},
+						"validity": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							Default:      common.DefAdminCertValidity.String(),
+							Description:  "validity of the client certificate in the admin kubeconfig (Example: 8760h)",
+							ValidateFunc: common.ValidateDuration,
+						},
+					},
+				},
+			},
+			"token": {
+				Type:        schema.TypeList,
+				Optional:    true,
+				MaxItems:    1,
+				Description: "options for the bootstrap tokens used for joining the cluster",
+				Elem: &schema.Resource{
+					Schema: tokenSchemaElements(false),
+				},
+			},
+			"nodes": {
+				Type:        schema.TypeList,
+				Optional:    true,
+				MaxItems:    1,
+				Description: "default options for the nodes in the cluster, depending on their role",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"master_labels": {
+							Type:         schema.TypeMap,
+							Elem:         &schema.Schema{Type: schema.TypeString},
+							Optional:     true,
+							Description:  "labels for the masters",
+							ValidateFunc: common.ValidateLabels,
+						},
+						"master_taints": {
+							Type: schema.TypeList,
+							Elem: &schema.Schema{
+								Type:         schema.TypeString,
+								ValidateFunc: common.ValidateTaint,
+							},
+							Optional:    true,
+							Description: "taints for the masters, as 'key=value:effect'",
+						},
+						"worker_labels": {
+							Type:         schema.TypeMap,
+							Elem:         &schema.Schema{Type: schema.TypeString},
+							Optional:     true,
+							Description:  "labels for the workers",
+							ValidateFunc: common.ValidateLabels,
+						},
+						"worker_taints": {
+							Type: schema.TypeList,
+							Elem: &schema.Schema{
+								Type:         schema.TypeString,
+								ValidateFunc: common.ValidateTaint,
+							},
+							Optional:    true,
+							Description: "taints for the workers, as 'key=value:effect'",
+						},
+					},
+				},
+			},
 			"api": {
 				Type:     schema.TypeList,
 				Optional: true,
@@ -48,10 +138,24 @@ func dataSourceKubeadm() *schema.Resource {
 				Elem: &schema.Resource{
 					Schema: map[string]*schema.Schema{
 						"external": {
-							Type:         schema.TypeString,
-							Optional:     true,
-							Description:  "stable IP/DNS (and port) for the control plane (for example, the load balancer)",
-							ValidateFunc: common.ValidateDNSNameOrIP,
+							Type:          schema.TypeString,
+							Optional:      true,
+							Description:   "stable IP/DNS (and port) for the control plane (for example, the load balancer)",
+							ValidateFunc:  common.ValidateDNSNameOrIP,
+							ConflictsWith: []string{"api.0.vip"},
+						},
+						"vip": {
+							Type:          schema.TypeString,
+							Optional:      true,
+							Description:   "virtual IP for the control plane, announced by a kube-vip static pod in the masters",
+							ValidateFunc:  validation.SingleIP(),
+							ConflictsWith: []string{"api.0.external"},
+						},
+						"vip_interface": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							Default:     common.DefKubeVipInterface,
+							Description: "network interface where the virtual IP is announced",
 						},
 						"internal": {
 							Type:         schema.TypeString,
@@ -111,7 +215,7 @@ func dataSourceKubeadm() *schema.Resource {
 							Type:         schema.TypeString,
 							Optional:     true,
 							Default:      "",
-							Description:  "CNI plugin to install. Currently supported: flannel",
+							Description:  "CNI plugin to install. Currently supported: flannel, weave, calico, cilium",
 							ValidateFunc: validation.StringInSlice(common.CNIPluginsList, true),
 						},
 						"plugin_manifest": {
@@ -150,24 +254,115 @@ func dataSourceKubeadm() *schema.Resource {
 										ValidateFunc: validation.StringInSlice([]string{"vxlan", "host-gw", "udp", "ali-vpc", "aws-vpc", "gce", "ipip", "ipsec"}, true),
 									},
 									"version": {
+										Type:        schema.TypeString,
+										Optional:    true,
+										Default:     common.DefFlannelImageVersion,
+										Description: "Flannel image version",
+									},
+									"iface": {
+										Type:          schema.TypeString,
+										Optional:      true,
+										Description:   "Interface to use for inter-host communication",
+										ConflictsWith: []string{"cni.0.flannel.0.iface_regex"},
+									},
+									"iface_regex": {
+										Type:          schema.TypeString,
+										Optional:      true,
+										Description:   "Regular expression for matching the interface to use for inter-host communication",
+										ConflictsWith: []string{"cni.0.flannel.0.iface"},
+									},
+									"mtu": {
+										Type:         schema.TypeInt,
+										Optional:     true,
+										Description:  "MTU for the pods interfaces (by default, computed from the interface used)",
+										ValidateFunc: validation.IntBetween(576, 9000),
+									},
+								},
+							},
+						},
+						"weave": {
+							Type:     schema.TypeList,
+							Optional: true,
+							ForceNew: true,
+							MaxItems: 1,
+							Elem: &schema.Resource{
+								Schema: map[string]*schema.Schema{
+									"password": {
+										Type:        schema.TypeString,
+										Optional:    true,
+										Sensitive:   true,
+										Description: "Password for encrypting the traffic between peers",
+									},
+									"ipalloc_range": {
+										Type:         schema.TypeString,
+										Optional:     true,
+										Description:  "Range of IP addresses used by Weave Net (by default, 10.32.0.0/12)",
+										ValidateFunc: validation.CIDRNetwork(0, 32),
+									},
+									"mtu": {
+										Type:         schema.TypeInt,
+										Optional:     true,
+										Description:  "MTU for the Weave Net interfaces",
+										ValidateFunc: validation.IntBetween(576, 9000),
+									},
+								},
+							},
+						},
+						"calico": {
+							Type:     schema.TypeList,
+							Optional: true,
+							ForceNew: true,
+							MaxItems: 1,
+							Elem: &schema.Resource{
+								Schema: map[string]*schema.Schema{
+									"mode": {
+										Type:         schema.TypeString,
+										Optional:     true,
+										Default:      common.DefCalicoMode,
+										Description:  "Calico encapsulation mode: ipip, ipip-crosssubnet, vxlan",
+										ValidateFunc: validation.StringInSlice([]string{"ipip", "ipip-crosssubnet", "vxlan"}, true),
+									},
+									"mtu": {
+										Type:         schema.TypeInt,
+										Optional:     true,
+										Description:  "MTU for the pods interfaces and the tunnel device (default depends on the mode)",
+										ValidateFunc: validation.IntBetween(576, 9000),
+									},
+									"ip_autodetection_method": {
 										Type:     schema.TypeString,
 										Optional: true,
-										Default:  common.DefFlannelBackend,
-										// see https://github.com/coreos/flannel/blob/master/Documentation/backends.md
-										Description:  "Flannel backend: vxlan, host-gw, udp, ali-vpc, aws-vpc, gce, ipip, ipsec",
-										ValidateFunc: validation.StringInSlice([]string{"vxlan", "host-gw", "udp", "ali-vpc", "aws-vpc", "gce", "ipip", "ipsec"}, true),
+										Default:  common.DefCalicoIPAutodetectionMethod,
+										// see https://docs.projectcalico.org/v3.9/reference/node/configuration#ip-autodetection-methods
+										Description: "Method used for detecting the node IP address (ie, first-found, can-reach=DEST, interface=REGEX)",
+									},
+									"version": {
+										Type:        schema.TypeString,
+										Optional:    true,
+										Default:     common.DefCalicoImageVersion,
+										Description: "Calico images version",
 									},
 								},
 							},
 						},
-						"weave": {
+						"cilium": {
 							Type:     schema.TypeList,
 							Optional: true,
 							ForceNew: true,
 							MaxItems: 1,
 							Elem: &schema.Resource{
 								Schema: map[string]*schema.Schema{
-									// no options yet
+									"version": {
+										Type:        schema.TypeString,
+										Optional:    true,
+										Default:     common.DefCiliumImageVersion,
+										Description: "Cilium images version",
+									},
+									"kube_proxy_replacement": {
+										Type:        schema.TypeBool,
+										Optional:    true,
+										Default:     false,
+										Description: "Replace kube-proxy with Cilium (kube-proxy will not be installed)",
+									},
 								},
 							},
 						},
@@ -259,6 +454,28 @@ func dataSourceKubeadm() *schema.Resource {
 							Optional:    true,
 							Description: "list of etcd servers URLs including host:port",
 						},
+						"ca_crt": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							Description: "CA certificate of the external etcd cluster",
+						},
+						"client_crt": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							Description: "client certificate for the external etcd cluster",
+						},
+						"client_key": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							Sensitive:   true,
+							Description: "client key for the external etcd cluster",
+						},
+						"managed": {
+							Type:        schema.TypeBool,
+							Optional:    true,
+							Default:     false,
+							Description: "the etcd members are bootstrapped by the provisioner (with 'role = \"etcd\"')",
+						},
 					},
 				},
 			},
@@ -266,8 +483,26 @@ func dataSourceKubeadm() *schema.Resource {
 				Type:        schema.TypeString,
 				Optional:    true,
 				Default:     common.DefKubernetesVersion,
-				ForceNew:    true,
-				Description: "Kubernetes version to use (Example: v1.15.0).",
+				Description: "Kubernetes version to use (Example: v1.15.0). Changing it upgrades the cluster in-place.",
+			},
+			"unsafe_skip_ca_verification": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "do not pin the CA certificate when joining the cluster (insecure)",
+			},
+			"cert_distribution": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      common.DefCertDistribution,
+				Description:  "how the certificates are distributed to the masters joining the cluster: 'ssh' or 'kubeadm'",
+				ValidateFunc: validation.StringInSlice([]string{"ssh", "kubeadm"}, false),
+			},
+			"schedulable_masters": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "do not taint the masters, so regular workloads can be scheduled in them (ie, for single-node clusters)",
 			},
 			"cloud": {
 				Type:     schema.TypeList,
@@ -346,6 +581,248 @@ func dataSourceKubeadm() *schema.Resource {
 					},
 				},
 			},
+			"audit": {
+				Type:     schema.TypeList,
+				Optional: true,
+				ForceNew: true,
+				MaxItems: 1,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"policy": {
+							Type:          schema.TypeString,
+							Optional:      true,
+							Description:   "audit policy contents (a default policy will be used if no policy is provided)",
+							ConflictsWith: []string{"audit.0.policy_file"},
+						},
+						"policy_file": {
+							Type:          schema.TypeString,
+							Optional:      true,
+							Description:   "local file with the audit policy",
+							ConflictsWith: []string{"audit.0.policy"},
+						},
+						"log_path": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							Default:     common.DefAuditLogPath,
+							Description: "path for the audit log in the control plane machines ('-' means the standard output)",
+						},
+						"max_age": {
+							Type:         schema.TypeInt,
+							Optional:     true,
+							Default:      common.DefAuditLogMaxAge,
+							Description:  "maximum number of days to retain old audit log files",
+							ValidateFunc: validation.IntAtLeast(0),
+						},
+						"max_size": {
+							Type:         schema.TypeInt,
+							Optional:     true,
+							Default:      common.DefAuditLogMaxSize,
+							Description:  "maximum size (in megabytes) of the audit log file before it gets rotated",
+							ValidateFunc: validation.IntAtLeast(0),
+						},
+						"max_backup": {
+							Type:         schema.TypeInt,
+							Optional:     true,
+							Default:      common.DefAuditLogMaxBackup,
+							Description:  "maximum number of old audit log files to retain",
+							ValidateFunc: validation.IntAtLeast(0),
+						},
+						"webhook_config": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							Sensitive:   true,
+							Description: "kubeconfig-formatted file with the configuration of the audit webhook backend",
+						},
+					},
+				},
+			},
+			"pod_security": {
+				Type:     schema.TypeList,
+				Optional: true,
+				ForceNew: true,
+				MaxItems: 1,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"default_policy": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							Default:      common.DefPSPDefaultPolicy,
+							Description:  "Pod Security Policy granted to all the users and service accounts: restricted or privileged",
+							ValidateFunc: validation.StringInSlice([]string{"restricted", "privileged"}, false),
+						},
+					},
+				},
+			},
+			"encryption": {
+				Type:     schema.TypeList,
+				Optional: true,
+				ForceNew: true,
+				MaxItems: 1,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"provider": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							ForceNew:     true,
+							Default:      common.DefEncryptionProvider,
+							Description:  fmt.Sprintf("encryption provider for Secrets: %s", strings.Join(common.EncryptionProviders, ", ")),
+							ValidateFunc: validation.StringInSlice(common.EncryptionProviders, false),
+						},
+						"key": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							Computed:     true,
+							ForceNew:     true,
+							Sensitive:    true,
+							Description:  "base64 encoded, 32 bytes key used for encrypting Secrets (a random key will be generated if not provided)",
+							ValidateFunc: common.ValidateEncryptionKey,
+						},
+					},
+				},
+			},
+			"feature_gates": {
+				Type:     schema.TypeList,
+				Optional: true,
+				ForceNew: true,
+				MaxItems: 1,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"kubeadm": {
+							Type:        schema.TypeMap,
+							Elem:        &schema.Schema{Type: schema.TypeBool},
+							Optional:    true,
+							Description: "Map of feature gates for kubeadm (Example: CoreDNS = true)",
+						},
+						"components": {
+							Type:        schema.TypeMap,
+							Elem:        &schema.Schema{Type: schema.TypeBool},
+							Optional:    true,
+							Description: "Map of feature gates for all the components of the cluster (API server, kubelet, kube-proxy...)",
+						},
+					},
+				},
+			},
+			"kubelet_config": {
+				Type:     schema.TypeList,
+				Optional: true,
+				ForceNew: true,
+				MaxItems: 1,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"cgroup_driver": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							Description:  "cgroup driver used by the kubelet: cgroupfs or systemd",
+							ValidateFunc: validation.StringInSlice([]string{"cgroupfs", "systemd"}, false),
+						},
+						"max_pods": {
+							Type:         schema.TypeInt,
+							Optional:     true,
+							Description:  "maximum number of pods that can run in a node",
+							ValidateFunc: validation.IntAtLeast(1),
+						},
+						"fail_swap_on": {
+							Type:        schema.TypeBool,
+							Optional:    true,
+							Default:     true,
+							Description: "fail to start the kubelet if swap is enabled in the node",
+						},
+						"protect_kernel_defaults": {
+							Type:        schema.TypeBool,
+							Optional:    true,
+							Default:     false,
+							Description: "fail to start the kubelet if the kernel tunables are different from the kubelet defaults",
+						},
+						"image_gc_high_threshold": {
+							Type:         schema.TypeInt,
+							Optional:     true,
+							Description:  "percent of disk usage after which image garbage collection is always run",
+							ValidateFunc: validation.IntBetween(0, 100),
+						},
+						"image_gc_low_threshold": {
+							Type:         schema.TypeInt,
+							Optional:     true,
+							Description:  "percent of disk usage before which image garbage collection is never run",
+							ValidateFunc: validation.IntBetween(0, 100),
+						},
+						"eviction_hard": {
+							Type:        schema.TypeMap,
+							Elem:        &schema.Schema{Type: schema.TypeString},
+							Optional:    true,
+							Description: "Map of signal names to quantities that defines hard eviction thresholds (Example: memory.available = \"100Mi\")",
+						},
+						"eviction_soft": {
+							Type:        schema.TypeMap,
+							Elem:        &schema.Schema{Type: schema.TypeString},
+							Optional:    true,
+							Description: "Map of signal names to quantities that defines soft eviction thresholds",
+						},
+						"eviction_soft_grace_period": {
+							Type:        schema.TypeMap,
+							Elem:        &schema.Schema{Type: schema.TypeString},
+							Optional:    true,
+							Description: "Map of signal names to the grace periods for the soft eviction thresholds",
+						},
+						"system_reserved": {
+							Type:        schema.TypeMap,
+							Elem:        &schema.Schema{Type: schema.TypeString},
+							Optional:    true,
+							Description: "Map of resources reserved for the system (Example: cpu = \"200m\")",
+						},
+						"kube_reserved": {
+							Type:        schema.TypeMap,
+							Elem:        &schema.Schema{Type: schema.TypeString},
+							Optional:    true,
+							Description: "Map of resources reserved for Kubernetes system components",
+						},
+					},
+				},
+			},
+			"kube_proxy_config": {
+				Type:     schema.TypeList,
+				Optional: true,
+				ForceNew: true,
+				MaxItems: 1,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"mode": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							Description:  "proxy mode: iptables or ipvs",
+							ValidateFunc: validation.StringInSlice([]string{"iptables", "ipvs"}, false),
+						},
+						"ipvs_scheduler": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							Description: "IPVS scheduler (Example: rr, lc, sh...)",
+						},
+						"ipvs_exclude_cidrs": {
+							Type:        schema.TypeList,
+							Elem:        &schema.Schema{Type: schema.TypeString},
+							Optional:    true,
+							Description: "List of CIDRs that the IPVS proxier should not touch when cleaning up rules",
+						},
+						"masquerade_all": {
+							Type:        schema.TypeBool,
+							Optional:    true,
+							Default:     false,
+							Description: "SNAT all the traffic sent via Service cluster IPs",
+						},
+						"conntrack_max_per_core": {
+							Type:         schema.TypeInt,
+							Optional:     true,
+							Description:  "maximum number of NAT connections to track per CPU core",
+							ValidateFunc: validation.IntAtLeast(0),
+						},
+						"metrics_bind_address": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							Description:  "IP address and port for the metrics server (Example: 0.0.0.0:10249)",
+							ValidateFunc: common.ValidateHostPort,
+						},
+					},
+				},
+			},
 			"certs": {
 				Type:     schema.TypeList,
 				Optional: true,
@@ -404,6 +881,58 @@ func dataSourceKubeadm() *schema.Resource {
 					},
 				},
 			},
+			"host": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "URL of the API server",
+			},
+			"cluster_ca_certificate": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Sensitive:   true,
+				Description: "PEM-encoded CA certificate of the cluster",
+			},
+			"client_certificate": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Sensitive:   true,
+				Description: "PEM-encoded client certificate for accessing the cluster as an administrator",
+			},
+			"client_key": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Sensitive:   true,
+				Description: "PEM-encoded private key for the client certificate",
+			},
+			"kubeconfig_raw": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Sensitive:   true,
+				Description: "contents of a kubeconfig for accessing the cluster as an administrator",
+			},
+			"join_command": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Sensitive:   true,
+				Description: "a 'kubeadm join' command for joining the cluster as a worker",
+			},
+			"join_config": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Sensitive:   true,
+				Description: "a kubeadm configuration for joining the cluster as a worker",
+			},
+			"join_config_control_plane": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Sensitive:   true,
+				Description: "a kubeadm configuration for joining the cluster as a control plane machine",
+			},
+			"ca_cert_hash": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "hash of the CA certificate, used for pinning the CA when joining the cluster",
+			},
 			// the "config" must be a map of string that will be passed to the "provisioner"
 			"config": {
 				Type:     schema.TypeMap,
@@ -416,10 +945,424 @@ func dataSourceKubeadm() *schema.Resource {
 	}
 }
 
+// tokenSchemaElements returns the schema for the options of a bootstrap token
+func tokenSchemaElements(forceNew bool) map[string]*schema.Schema {
+	return map[string]*schema.Schema{
+		"ttl": {
+			Type:         schema.TypeString,
+			Optional:     true,
+			ForceNew:     forceNew,
+			Default:      common.DefTokenTTL.String(),
+			Description:  "time to live of the token (Example: 2h). A zero TTL means the token never expires.",
+			ValidateFunc: common.ValidateDuration,
+		},
+		"usages": {
+			Type:     schema.TypeList,
+			Optional: true,
+			Computed: forceNew,
+			ForceNew: forceNew,
+			Elem: &schema.Schema{
+				Type:         schema.TypeString,
+				ValidateFunc: validation.StringInSlice(kubeadmconstants.DefaultTokenUsages, false),
+			},
+			Description: "ways in which the token can be used (defaults to signing and authentication)",
+		},
+		"groups": {
+			Type:        schema.TypeList,
+			Optional:    true,
+			Computed:    forceNew,
+			ForceNew:    forceNew,
+			Elem:        &schema.Schema{Type: schema.TypeString},
+			Description: "extra groups the token will authenticate as (defaults to " + kubeadmconstants.NodeBootstrapTokenAuthGroup + ")",
+		},
+		"description": {
+			Type:        schema.TypeString,
+			Optional:    true,
+			ForceNew:    forceNew,
+			Description: "a human-friendly description of the token",
+		},
+	}
+}
+
+func resourceKubeadmToken() *schema.Resource {
+	tokenSchema := tokenSchemaElements(true)
+	tokenSchema["config_path"] = &schema.Schema{
+		Type:          schema.TypeString,
+		Optional:      true,
+		ConflictsWith: []string{"kubeconfig_raw"},
+		Description:   "path to a kubeconfig for accessing the cluster as an administrator",
+	}
+	tokenSchema["kubeconfig_raw"] = &schema.Schema{
+		Type:          schema.TypeString,
+		Optional:      true,
+		Sensitive:     true,
+		ConflictsWith: []string{"config_path"},
+		Description:   "contents of a kubeconfig for accessing the cluster as an administrator",
+	}
+	tokenSchema["token"] = &schema.Schema{
+		Type:        schema.TypeString,
+		Computed:    true,
+		Sensitive:   true,
+		Description: "the bootstrap token",
+	}
+	tokenSchema["expires"] = &schema.Schema{
+		Type:        schema.TypeString,
+		Computed:    true,
+		Description: "expiration time of the token (in RFC3339 format, empty if the token never expires)",
+	}
+
+	return &schema.Resource{
+		Create: resourceKubeadmTokenCreate,
+		Read:   resourceKubeadmTokenRead,
+		Update: resourceKubeadmTokenUpdate,
+		Delete: resourceKubeadmTokenDelete,
+		Schema: tokenSchema,
+	}
+}
+
+// installSchemaElements returns the elements of an `install` block, with
+// the same names as the `install` block in the provisioner
+func installSchemaElements() map[string]*schema.Schema {
+	return map[string]*schema.Schema{
+		"auto": {
+			Type:        schema.TypeBool,
+			Optional:    true,
+			Default:     false,
+			Description: "try to automatically install kubeadm with the built-in helper script",
+		},
+		"script": {
+			Type:        schema.TypeString,
+			Optional:    true,
+			Description: "local script for installing kubeadm",
+		},
+		"inline": {
+			Type:        schema.TypeString,
+			Optional:    true,
+			Description: "inline shell script code for installing kubeadm",
+		},
+		"sysconfig_path": {
+			Type:        schema.TypeString,
+			Default:     common.DefKubeletSysconfigPath,
+			Optional:    true,
+			Description: "full path for the kubelet sysconfig file",
+		},
+		"service_path": {
+			Type:        schema.TypeString,
+			Default:     common.DefKubeletServicePath,
+			Optional:    true,
+			Description: "full path for the kubelet.service file",
+		},
+		"dropin_path": {
+			Type:        schema.TypeString,
+			Default:     common.DefKubeadmDropinPath,
+			Optional:    true,
+			Description: "full path for the kubeadm dropin file",
+		},
+		"kubeadm_path": {
+			Type:        schema.TypeString,
+			Default:     common.DefKubeadmPath,
+			Optional:    true,
+			Description: "full path where kubeadm should be present",
+		},
+		"kubectl_path": {
+			Type:        schema.TypeString,
+			Default:     common.DefKubectlPath,
+			Optional:    true,
+			Description: "full path where kubectl should be present",
+		},
+	}
+}
+
+// dataSourceKubeadmCloudInit is a data source that renders a cloud-config
+// document. Note well: the arguments must have the same names as the ones
+// in the provisioner, as the rendering is done in the provisioner package.
+func dataSourceKubeadmCloudInit() *schema.Resource {
+	return &schema.Resource{
+		Read: dataSourceKubeadmCloudInitRead,
+
+		Schema: map[string]*schema.Schema{
+			"config": {
+				Type:        schema.TypeMap,
+				Required:    true,
+				Description: "the config exported by the kubeadm resource",
+				Elem: &schema.Resource{
+					Schema: common.ProvisionerConfigElements,
+				},
+			},
+			"join": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Default:     "",
+				Description: "address of the API server to join. Or initialize the cluster when not provided",
+			},
+			"role": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      "",
+				Description:  "role of this machine: master or worker",
+				ValidateFunc: validation.StringInSlice([]string{"master", "worker"}, true),
+			},
+			"nodename": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Default:     "",
+				Description: "name used for registering the node in the kubernetes cluster (defaults to the hostname)",
+			},
+			"listen": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Description:  "for masters, IP/DNS:port to listen at",
+				ValidateFunc: common.ValidateHostPort,
+			},
+			"ignore_checks": {
+				Type:        schema.TypeList,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Optional:    true,
+				Description: "list of preflight checks to ignore by kubeadm",
+			},
+			"install": {
+				Type:     schema.TypeList,
+				Optional: true,
+				MaxItems: 1,
+				Elem: &schema.Resource{
+					Schema: installSchemaElements(),
+				},
+			},
+			"labels": {
+				Type:         schema.TypeMap,
+				Elem:         &schema.Schema{Type: schema.TypeString},
+				Optional:     true,
+				Description:  "labels for the node",
+				ValidateFunc: common.ValidateLabels,
+			},
+			"taints": {
+				Type: schema.TypeList,
+				Elem: &schema.Schema{
+					Type:         schema.TypeString,
+					ValidateFunc: common.ValidateTaint,
+				},
+				Optional:    true,
+				Description: "taints for the node, as 'key=value:effect'",
+			},
+			"zone": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "zone of the node (for the well-known topology label)",
+			},
+			"region": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "region of the node (for the well-known topology label)",
+			},
+			"rendered": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "the rendered cloud-config document",
+			},
+		},
+	}
+}
+
+// resourceKubeadmNode is a node in the cluster, provisioned through SSH.
+// Note well: the arguments must have the same names as the ones in the
+// provisioner, as the provisioning is done in the provisioner package.
+func resourceKubeadmNode() *schema.Resource {
+	return &schema.Resource{
+		Create: resourceKubeadmNodeCreate,
+		Read:   resourceKubeadmNodeRead,
+		Update: resourceKubeadmNodeUpdate,
+		Delete: resourceKubeadmNodeDelete,
+
+		Schema: map[string]*schema.Schema{
+			"config": {
+				Type:        schema.TypeMap,
+				Required:    true,
+				Description: "the config exported by the kubeadm resource",
+				Elem: &schema.Resource{
+					Schema: common.ProvisionerConfigElements,
+				},
+			},
+			"ssh": {
+				Type:        schema.TypeList,
+				Required:    true,
+				MaxItems:    1,
+				Description: "SSH connection details for the machine",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"host": {
+							Type:        schema.TypeString,
+							Required:    true,
+							ForceNew:    true,
+							Description: "address of the machine",
+						},
+						"port": {
+							Type:        schema.TypeInt,
+							Optional:    true,
+							Default:     22,
+							Description: "SSH port",
+						},
+						"user": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							Default:     "root",
+							Description: "user for the SSH connection",
+						},
+						"password": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							Sensitive:   true,
+							Description: "password for the SSH connection",
+						},
+						"private_key": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							Sensitive:   true,
+							Description: "contents of the SSH private key",
+						},
+						"agent": {
+							Type:        schema.TypeBool,
+							Optional:    true,
+							Description: "use the SSH agent for authenticating",
+						},
+						"timeout": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							Default:      "5m",
+							Description:  "timeout for establishing the SSH connection",
+							ValidateFunc: common.ValidateDuration,
+						},
+						"bastion_host": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							Description: "address of a bastion host for the SSH connection",
+						},
+						"bastion_port": {
+							Type:        schema.TypeInt,
+							Optional:    true,
+							Description: "SSH port in the bastion host (defaults to the port of the machine)",
+						},
+						"bastion_user": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							Description: "user for the bastion host (defaults to the user of the machine)",
+						},
+						"bastion_private_key": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							Sensitive:   true,
+							Description: "contents of the SSH private key for the bastion host",
+						},
+					},
+				},
+			},
+			"join": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				ForceNew:    true,
+				Default:     "",
+				Description: "address of the API server to join. Or initialize the cluster when not provided",
+			},
+			"role": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ForceNew:     true,
+				Default:      "",
+				Description:  "role of this machine: master, worker or etcd",
+				ValidateFunc: validation.StringInSlice([]string{"master", "worker", "etcd"}, true),
+			},
+			"nodename": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Computed:    true,
+				ForceNew:    true,
+				Description: "name used for registering the node in the kubernetes cluster (defaults to the hostname)",
+			},
+			"listen": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ForceNew:     true,
+				Description:  "for masters, IP/DNS:port to listen at",
+				ValidateFunc: common.ValidateHostPort,
+			},
+			"ignore_checks": {
+				Type:        schema.TypeList,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Optional:    true,
+				Description: "list of preflight checks to ignore by kubeadm",
+			},
+			"manifests": {
+				Type:        schema.TypeList,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Optional:    true,
+				Description: "list of manifests to load in the API server once the master is setup",
+			},
+			"prevent_sudo": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "prevent the use of sudo",
+			},
+			"force": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "when true, remove this node from the etcd cluster even if the etcd cluster would lose quorum",
+			},
+			"install": {
+				Type:     schema.TypeList,
+				Optional: true,
+				MaxItems: 1,
+				Elem: &schema.Resource{
+					Schema: installSchemaElements(),
+				},
+			},
+			"labels": {
+				Type:         schema.TypeMap,
+				Elem:         &schema.Schema{Type: schema.TypeString},
+				Optional:     true,
+				Description:  "labels for the node",
+				ValidateFunc: common.ValidateLabels,
+			},
+			"taints": {
+				Type: schema.TypeList,
+				Elem: &schema.Schema{
+					Type:         schema.TypeString,
+					ValidateFunc: common.ValidateTaint,
+				},
+				Optional:    true,
+				Description: "taints for the node, as 'key=value:effect'",
+			},
+			"zone": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				ForceNew:    true,
+				Description: "zone of the node (for the well-known topology label)",
+			},
+			"region": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				ForceNew:    true,
+				Description: "region of the node (for the well-known topology label)",
+			},
+			"kubelet_args": {
+				Type:        schema.TypeMap,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Optional:    true,
+				Description: "extra arguments for the kubelet",
+			},
+		},
+	}
+}
+
 func Provider() terraform.ResourceProvider {
 	return &schema.Provider{
 		ResourcesMap: map[string]*schema.Resource{
-			"kubeadm": dataSourceKubeadm(),
+			"kubeadm":       dataSourceKubeadm(),
+			"kubeadm_token": resourceKubeadmToken(),
+			"kubeadm_node":  resourceKubeadmNode(),
+		},
+		DataSourcesMap: map[string]*schema.Resource{
+			"kubeadm_cloud_init": dataSourceKubeadmCloudInit(),
 		},
 	}
 }
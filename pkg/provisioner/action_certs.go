@@ -0,0 +1,245 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/hashicorp/terraform/helper/schema"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	kubeadmapi "k8s.io/kubernetes/cmd/kubeadm/app/apis/kubeadm"
+	kubeadmconstants "k8s.io/kubernetes/cmd/kubeadm/app/constants"
+
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
+)
+
+const (
+	// command for checking the Secret with the certificates uploaded by kubeadm exists
+	kubectlGetKubeadmCertsCmd = `-n kube-system get secret ` + kubeadmconstants.KubeadmCertsSecret
+
+	// command for getting the UID of a Secret in the "kube-system" namespace
+	kubectlGetSecretUIDCmd = `-n kube-system get secret %s -o=jsonpath='{.metadata.uid}'`
+)
+
+// names used by kubeadm in the "kubeadm-certs" Secret for the certificates
+// (in the `config`) for accessing an external etcd cluster
+var externalEtcdCertsSecretNames = map[string]string{
+	"etcd_ca_crt":     "external-etcd-ca.crt",
+	"etcd_client_crt": "external-etcd.crt",
+	"etcd_client_key": "external-etcd.key",
+}
+
+// isKubeadmCertDistribution returns true if the certificates are distributed
+// to the masters joining the cluster with kubeadm
+func isKubeadmCertDistribution(d *schema.ResourceData) bool {
+	return getCertDistributionFromResourceData(d) == "kubeadm"
+}
+
+// getKubeadmInitCertsArgs returns the extra arguments for the `kubeadm init`, so it
+// uploads the certificates to the cluster (like a `kubeadm init phase upload-certs`)
+// when they are distributed with kubeadm
+func getKubeadmInitCertsArgs(d *schema.ResourceData) []string {
+	if !isKubeadmCertDistribution(d) {
+		return nil
+	}
+	return []string{
+		"--upload-certs",
+		fmt.Sprintf("--certificate-key=%s", getCertificateKeyFromResourceData(d)),
+		"--skip-certificate-key-print",
+	}
+}
+
+// getKubeadmJoinCertsArgs returns the extra arguments for the `kubeadm join` of a master,
+// so it downloads the certificates from the cluster when they are distributed with kubeadm
+func getKubeadmJoinCertsArgs(d *schema.ResourceData) []string {
+	if !isKubeadmCertDistribution(d) {
+		return nil
+	}
+	return []string{fmt.Sprintf("--certificate-key=%s", getCertificateKeyFromResourceData(d))}
+}
+
+// doDistributeCerts distributes the certificates to a master joining the cluster
+// * in the "ssh" mode, the certificates are uploaded to the machine.
+// * in the "kubeadm" mode, the `kubeadm join` downloads them from the Secret.
+// In the "kubeadm" mode, we only have to make sure the "kubeadm-certs" Secret is still there.
+func doDistributeCerts(d *schema.ResourceData) ssh.Action {
+	if !isKubeadmCertDistribution(d) {
+		return ssh.ActionList{
+			doUploadCerts(d), // (we must upload certs because a "kubeadm reset" wipes them...)
+			doUploadExternalEtcdCerts(d),
+		}
+	}
+
+	if len(getCertificateKeyFromResourceData(d)) == 0 {
+		return ssh.ActionError("no certificate key found in 'config'")
+	}
+
+	return ssh.DoIfElse(
+		ssh.CheckAction(doRemoteKubectl(d, kubectlGetKubeadmCertsCmd)),
+		ssh.DoMessageInfo("Certificates will be downloaded from the %q Secret", kubeadmconstants.KubeadmCertsSecret),
+		ssh.ActionList{
+			ssh.DoMessageWarn("%q Secret not found (it could have expired): uploading certificates again...", kubeadmconstants.KubeadmCertsSecret),
+			doUploadKubeadmCerts(d),
+		})
+}
+
+// doUploadKubeadmCerts uploads the certificates to the "kubeadm-certs" Secret, the same
+// way a `kubeadm init phase upload-certs` would do. The Secret is owned by a new short-lived
+// bootstrap token (not the token used for joining), so it is removed when this token expires.
+func doUploadKubeadmCerts(d *schema.ResourceData) ssh.Action {
+	token, err := common.GetRandomToken()
+	if err != nil {
+		return ssh.ActionError(fmt.Sprintf("cannot create new random token: %s", err))
+	}
+	tokenManifest, tokenSecret, err := getKubeadmCertsTokenManifest(token)
+	if err != nil {
+		return ssh.ActionError(err.Error())
+	}
+
+	var uid bytes.Buffer
+	return ssh.ActionList{
+		doRemoteKubectlApply(d, []ssh.Manifest{{Inline: tokenManifest}}),
+		ssh.DoSendingExecOutputToWriter(doRemoteKubectl(d, fmt.Sprintf(kubectlGetSecretUIDCmd, tokenSecret)), &uid),
+		ssh.ActionFunc(func(ctx context.Context) ssh.Action {
+			manifest, err := getKubeadmCertsManifest(d, tokenSecret, strings.TrimSpace(uid.String()))
+			if err != nil {
+				return ssh.ActionError(err.Error())
+			}
+			return doRemoteKubectlApply(d, []ssh.Manifest{{Inline: manifest}})
+		}),
+	}
+}
+
+// getKubeadmCertsTokenManifest returns the manifest for the bootstrap token that owns the
+// "kubeadm-certs" Secret (like the token created by kubeadm in `upload-certs`), as well
+// as the name of its Secret. This token expires after kubeadm's DefaultCertTokenDuration.
+func getKubeadmCertsTokenManifest(token string) (string, string, error) {
+	bts, err := kubeadmapi.NewBootstrapTokenString(token)
+	if err != nil {
+		return "", "", err
+	}
+	bt := kubeadmapi.BootstrapToken{
+		Token:       bts,
+		Description: "Proxy for managing TTL for the kubeadm-certs secret",
+		TTL:         &metav1.Duration{Duration: kubeadmconstants.DefaultCertTokenDuration},
+	}
+
+	secret := bt.ToSecret()
+	secret.TypeMeta = metav1.TypeMeta{APIVersion: "v1", Kind: "Secret"}
+	manifest, err := yaml.Marshal(secret)
+	if err != nil {
+		return "", "", fmt.Errorf("could not serialize the kubeadm certificates token: %s", err)
+	}
+	return string(manifest), secret.Name, nil
+}
+
+// getKubeadmCertsManifest returns the manifest for the "kubeadm-certs" Secret (owned by
+// the given token Secret), as well as the RBAC rules for reading it while joining
+func getKubeadmCertsManifest(d *schema.ResourceData, tokenSecret string, tokenSecretUID string) (string, error) {
+	certsConfig := &common.CertsConfig{}
+	if err := certsConfig.FromResourceDataConfig(d); err != nil {
+		return "", fmt.Errorf("no certificates data in config")
+	}
+
+	extra := map[string][]byte{}
+	if isExternalEtcdFromResourceData(d) {
+		for name, secretName := range externalEtcdCertsSecretNames {
+			extra[secretName] = []byte{}
+			raw, ok := d.GetOk("config." + name)
+			if !ok || len(raw.(string)) == 0 {
+				continue
+			}
+			contents, err := common.FromTerraformSafeString(raw.(string))
+			if err != nil {
+				return "", fmt.Errorf("could not decode %s: %s", name, err)
+			}
+			extra[secretName] = contents
+		}
+	}
+
+	data, err := certsConfig.KubeadmCertsSecretData(getCertificateKeyFromResourceData(d), extra)
+	if err != nil {
+		return "", err
+	}
+
+	secret := &corev1.Secret{
+		TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "Secret"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      kubeadmconstants.KubeadmCertsSecret,
+			Namespace: metav1.NamespaceSystem,
+		},
+		Data: data,
+	}
+	if len(tokenSecretUID) > 0 {
+		controller := true
+		secret.OwnerReferences = []metav1.OwnerReference{{
+			APIVersion:         "v1",
+			Kind:               "Secret",
+			Name:               tokenSecret,
+			UID:                types.UID(tokenSecretUID),
+			Controller:         &controller,
+			BlockOwnerDeletion: &controller,
+		}}
+	}
+
+	role := &rbacv1.Role{
+		TypeMeta: metav1.TypeMeta{APIVersion: rbacv1.SchemeGroupVersion.String(), Kind: "Role"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      kubeadmconstants.KubeadmCertsClusterRoleName,
+			Namespace: metav1.NamespaceSystem,
+		},
+		Rules: []rbacv1.PolicyRule{{
+			APIGroups:     []string{""},
+			Resources:     []string{"secrets"},
+			ResourceNames: []string{kubeadmconstants.KubeadmCertsSecret},
+			Verbs:         []string{"get"},
+		}},
+	}
+
+	roleBinding := &rbacv1.RoleBinding{
+		TypeMeta: metav1.TypeMeta{APIVersion: rbacv1.SchemeGroupVersion.String(), Kind: "RoleBinding"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      kubeadmconstants.KubeadmCertsClusterRoleName,
+			Namespace: metav1.NamespaceSystem,
+		},
+		RoleRef: rbacv1.RoleRef{
+			APIGroup: rbacv1.GroupName,
+			Kind:     "Role",
+			Name:     kubeadmconstants.KubeadmCertsClusterRoleName,
+		},
+		Subjects: []rbacv1.Subject{{
+			Kind: rbacv1.GroupKind,
+			Name: kubeadmconstants.NodeBootstrapTokenAuthGroup,
+		}},
+	}
+
+	docs := []string{}
+	for _, obj := range []interface{}{secret, role, roleBinding} {
+		doc, err := yaml.Marshal(obj)
+		if err != nil {
+			return "", fmt.Errorf("could not serialize the kubeadm certificates manifest: %s", err)
+		}
+		docs = append(docs, string(doc))
+	}
+	return strings.Join(docs, "---\n"), nil
+}
@@ -0,0 +1,124 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/ghodss/yaml"
+	"github.com/hashicorp/terraform/helper/schema"
+	corev1 "k8s.io/api/core/v1"
+	cryptoutil "k8s.io/kubernetes/cmd/kubeadm/app/util/crypto"
+)
+
+func TestGetKubeadmCertsManifest(t *testing.T) {
+	const key = "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	const caCrt = "-- BEGIN PUBLIC KEY ---\n SOME-CERT ..."
+
+	d := schema.TestResourceDataRaw(t, Provisioner().(*schema.Provisioner).Schema, map[string]interface{}{
+		"config": map[string]interface{}{
+			"cert_distribution": "kubeadm",
+			"certificate_key":   key,
+			"ca_crt":            caCrt,
+		},
+	})
+
+	manifest, err := getKubeadmCertsManifest(d, "bootstrap-token-abcdef", "1234-5678")
+	if err != nil {
+		t.Fatalf("Error: could not get the kubeadm-certs manifest: %s", err)
+	}
+	for _, expected := range []string{
+		"kind: Role\n",
+		"kind: RoleBinding\n",
+		"name: kubeadm:kubeadm-certs",
+		"name: system:bootstrappers:kubeadm:default-node-token",
+		"name: bootstrap-token-abcdef",
+		"uid: 1234-5678",
+	} {
+		if !strings.Contains(manifest, expected) {
+			t.Fatalf("Error: %q not found in kubeadm-certs manifest:\n%s", expected, manifest)
+		}
+	}
+
+	secret := corev1.Secret{}
+	if err := yaml.Unmarshal([]byte(strings.Split(manifest, "---\n")[0]), &secret); err != nil {
+		t.Fatalf("Error: could not parse the kubeadm-certs Secret: %s", err)
+	}
+	if secret.Name != "kubeadm-certs" || secret.Namespace != "kube-system" {
+		t.Fatalf("Error: unexpected Secret %s/%s", secret.Namespace, secret.Name)
+	}
+	if _, ok := secret.Data["external-etcd.crt"]; ok {
+		t.Fatalf("Error: external etcd certificates found when not using an external etcd")
+	}
+	decodedKey, _ := hex.DecodeString(key)
+	decrypted, err := cryptoutil.DecryptBytes(secret.Data["ca.crt"], decodedKey)
+	if err != nil {
+		t.Fatalf("Error: could not decrypt the CA certificate: %s", err)
+	}
+	if string(decrypted) != caCrt {
+		t.Fatalf("Error: unexpected CA certificate %q", decrypted)
+	}
+}
+
+func TestKubeadmCertsArgs(t *testing.T) {
+	d := schema.TestResourceDataRaw(t, Provisioner().(*schema.Provisioner).Schema, map[string]interface{}{
+		"config": map[string]interface{}{
+			"cert_distribution": "kubeadm",
+			"certificate_key":   "0123456789abcdef",
+		},
+	})
+	initArgs := strings.Join(getKubeadmInitCertsArgs(d), " ")
+	if !strings.Contains(initArgs, "--upload-certs") || !strings.Contains(initArgs, "--certificate-key=0123456789abcdef") {
+		t.Fatalf("Error: unexpected init arguments %q", initArgs)
+	}
+	joinArgs := strings.Join(getKubeadmJoinCertsArgs(d), " ")
+	if joinArgs != "--certificate-key=0123456789abcdef" {
+		t.Fatalf("Error: unexpected join arguments %q", joinArgs)
+	}
+
+	// certificates distributed with ssh: no extra arguments
+	d = schema.TestResourceDataRaw(t, Provisioner().(*schema.Provisioner).Schema, map[string]interface{}{})
+	if len(getKubeadmInitCertsArgs(d)) > 0 || len(getKubeadmJoinCertsArgs(d)) > 0 {
+		t.Fatalf("Error: unexpected arguments when distributing certificates with ssh")
+	}
+}
+
+func TestGetKubeadmCertsTokenManifest(t *testing.T) {
+	manifest, secretName, err := getKubeadmCertsTokenManifest("abcdef.0123456789abcdef")
+	if err != nil {
+		t.Fatalf("Error: could not get the kubeadm-certs token manifest: %s", err)
+	}
+	if secretName != "bootstrap-token-abcdef" {
+		t.Fatalf("Error: unexpected name for the token Secret: %q", secretName)
+	}
+
+	secret := corev1.Secret{}
+	if err := yaml.Unmarshal([]byte(manifest), &secret); err != nil {
+		t.Fatalf("Error: could not parse the token Secret: %s", err)
+	}
+	if secret.Kind != "Secret" || secret.Namespace != "kube-system" || secret.Type != "bootstrap.kubernetes.io/token" {
+		t.Fatalf("Error: unexpected token Secret:\n%s", manifest)
+	}
+	if _, ok := secret.Data["expiration"]; !ok {
+		t.Fatalf("Error: the token for the kubeadm-certs Secret must expire:\n%s", manifest)
+	}
+	for k := range secret.Data {
+		if strings.HasPrefix(k, "usage-bootstrap-") {
+			t.Fatalf("Error: the token for the kubeadm-certs Secret must not be usable (found %q)", k)
+		}
+	}
+}
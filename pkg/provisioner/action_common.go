@@ -23,12 +23,23 @@ import (
 	"strings"
 
 	"github.com/hashicorp/terraform/helper/schema"
+	corev1 "k8s.io/api/core/v1"
+	kubeadmapi "k8s.io/kubernetes/cmd/kubeadm/app/apis/kubeadm"
+	kubeadmconstants "k8s.io/kubernetes/cmd/kubeadm/app/constants"
 
 	"github.com/inercia/terraform-provider-kubeadm/internal/assets"
 	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
 	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
 )
 
+const (
+	// the variable with the extra arguments for the kubelet in the sysconfig file
+	kubeletExtraArgsVar = "KUBELET_EXTRA_ARGS"
+
+	// preflight check that fails when there are static pods (ie, the kube-vip manifest) before the `kubeadm init/join`
+	kubeVipIgnoredCheck = "DirAvailable--etc-kubernetes-manifests"
+)
+
 // expectedBinaries is the list of expected binaries to be present in the remote machine
 var expectedBinaries = []struct {
 	name        string
@@ -55,8 +66,8 @@ var expectedBinaries = []struct {
 	//},
 }
 
-// getKubeadmIgnoredChecksArg returns the kubeadm arguments for the ignored checks
-func getKubeadmIgnoredChecksArg(d *schema.ResourceData) string {
+// getKubeadmIgnoredChecksArg returns the kubeadm arguments for the ignored checks in a `kubeadm <command>`
+func getKubeadmIgnoredChecksArg(d *schema.ResourceData, command string) string {
 	ignoredChecks := common.DefIgnorePreflightChecks[:]
 	if checksOptRaw, ok := d.GetOk("ignore_checks"); ok {
 		checksOpts := checksOptRaw.([]interface{})
@@ -64,6 +75,11 @@ func getKubeadmIgnoredChecksArg(d *schema.ResourceData) string {
 			ignoredChecks = append(ignoredChecks, check.(string))
 		}
 	}
+	if (command == "init" || command == "join") && hasKubeVipManifest(d) {
+		// the kube-vip manifest is uploaded to the manifests directory before
+		// the `kubeadm init/join` (see doUploadKubeVip), so it will not be empty
+		ignoredChecks = append(ignoredChecks, kubeVipIgnoredCheck)
+	}
 	ignoredChecks = common.StringSliceUnique(ignoredChecks) // remove all the duplicates
 
 	if len(ignoredChecks) > 0 {
@@ -81,8 +97,10 @@ func doExecKubeadmWithConfig(d *schema.ResourceData, command string, cfg string,
 	allArgs := []string{}
 	switch command {
 	case "init", "join":
-		allArgs = append(allArgs, getKubeadmIgnoredChecksArg(d))
+		allArgs = append(allArgs, getKubeadmIgnoredChecksArg(d, command))
 		allArgs = append(allArgs, fmt.Sprintf("--config=%s", cfg))
+	case "upgrade apply":
+		allArgs = append(allArgs, getKubeadmIgnoredChecksArg(d, command))
 	}
 
 	// increase kubeadm verbosity if we are debugging at the Terraform level
@@ -109,8 +127,7 @@ func doKubeadm(d *schema.ResourceData, kubeadmConfigFilename string, command str
 				ssh.DoMessageWarn("kubeadm failed: dumping logs..."),
 				ssh.DoMessageWarn("- kubelet logs:"),
 				ssh.DoExec("systemctl --no-pager -l status kubelet"),
-				ssh.DoMessageWarn("- docker logs:"),
-				ssh.DoExec("systemctl --no-pager -l status docker"),
+				ssh.DoPrintContainerRuntimeStatus(getContainerRuntimeFromResourceData(d)),
 				ssh.DoMessageWarn("- last lines in the journal:"),
 				ssh.DoExec("journalctl -e --no-pager | tail -n 20"),
 				ssh.DoTry(ssh.DoDeleteFile(kubeadmConfigFilename)),
@@ -182,6 +199,137 @@ func doUploadCerts(d *schema.ResourceData) ssh.Action {
 	return actions
 }
 
+// doUploadEncryptionConfig uploads the configuration for encrypting Secrets at rest (if present)
+// we only do this on the control plane machines
+func doUploadEncryptionConfig(d *schema.ResourceData) ssh.Action {
+	encryptionConfigRaw, ok := d.GetOk("config.encryption_config")
+	if !ok || len(encryptionConfigRaw.(string)) == 0 {
+		return nil
+	}
+
+	encryptionConfig, err := common.FromTerraformSafeString(encryptionConfigRaw.(string))
+	if err != nil {
+		return ssh.ActionError(fmt.Sprintf("could not decode the encryption configuration: %s", err))
+	}
+
+	return ssh.ActionList{
+		ssh.DoMessageInfo("Uploading encryption configuration..."),
+		ssh.DoUploadBytesToFile(encryptionConfig, common.DefEncryptionConfigPath),
+		ssh.DoExec(fmt.Sprintf("chmod 600 %s", common.DefEncryptionConfigPath)),
+	}
+}
+
+// externalEtcdCerts are the certificates (in the `config`) for accessing an external
+// etcd cluster, and where they must be written in the control plane machines
+var externalEtcdCerts = []struct {
+	name string
+	path string
+}{
+	{"etcd_ca_crt", common.DefExternalEtcdCACrtPath},
+	{"etcd_client_crt", common.DefExternalEtcdClientCrtPath},
+	{"etcd_client_key", common.DefExternalEtcdClientKeyPath},
+}
+
+// doUploadExternalEtcdCerts uploads the certificates for accessing an external etcd cluster (if present)
+// we only do this on the control plane machines
+func doUploadExternalEtcdCerts(d *schema.ResourceData) ssh.Action {
+	actions := ssh.ActionList{}
+	for _, cert := range externalEtcdCerts {
+		raw, ok := d.GetOk("config." + cert.name)
+		if !ok || len(raw.(string)) == 0 {
+			continue
+		}
+		contents, err := common.FromTerraformSafeString(raw.(string))
+		if err != nil {
+			return ssh.ActionError(fmt.Sprintf("could not decode %s: %s", cert.name, err))
+		}
+		ssh.Debug("will upload external etcd certificate to %q", cert.path)
+		actions = append(actions, ssh.DoUploadBytesToFile(contents, cert.path))
+		if strings.HasSuffix(cert.path, ".key") {
+			actions = append(actions, ssh.DoExec(fmt.Sprintf("chmod 600 %s", cert.path)))
+		}
+	}
+	if len(actions) == 0 {
+		return nil
+	}
+
+	return ssh.ActionList{
+		ssh.DoMessageInfo("Uploading certificates for the external etcd cluster..."),
+		actions,
+	}
+}
+
+// doUploadAuditConfig uploads the audit policy and the webhook configuration (if present)
+// we only do this on the control plane machines
+func doUploadAuditConfig(d *schema.ResourceData) ssh.Action {
+	auditPolicyRaw, ok := d.GetOk("config.audit_policy")
+	if !ok || len(auditPolicyRaw.(string)) == 0 {
+		return nil
+	}
+
+	auditPolicy, err := common.FromTerraformSafeString(auditPolicyRaw.(string))
+	if err != nil {
+		return ssh.ActionError(fmt.Sprintf("could not decode the audit policy: %s", err))
+	}
+
+	actions := ssh.ActionList{
+		ssh.DoMessageInfo("Uploading audit policy..."),
+		ssh.DoUploadBytesToFile(auditPolicy, common.DefAuditPolicyPath),
+	}
+
+	if webhookConfigRaw, ok := d.GetOk("config.audit_webhook_config"); ok && len(webhookConfigRaw.(string)) > 0 {
+		webhookConfig, err := common.FromTerraformSafeString(webhookConfigRaw.(string))
+		if err != nil {
+			return ssh.ActionError(fmt.Sprintf("could not decode the audit webhook configuration: %s", err))
+		}
+		actions = append(actions,
+			ssh.DoMessageInfo("Uploading audit webhook configuration..."),
+			ssh.DoUploadBytesToFile(webhookConfig, common.DefAuditWebhookConfigPath),
+			ssh.DoExec(fmt.Sprintf("chmod 600 %s", common.DefAuditWebhookConfigPath)),
+		)
+	}
+
+	return actions
+}
+
+// doUploadKubeVip uploads the kube-vip static pod manifest (when a virtual IP is used
+// for the control plane), so the virtual IP is announced as soon as the kubelet starts.
+// We only do this on the control plane machines
+func doUploadKubeVip(d *schema.ResourceData) ssh.Action {
+	manifest, err := getKubeVipManifest(d)
+	if err != nil {
+		return ssh.ActionError(err.Error())
+	}
+	if len(manifest) == 0 {
+		return nil
+	}
+
+	return ssh.ActionList{
+		ssh.DoMessageInfo("Uploading kube-vip manifest for the virtual IP %s...", getAPIVipFromResourceData(d)),
+		ssh.DoUploadBytesToFile(manifest, common.DefKubeVipManifestPath),
+	}
+}
+
+// hasKubeVipManifest returns true if the kube-vip manifest must be uploaded
+// to this machine: a control plane machine when a virtual IP is used
+func hasKubeVipManifest(d *schema.ResourceData) bool {
+	return isControlPlaneFromResourceData(d) && len(getAPIVipFromResourceData(d)) > 0
+}
+
+// getKubeVipManifest returns the kube-vip static pod manifest, or nothing
+// when this machine does not need it (see hasKubeVipManifest)
+func getKubeVipManifest(d *schema.ResourceData) ([]byte, error) {
+	if !hasKubeVipManifest(d) {
+		return nil, nil
+	}
+
+	manifest := ssh.Manifest{Inline: assets.KubeVipManifestCode}
+	if err := manifest.ReplaceConfig(common.GetProvisionerConfig(d)); err != nil {
+		return nil, fmt.Errorf("could not replace variables in kube-vip manifest: %s", err)
+	}
+	return []byte(manifest.Inline), nil
+}
+
 // doLoadCloudProviderManager uploads the cloud-config to /etc/kubernetes/cloud.conf if necessary
 func doLoadCloudProviderManager(d *schema.ResourceData) ssh.Action {
 	cloudProviderRaw, ok := d.GetOk("config.cloud_provider")
@@ -242,27 +390,142 @@ func doCheckCommonBinaries(d *schema.ResourceData) ssh.Action {
 // doUploadResolvConf (maybe) uploads some configuration for DNS upstream servers
 // this is only done when the "dns.upstream" has been configured in the "resource"
 func doUploadResolvConf(d *schema.ResourceData) ssh.Action {
-	dRaw, ok := d.GetOk("config.dns_upstream")
-	if !ok {
+	resolvConf := getUpstreamResolvConf(d)
+	if len(resolvConf) == 0 {
 		return nil
 	}
 
-	upstreamStr := dRaw.(string)
-	if len(upstreamStr) == 0 {
+	return ssh.ActionList{
+		ssh.DoMessageInfo("Using user-provided upstream DNS resolvers: %+v", getUpstreamDNSServers(d)),
+		ssh.DoUploadBytesToFile(resolvConf, common.DefResolvUpstreamConf),
+	}
+}
+
+// getUpstreamDNSServers returns the upstream DNS servers in the config
+func getUpstreamDNSServers(d *schema.ResourceData) []string {
+	dRaw, ok := d.GetOk("config.dns_upstream")
+	if !ok {
 		return nil
 	}
+	return strings.Fields(dRaw.(string))
+}
 
+// getUpstreamResolvConf returns a resolv.conf with the upstream DNS servers
+// (or nothing when no upstream servers have been configured)
+func getUpstreamResolvConf(d *schema.ResourceData) []byte {
 	buf := bytes.Buffer{}
-	servers := strings.Split(strings.TrimSpace(upstreamStr), " ")
-	for _, server := range servers {
-		if server == "" {
+	for _, server := range getUpstreamDNSServers(d) {
+		buf.WriteString(fmt.Sprintf("nameserver %s\n", server))
+	}
+	return buf.Bytes()
+}
+
+// getKubeletSysconfig returns the kubelet sysconfig file, with the
+// `kubelet_args` (if any) added to the KUBELET_EXTRA_ARGS
+func getKubeletSysconfig(d *schema.ResourceData) []byte {
+	args := getKubeletArgsFromResourceData(d)
+	if len(args) == 0 {
+		return []byte(assets.KubeletSysconfigCode)
+	}
+
+	prefix := kubeletExtraArgsVar + "="
+	lines := strings.Split(assets.KubeletSysconfigCode, "\n")
+	for i, line := range lines {
+		if !strings.HasPrefix(line, prefix) {
 			continue
 		}
-		buf.WriteString(fmt.Sprintf("nameserver %s\n", server))
+		current := strings.Trim(strings.TrimPrefix(line, prefix), `"`)
+		lines[i] = fmt.Sprintf(`%s"%s"`, prefix, strings.TrimSpace(current+" "+strings.Join(args, " ")))
 	}
+	return []byte(strings.Join(lines, "\n"))
+}
+
+// setNodeRegistration sets the nodename, the labels and the taints
+// in the registration options for `kubeadm init/join`
+func setNodeRegistration(d *schema.ResourceData, nodeReg *kubeadmapi.NodeRegistrationOptions) error {
+	nodeReg.Name = getNodenameFromResourceData(d)
 
+	labels, err := getNodeLabelsFromResourceData(d)
+	if err != nil {
+		return err
+	}
+	if len(labels) > 0 {
+		if nodeReg.KubeletExtraArgs == nil {
+			nodeReg.KubeletExtraArgs = map[string]string{}
+		}
+		// keep any label already present in the kubelet arguments
+		nodeLabels, err := common.ParseLabels(nodeReg.KubeletExtraArgs["node-labels"])
+		if err != nil {
+			return err
+		}
+		for k, v := range labels {
+			nodeLabels[k] = v
+		}
+		nodeReg.KubeletExtraArgs["node-labels"] = common.LabelsToString(nodeLabels)
+	}
+
+	taints, err := getNodeTaintsFromResourceData(d)
+	if err != nil {
+		return err
+	}
+	if len(taints) > 0 {
+		// note: kubeadm only adds the control plane taint when no taints are provided
+		if isControlPlaneFromResourceData(d) && !getSchedulableMastersFromResourceData(d) {
+			res := []corev1.Taint{kubeadmconstants.ControlPlaneTaint}
+			for _, taint := range taints {
+				res = mergeTaint(res, taint)
+			}
+			taints = res
+		}
+		nodeReg.Taints = taints
+	}
+	return nil
+}
+
+// doMaybeRemoveControlPlaneTaint removes the taint kubeadm adds to the control plane
+// nodes when the masters must be schedulable.
+// Note that kubeadm only taints the node when no taints are provided, but an empty
+// list of taints is dropped from the kubeadm configuration file, so we must remove
+// the taint once the node has been registered.
+func doMaybeRemoveControlPlaneTaint(d *schema.ResourceData) ssh.Action {
+	remove, err := mustRemoveControlPlaneTaint(d)
+	if err != nil {
+		return ssh.ActionError(err.Error())
+	}
+	if !remove {
+		return nil
+	}
+
+	localKubeNode := ssh.KubeNode{}
 	return ssh.ActionList{
-		ssh.DoMessageInfo("Using user-provided upstream DNS resolvers: %+v", servers),
-		ssh.DoUploadBytesToFile(buf.Bytes(), common.DefResolvUpstreamConf),
+		DoGetNodename(d, &localKubeNode),
+		ssh.ActionFunc(func(ctx context.Context) ssh.Action {
+			if localKubeNode.IsEmpty() {
+				return ssh.ActionError("could not find Kubernetes nodename for this node")
+			}
+			nodename := localKubeNode.Nodename
+			taint := common.TaintToString(kubeadmconstants.ControlPlaneTaint)
+
+			ssh.Debug("removing the control plane taint %q from %q", taint, nodename)
+			return ssh.ActionList{
+				ssh.DoMessageInfo("Making master %q schedulable...", nodename),
+				// (the taint could have been removed in a previous run)
+				ssh.DoTry(doRemoteKubectl(d, "taint", "node", nodename, taint+"-")),
+			}
+		}),
+	}
+}
+
+// mustRemoveControlPlaneTaint returns true if the control plane taint added
+// by kubeadm must be removed from this node
+func mustRemoveControlPlaneTaint(d *schema.ResourceData) (bool, error) {
+	if !isControlPlaneFromResourceData(d) || !getSchedulableMastersFromResourceData(d) {
+		return false, nil
+	}
+	taints, err := getNodeTaintsFromResourceData(d)
+	if err != nil {
+		return false, err
 	}
+	// when some taints are provided, kubeadm uses them instead of the control plane taint
+	return len(taints) == 0, nil
 }
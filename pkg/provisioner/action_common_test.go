@@ -0,0 +1,86 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+func TestGetKubeVipManifest(t *testing.T) {
+	d := schema.TestResourceDataRaw(t, Provisioner().(*schema.Provisioner).Schema, map[string]interface{}{
+		"config": map[string]interface{}{
+			"api_vip":           "10.0.0.100",
+			"api_vip_interface": "ens3",
+			"api_vip_version":   "v0.3.8",
+			"api_port":          "6443",
+		},
+	})
+
+	manifest, err := getKubeVipManifest(d)
+	if err != nil {
+		t.Fatalf("Error: could not get the kube-vip manifest: %s", err)
+	}
+	for _, expected := range []string{
+		"image: ghcr.io/kube-vip/kube-vip:v0.3.8",
+		`value: "10.0.0.100"`,
+		`value: "ens3"`,
+		`value: "6443"`,
+	} {
+		if !strings.Contains(string(manifest), expected) {
+			t.Fatalf("Error: %q not found in kube-vip manifest:\n%s", expected, manifest)
+		}
+	}
+	if !strings.Contains(getKubeadmIgnoredChecksArg(d, "init"), kubeVipIgnoredCheck) {
+		t.Fatalf("Error: %q is not ignored in masters with a virtual IP", kubeVipIgnoredCheck)
+	}
+	if strings.Contains(getKubeadmIgnoredChecksArg(d, "upgrade apply"), kubeVipIgnoredCheck) {
+		t.Fatalf("Error: %q is ignored in an upgrade", kubeVipIgnoredCheck)
+	}
+
+	// workers: no manifest
+	d = schema.TestResourceDataRaw(t, Provisioner().(*schema.Provisioner).Schema, map[string]interface{}{
+		"config": map[string]interface{}{
+			"api_vip": "10.0.0.100",
+		},
+		"join": "10.0.0.1",
+		"role": "worker",
+	})
+	manifest, err = getKubeVipManifest(d)
+	if err != nil {
+		t.Fatalf("Error: could not get the kube-vip manifest: %s", err)
+	}
+	if len(manifest) > 0 {
+		t.Fatalf("Error: unexpected kube-vip manifest in a worker:\n%s", manifest)
+	}
+	if strings.Contains(getKubeadmIgnoredChecksArg(d, "join"), kubeVipIgnoredCheck) {
+		t.Fatalf("Error: %q is ignored in workers", kubeVipIgnoredCheck)
+	}
+
+	// no virtual IP: no manifest
+	d = schema.TestResourceDataRaw(t, Provisioner().(*schema.Provisioner).Schema, map[string]interface{}{})
+	manifest, err = getKubeVipManifest(d)
+	if err != nil {
+		t.Fatalf("Error: could not get the kube-vip manifest: %s", err)
+	}
+	if len(manifest) > 0 {
+		t.Fatalf("Error: unexpected kube-vip manifest:\n%s", manifest)
+	}
+	if strings.Contains(getKubeadmIgnoredChecksArg(d, "init"), kubeVipIgnoredCheck) {
+		t.Fatalf("Error: %q is ignored with no virtual IP", kubeVipIgnoredCheck)
+	}
+}
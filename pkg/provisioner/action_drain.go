@@ -23,10 +23,22 @@ import (
 )
 
 func doRemoveNode(d *schema.ResourceData) ssh.Action {
+	// etcd members are not Kubernetes nodes
+	if getRoleFromResourceData(d) == "etcd" {
+		return ssh.ActionList{
+			ssh.DoMessageInfo("Preparing to remove etcd member..."),
+			doCheckEtcdMemberRemoval(d),
+			doRemoveIfMember(d),
+		}
+	}
+
 	return ssh.ActionList{
 		ssh.DoMessageInfo("Preparing to remove node from cluster..."),
+		// note: we must check the etcd quorum before draining the node (and
+		//       again before removing the member, in doRemoveIfMember)
+		doCheckEtcdMemberRemoval(d),
 		ssh.DoTry(doDrainKubernetesNode(d)),
-		ssh.DoTry(doRemoveIfMember(d)),
+		doRemoveIfMember(d),
 	}
 }
 
@@ -0,0 +1,171 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/terraform/helper/schema"
+	kubeadmapi "k8s.io/kubernetes/cmd/kubeadm/app/apis/kubeadm"
+	kubeadmconstants "k8s.io/kubernetes/cmd/kubeadm/app/constants"
+
+	"github.com/inercia/terraform-provider-kubeadm/internal/assets"
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
+)
+
+const (
+	// command for getting the names and addresses of this machine
+	etcdLocalAddressesCmd = "hostname ; hostname -f ; hostname -I"
+)
+
+// doKubeadmEtcdMember bootstraps a standalone etcd member (of an external etcd cluster)
+// with `kubeadm init phase etcd local`. The members of the etcd cluster are obtained
+// from the external etcd endpoints in the `config`, and the certificates are signed
+// by the etcd CA generated by the provider.
+// See https://kubernetes.io/docs/setup/production-environment/tools/kubeadm/setup-ha-etcd-with-kubeadm/
+func doKubeadmEtcdMember(d *schema.ResourceData) ssh.Action {
+	initConfig, _, err := common.InitConfigFromResourceData(d)
+	if err != nil {
+		return ssh.ActionError(fmt.Sprintf("could not get a valid 'config' for the etcd member: %s", err))
+	}
+	if initConfig.Etcd.External == nil || len(initConfig.Etcd.External.Endpoints) == 0 {
+		return ssh.ActionError("no etcd endpoints found in 'config': the 'etcd' role requires some 'etcd.endpoints' in the kubeadm resource")
+	}
+	if !getEtcdManagedFromResourceData(d) {
+		return ssh.ActionError("the etcd cluster is not managed: the 'etcd' role requires 'etcd.managed = true' in the kubeadm resource")
+	}
+
+	members, err := getEtcdMembers(initConfig.Etcd.External.Endpoints)
+	if err != nil {
+		return ssh.ActionError(err.Error())
+	}
+
+	certsConfig := &common.CertsConfig{}
+	if err := certsConfig.FromResourceDataConfig(d); err != nil || len(certsConfig.EtcdCrt) == 0 || len(certsConfig.EtcdKey) == 0 {
+		return ssh.ActionError("no etcd CA found in 'config'")
+	}
+
+	dropinPath := path.Join(filepath.Dir(getDropinPathFromResourceData(d)), common.DefKubeletEtcdDropinName)
+	etcdConfigArg := fmt.Sprintf("--config=%s", common.DefKubeadmEtcdConfPath)
+
+	var addresses bytes.Buffer
+	return ssh.ActionList{
+		ssh.DoMessageInfo("Preparing the kubelet for running etcd..."),
+		ssh.DoUploadBytesToFile([]byte(assets.KubeletEtcdDropinCode), dropinPath),
+		ssh.DoExec("systemctl --no-pager daemon-reload"),
+		ssh.DoRestartService("kubelet.service"),
+		ssh.DoSendingExecOutputToWriter(ssh.DoExec(etcdLocalAddressesCmd), &addresses),
+		ssh.ActionFunc(func(ctx context.Context) ssh.Action {
+			member, err := findLocalEtcdMember(members, strings.Fields(addresses.String()))
+			if err != nil {
+				return ssh.ActionError(err.Error())
+			}
+
+			memberConfig := getEtcdMemberConfig(initConfig, member, members)
+			memberConfigBytes, err := common.InitConfigToYAML(memberConfig)
+			if err != nil {
+				return ssh.ActionError(fmt.Sprintf("could not serialize the etcd member configuration: %s", err))
+			}
+
+			certsDir := initConfig.CertificatesDir
+			if len(certsDir) == 0 {
+				certsDir = common.DefPKIDir
+			}
+
+			return ssh.ActionList{
+				ssh.DoMessageInfo("Bootstrapping etcd member %q...", member.Hostname()),
+				ssh.DoUploadBytesToFile([]byte(certsConfig.EtcdCrt), path.Join(certsDir, kubeadmconstants.EtcdCACertName)),
+				ssh.DoUploadBytesToFile([]byte(certsConfig.EtcdKey), path.Join(certsDir, kubeadmconstants.EtcdCAKeyName)),
+				ssh.DoUploadBytesToFile(memberConfigBytes, common.DefKubeadmEtcdConfPath),
+				doExecKubeadmWithConfig(d, "init phase certs etcd-server", "", etcdConfigArg),
+				doExecKubeadmWithConfig(d, "init phase certs etcd-peer", "", etcdConfigArg),
+				doExecKubeadmWithConfig(d, "init phase certs etcd-healthcheck-client", "", etcdConfigArg),
+				doExecKubeadmWithConfig(d, "init phase etcd local", "", etcdConfigArg),
+				ssh.DoMessageInfo("etcd member %q has been started", member.Hostname()),
+			}
+		}),
+	}
+}
+
+// getEtcdMembers parses the list of etcd endpoints
+func getEtcdMembers(endpoints []string) ([]url.URL, error) {
+	members := []url.URL{}
+	for _, endpoint := range endpoints {
+		u, err := url.Parse(endpoint)
+		if err != nil || len(u.Hostname()) == 0 {
+			return nil, fmt.Errorf("could not parse etcd endpoint %q", endpoint)
+		}
+		members = append(members, *u)
+	}
+	return members, nil
+}
+
+// findLocalEtcdMember finds the etcd member that corresponds to this machine,
+// looking for any of the local names/addresses in the list of members
+func findLocalEtcdMember(members []url.URL, localAddresses []string) (url.URL, error) {
+	for _, member := range members {
+		for _, address := range localAddresses {
+			if strings.EqualFold(member.Hostname(), address) {
+				return member, nil
+			}
+		}
+	}
+	return url.URL{}, fmt.Errorf("this machine (%s) is not in the list of etcd endpoints", strings.Join(localAddresses, ", "))
+}
+
+// getEtcdPeerURL returns the peer URL for an etcd member
+func getEtcdPeerURL(member url.URL) string {
+	return fmt.Sprintf("https://%s", net.JoinHostPort(member.Hostname(), strconv.Itoa(kubeadmconstants.EtcdListenPeerPort)))
+}
+
+// getEtcdMemberConfig returns the kubeadm configuration for a standalone etcd member
+func getEtcdMemberConfig(initConfig *kubeadmapi.InitConfiguration, member url.URL, members []url.URL) *kubeadmapi.InitConfiguration {
+	initialCluster := []string{}
+	for _, m := range members {
+		initialCluster = append(initialCluster, fmt.Sprintf("%s=%s", m.Hostname(), getEtcdPeerURL(m)))
+	}
+
+	clientPort := member.Port()
+	if len(clientPort) == 0 {
+		clientPort = strconv.Itoa(kubeadmconstants.EtcdListenClientPort)
+	}
+
+	memberConfig := initConfig.DeepCopy()
+	memberConfig.Etcd = kubeadmapi.Etcd{
+		Local: &kubeadmapi.LocalEtcd{
+			ServerCertSANs: []string{member.Hostname()},
+			PeerCertSANs:   []string{member.Hostname()},
+			ExtraArgs: map[string]string{
+				"name":                        member.Hostname(),
+				"listen-client-urls":          fmt.Sprintf("https://0.0.0.0:%s", clientPort),
+				"advertise-client-urls":       fmt.Sprintf("https://%s", net.JoinHostPort(member.Hostname(), clientPort)),
+				"listen-peer-urls":            fmt.Sprintf("https://0.0.0.0:%d", kubeadmconstants.EtcdListenPeerPort),
+				"initial-advertise-peer-urls": getEtcdPeerURL(member),
+				"initial-cluster":             strings.Join(initialCluster, ","),
+				"initial-cluster-state":       "new",
+			},
+		},
+	}
+	return memberConfig
+}
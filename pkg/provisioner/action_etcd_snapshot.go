@@ -0,0 +1,262 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform/helper/schema"
+
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+)
+
+const (
+	// remote path for the snapshot
+	// note: this directory is mounted in the etcd container at the same path,
+	//       so it can be used both from the host and from `etcdctl`
+	etcdSnapshotRemotePath = "/var/lib/etcd/terraform-snapshot.db"
+
+	// remote directory where the snapshot is restored (in the etcd container)
+	etcdRestoreDataDir = "/var/lib/etcd/terraform-restore"
+
+	// the data directory of etcd
+	etcdDataDir = "/var/lib/etcd"
+
+	// where the previous etcd data is saved when restoring a snapshot
+	etcdDataBackupDir = "/var/lib/etcd-backup"
+
+	// the etcd static pod manifest
+	etcdManifestPath = "/etc/kubernetes/manifests/etcd.yaml"
+
+	// where the etcd manifest is moved while etcd must be stopped
+	etcdManifestStoppedPath = "/etc/kubernetes/etcd.yaml.stopped"
+
+	// extension for the checksum files saved next to the snapshots
+	etcdSnapshotChecksumExt = ".sha256"
+)
+
+// doEtcdSnapshotSave saves a snapshot of the etcd database in a local file.
+// The snapshot is taken with `etcdctl snapshot save` in the etcd container,
+// downloaded and verified against the checksum in the remote machine.
+// A "<snapshot>.sha256" file (in the `sha256sum` format) is saved next to the snapshot.
+func doEtcdSnapshotSave(d *schema.ResourceData, local string) ssh.Action {
+	if isExternalEtcdFromResourceData(d) {
+		return ssh.ActionError("using an external etcd cluster: snapshots must be taken in the 'etcd' members")
+	}
+
+	runtime := getContainerRuntimeFromResourceData(d)
+	var remoteChecksum bytes.Buffer
+	var encoded bytes.Buffer
+
+	return ssh.DoIfElse(
+		ssh.CheckContainerRunning(runtime, etcdContainerName),
+		ssh.DoWithCleanup(
+			ssh.ActionList{
+				ssh.DoMessageInfo("Saving etcd snapshot..."),
+				DoRunEtcdctlSubcommand(runtime, subcmdSnapshotSave, etcdSnapshotRemotePath),
+				ssh.DoSendingExecOutputToWriter(ssh.DoExec(fmt.Sprintf("sha256sum %q", etcdSnapshotRemotePath)), &remoteChecksum),
+				// note: the snapshot is a binary file, so we must encode it for downloading it
+				ssh.DoMessageInfo("Downloading etcd snapshot to %q", local),
+				ssh.DoSendingExecOutputToWriter(ssh.DoExec(fmt.Sprintf("base64 %q", etcdSnapshotRemotePath)), &encoded),
+				ssh.ActionFunc(func(ctx context.Context) ssh.Action {
+					expected, err := parseSha256sumOutput(remoteChecksum.String())
+					if err != nil {
+						return ssh.ActionError(fmt.Sprintf("could not get the checksum of the remote snapshot: %s", err))
+					}
+
+					contents, err := base64.StdEncoding.DecodeString(encoded.String())
+					if err != nil {
+						return ssh.ActionError(fmt.Sprintf("could not decode the etcd snapshot: %s", err))
+					}
+
+					checksum := sha256Sum(contents)
+					if checksum != expected {
+						return ssh.ActionError(fmt.Sprintf("checksum mismatch in downloaded snapshot: got %s, expected %s", checksum, expected))
+					}
+
+					if err := ioutil.WriteFile(local, contents, 0600); err != nil {
+						return ssh.ActionError(fmt.Sprintf("could not write etcd snapshot to %q: %s", local, err))
+					}
+					checksumLine := fmt.Sprintf("%s  %s\n", checksum, filepath.Base(local))
+					if err := ioutil.WriteFile(local+etcdSnapshotChecksumExt, []byte(checksumLine), 0644); err != nil {
+						return ssh.ActionError(fmt.Sprintf("could not write checksum file for %q: %s", local, err))
+					}
+
+					return ssh.DoMessageInfo("etcd snapshot saved to %q (sha256: %s)", local, checksum)
+				}),
+			},
+			ssh.ActionList{
+				ssh.DoTry(ssh.DoDeleteFile(etcdSnapshotRemotePath)),
+			}),
+		ssh.ActionError("etcd is not running in this node: cannot save a snapshot"),
+	)
+}
+
+// doEtcdSnapshotRestore restores a local etcd snapshot in a freshly initialized seeder.
+// The snapshot is restored with the name and peer URL of the etcd member created by
+// `kubeadm init`, and the etcd data directory is replaced while etcd is stopped.
+func doEtcdSnapshotRestore(d *schema.ResourceData, local string) ssh.Action {
+	if isExternalEtcdFromResourceData(d) {
+		return ssh.ActionError("using an external etcd cluster: snapshots cannot be restored from the Kubernetes nodes")
+	}
+
+	runtime := getContainerRuntimeFromResourceData(d)
+	checksum := ""
+	var remoteChecksum bytes.Buffer
+	members := EtcdMembersList{}
+
+	return ssh.ActionList{
+		ssh.DoMessageInfo("Restoring etcd from snapshot %q...", local),
+		ssh.ActionFunc(func(ctx context.Context) ssh.Action {
+			skipChecksum := getEtcdRestoreSkipChecksumFromResourceData(d)
+			var err error
+			checksum, err = verifyLocalSnapshot(local, skipChecksum)
+			if err != nil {
+				return ssh.ActionError(err.Error())
+			}
+			if skipChecksum && !ssh.LocalFileExists(local+etcdSnapshotChecksumExt) {
+				return ssh.DoMessageWarn("No checksum file found for %q: the snapshot has not been verified", local)
+			}
+			return nil
+		}),
+		ssh.DoUploadFileToFile(local, etcdSnapshotRemotePath),
+		ssh.DoWithCleanup(
+			ssh.ActionList{
+				ssh.DoSendingExecOutputToWriter(ssh.DoExec(fmt.Sprintf("sha256sum %q", etcdSnapshotRemotePath)), &remoteChecksum),
+				ssh.ActionFunc(func(ctx context.Context) ssh.Action {
+					uploaded, err := parseSha256sumOutput(remoteChecksum.String())
+					if err != nil {
+						return ssh.ActionError(fmt.Sprintf("could not get the checksum of the uploaded snapshot: %s", err))
+					}
+					if uploaded != checksum {
+						return ssh.ActionError(fmt.Sprintf("checksum mismatch in uploaded snapshot: got %s, expected %s", uploaded, checksum))
+					}
+					return nil
+				}),
+				DoGetMembersList(runtime, &members),
+				ssh.ActionFunc(func(ctx context.Context) ssh.Action {
+					if len(members) != 1 {
+						return ssh.ActionError(fmt.Sprintf("snapshots can only be restored in a new cluster with one etcd member (found %d members)", len(members)))
+					}
+					member := members[0]
+					ssh.Debug("restoring snapshot for etcd member %s", member)
+
+					return ssh.ActionList{
+						ssh.DoExec(fmt.Sprintf("rm -rf %q", etcdRestoreDataDir)),
+						DoRunEtcdctlSubcommand(runtime, subcmdSnapshotRestore, etcdSnapshotRemotePath,
+							fmt.Sprintf("--name=%s", member.Name),
+							fmt.Sprintf("--initial-cluster=%s=%s", member.Name, member.PeerURL),
+							fmt.Sprintf("--initial-advertise-peer-urls=%s", member.PeerURL),
+							fmt.Sprintf("--data-dir=%s", etcdRestoreDataDir)),
+						ssh.DoMessageInfo("Stopping etcd..."),
+						ssh.DoMoveFile(etcdManifestPath, etcdManifestStoppedPath),
+						ssh.DoRetry(
+							ssh.Retry{Times: 12, Interval: 10 * time.Second},
+							ssh.DoIf(
+								ssh.CheckContainerRunning(runtime, etcdContainerName),
+								ssh.ActionError("etcd is still running"))),
+						ssh.DoMessageInfo("Replacing etcd data (previous data saved at %q)...", etcdDataBackupDir),
+						ssh.DoExec(fmt.Sprintf("sh -c 'rm -rf %s && mkdir -p %s && mv %s/member %s/ && mv %s/member %s/'",
+							etcdDataBackupDir, etcdDataBackupDir,
+							etcdDataDir, etcdDataBackupDir,
+							etcdRestoreDataDir, etcdDataDir)),
+						ssh.DoMessageInfo("Starting etcd..."),
+						ssh.DoMoveFile(etcdManifestStoppedPath, etcdManifestPath),
+						ssh.DoRetry(
+							ssh.Retry{Times: 20, Interval: 15 * time.Second},
+							ssh.DoRemoteKubectl(getKubectlFromResourceData(d), "", "cluster-info")),
+						ssh.DoMessageInfo("etcd has been restored from %q", local),
+					}
+				}),
+			},
+			ssh.ActionList{
+				ssh.DoTry(ssh.DoDeleteFile(etcdSnapshotRemotePath)),
+				ssh.DoTry(ssh.DoExec(fmt.Sprintf("rm -rf %q", etcdRestoreDataDir))),
+			}),
+	}
+}
+
+// verifyLocalSnapshot checks a local snapshot against its "<snapshot>.sha256" file,
+// returning the checksum of the snapshot. A missing checksum file is an error
+// unless `skipMissing` is set.
+func verifyLocalSnapshot(local string, skipMissing bool) (string, error) {
+	f, err := os.Open(local)
+	if err != nil {
+		return "", fmt.Errorf("could not open etcd snapshot %q: %s", local, err)
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("could not read etcd snapshot %q: %s", local, err)
+	}
+	checksum := hex.EncodeToString(h.Sum(nil))
+
+	checksumFile := local + etcdSnapshotChecksumExt
+	if !ssh.LocalFileExists(checksumFile) {
+		if !skipMissing {
+			return "", fmt.Errorf("no checksum file %q found for etcd snapshot %q (use 'etcd_restore_skip_checksum = true' for restoring it anyway)", checksumFile, local)
+		}
+		ssh.Debug("no checksum file found for %q: skipping verification", local)
+		return checksum, nil
+	}
+
+	contents, err := ioutil.ReadFile(checksumFile)
+	if err != nil {
+		return "", fmt.Errorf("could not read checksum file %q: %s", checksumFile, err)
+	}
+	expected, err := parseSha256sumOutput(string(contents))
+	if err != nil {
+		return "", fmt.Errorf("could not parse checksum file %q: %s", checksumFile, err)
+	}
+	if checksum != expected {
+		return "", fmt.Errorf("checksum mismatch for etcd snapshot %q: got %s, expected %s", local, checksum, expected)
+	}
+	return checksum, nil
+}
+
+// parseSha256sumOutput gets the checksum from the output of `sha256sum`
+// (ie, "<checksum>  <filename>")
+func parseSha256sumOutput(s string) (string, error) {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return "", fmt.Errorf("empty checksum")
+	}
+	checksum := strings.ToLower(fields[0])
+	if len(checksum) != sha256.Size*2 {
+		return "", fmt.Errorf("invalid checksum %q", checksum)
+	}
+	if _, err := hex.DecodeString(checksum); err != nil {
+		return "", fmt.Errorf("invalid checksum %q", checksum)
+	}
+	return checksum, nil
+}
+
+// sha256Sum returns the hex-encoded SHA256 checksum of some contents
+func sha256Sum(contents []byte) string {
+	sum := sha256.Sum256(contents)
+	return hex.EncodeToString(sum[:])
+}
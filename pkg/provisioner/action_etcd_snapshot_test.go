@@ -0,0 +1,87 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseSha256sumOutput(t *testing.T) {
+	expected := sha256Sum([]byte("some snapshot"))
+
+	checksum, err := parseSha256sumOutput(fmt.Sprintf("%s  /var/lib/etcd/terraform-snapshot.db\n", expected))
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if checksum != expected {
+		t.Fatalf("checksum does not match: %s", checksum)
+	}
+
+	for _, s := range []string{"", "  \n", "1234  file", "sha256sum: file: No such file or directory"} {
+		if _, err := parseSha256sumOutput(s); err == nil {
+			t.Fatalf("Error expected when parsing %q", s)
+		}
+	}
+}
+
+func TestVerifyLocalSnapshot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "snapshot")
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	contents := []byte("some snapshot")
+	snapshot := filepath.Join(dir, "etcd.db")
+	if err := ioutil.WriteFile(snapshot, contents, 0600); err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+
+	// no checksum file: it must fail, unless explicitly skipped
+	if _, err := verifyLocalSnapshot(snapshot, false); err == nil {
+		t.Fatalf("Error expected for a snapshot without a checksum file")
+	}
+	checksum, err := verifyLocalSnapshot(snapshot, true)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if checksum != sha256Sum(contents) {
+		t.Fatalf("checksum does not match: %s", checksum)
+	}
+
+	checksumLine := fmt.Sprintf("%s  etcd.db\n", sha256Sum(contents))
+	if err := ioutil.WriteFile(snapshot+etcdSnapshotChecksumExt, []byte(checksumLine), 0644); err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if _, err := verifyLocalSnapshot(snapshot, false); err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+
+	// a corrupted snapshot must be detected
+	if err := ioutil.WriteFile(snapshot, []byte("some corrupted snapshot"), 0600); err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if _, err := verifyLocalSnapshot(snapshot, false); err == nil {
+		t.Fatalf("Error expected for a snapshot that does not match its checksum")
+	}
+
+	if _, err := verifyLocalSnapshot(filepath.Join(dir, "missing.db"), true); err == nil {
+		t.Fatalf("Error expected for a missing snapshot")
+	}
+}
@@ -0,0 +1,72 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"testing"
+
+	kubeadmapi "k8s.io/kubernetes/cmd/kubeadm/app/apis/kubeadm"
+)
+
+func TestEtcdMemberConfig(t *testing.T) {
+	members, err := getEtcdMembers([]string{
+		"https://10.0.0.1:2379",
+		"https://10.0.0.2:2379",
+		"https://etcd3.example.com:2379",
+	})
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+
+	// this machine is "etcd3"
+	member, err := findLocalEtcdMember(members, []string{"etcd3", "etcd3.example.com", "192.168.1.3"})
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if member.Hostname() != "etcd3.example.com" {
+		t.Fatalf("Error: wrong local member: %s", member.String())
+	}
+
+	// this machine is not a member
+	if _, err := findLocalEtcdMember(members, []string{"master1", "10.0.0.10"}); err == nil {
+		t.Fatalf("Error: no error for a machine that is not a member")
+	}
+
+	initConfig := &kubeadmapi.InitConfiguration{}
+	initConfig.Etcd.External = &kubeadmapi.ExternalEtcd{Endpoints: []string{"https://10.0.0.1:2379"}}
+
+	memberConfig := getEtcdMemberConfig(initConfig, member, members)
+	if memberConfig.Etcd.External != nil || memberConfig.Etcd.Local == nil {
+		t.Fatalf("Error: the etcd member should use a local etcd: %+v", memberConfig.Etcd)
+	}
+	if initConfig.Etcd.External == nil {
+		t.Fatalf("Error: the original configuration has been modified")
+	}
+
+	expectedArgs := map[string]string{
+		"name":                        "etcd3.example.com",
+		"advertise-client-urls":       "https://etcd3.example.com:2379",
+		"initial-advertise-peer-urls": "https://etcd3.example.com:2380",
+		"initial-cluster":             "10.0.0.1=https://10.0.0.1:2380,10.0.0.2=https://10.0.0.2:2380,etcd3.example.com=https://etcd3.example.com:2380",
+	}
+	for k, v := range expectedArgs {
+		if memberConfig.Etcd.Local.ExtraArgs[k] != v {
+			t.Fatalf("Error: wrong %q: %q (expected %q)", k, memberConfig.Etcd.Local.ExtraArgs[k], v)
+		}
+	}
+	if sans := memberConfig.Etcd.Local.ServerCertSANs; len(sans) != 1 || sans[0] != "etcd3.example.com" {
+		t.Fatalf("Error: wrong server cert SANs: %v", sans)
+	}
+}
@@ -27,6 +27,12 @@ import (
 // doKubeadmInit runs the `kubeadm init`
 func doKubeadmInit(d *schema.ResourceData) ssh.Action {
 	extraArgs := []string{"--skip-token-print"}
+	if getSkipKubeProxyFromResourceData(d) {
+		// the CNI plugin will replace kube-proxy
+		extraArgs = append(extraArgs, "--skip-phases=addon/kube-proxy")
+	}
+	// upload the certificates to the cluster when the masters download them with kubeadm
+	extraArgs = append(extraArgs, getKubeadmInitCertsArgs(d)...)
 
 	// get the join configuration
 	initConfig, _, err := common.InitConfigFromResourceData(d)
@@ -34,21 +40,29 @@ func doKubeadmInit(d *schema.ResourceData) ssh.Action {
 		return ssh.ActionError(fmt.Sprintf("could not get a valid 'config' for join'ing: %s", err))
 	}
 
-	// ... update the nodename
-	initConfig.NodeRegistration.Name = getNodenameFromResourceData(d)
+	// ... update the nodename, labels and taints
+	if err := setNodeRegistration(d, &initConfig.NodeRegistration); err != nil {
+		return ssh.ActionError(err.Error())
+	}
 
 	// ... and update the `config.join` section
 	if err := common.InitConfigToResourceData(d, initConfig); err != nil {
 		return ssh.ActionError(err.Error())
 	}
 
-	actions := ssh.ActionList{
+	actions := ssh.ActionList{}
+
+	// remove any previous kubeconfig, unless it has been generated by the provider
+	if len(getKubeconfigContentsFromResourceData(d)) == 0 {
+		actions = append(actions, doDeleteLocalKubeconfig(d))
+	}
+
+	actions = append(actions,
 		// * if a "admin.conf" is there and the cluster is alive, do nothing
 		//   (just try to reload CNI, Helm and so)
 		// * if a partial setup is detected (ie, cluster is not alive but some manifests are there...)
 		//   try to reset the node
 		// * in any other case, do a regular "kubeadm init"
-		doDeleteLocalKubeconfig(d),
 		ssh.DoIfElse(
 			checkAdminConfAlive(d),
 			ssh.ActionList{
@@ -60,23 +74,40 @@ func doKubeadmInit(d *schema.ResourceData) ssh.Action {
 					ssh.ActionList{
 						doMaybeResetMaster(d, common.DefKubeadmInitConfPath),
 						doUploadCerts(d), // (we must upload certs because a "kubeadm reset" wipes them...)
+						doUploadExternalEtcdCerts(d),
+						doUploadEncryptionConfig(d),
+						doUploadAuditConfig(d),
+						doUploadKubeVip(d),
 						ssh.DoMessageInfo("Initializing the cluster with 'kubadm init'..."),
 						doKubeadm(d, common.DefKubeadmInitConfPath, "init", extraArgs...),
 					},
 				),
+				doMaybeRestoreEtcd(d),
 			},
 		),
-		// we always download the kubeconfig and try to do a "kubeactl apply -f" of manifests
-		doDownloadKubeconfig(d),
+		// we always write the kubeconfig and try to do a "kubeactl apply -f" of manifests
+		doWriteLocalKubeconfig(d),
+		doMaybeRemoveControlPlaneTaint(d),
+		doLoadPSP(d),
 		doLoadCNI(d),
 		doLoadDashboard(d),
 		doLoadHelm(d),
 		doLoadCloudProviderManager(d),
 		doLoadExtraManifests(d),
-	}
+	)
 	return actions
 }
 
+// doMaybeRestoreEtcd restores an etcd snapshot after a fresh `kubeadm init`,
+// if a snapshot has been provided
+func doMaybeRestoreEtcd(d *schema.ResourceData) ssh.Action {
+	snapshot := getEtcdRestoreFromResourceData(d)
+	if len(snapshot) == 0 {
+		return nil
+	}
+	return doEtcdSnapshotRestore(d, snapshot)
+}
+
 // doMaybeResetMaster maybe "reset"s the master with kubeadm if
 // it is detected as "partially" setup:
 // ie, /etc/kubernetes/kubeadm-*.conf exist AND /etc/kubernetes/manifests/* exist
@@ -20,6 +20,7 @@ import (
 
 	"github.com/hashicorp/terraform/helper/schema"
 
+	"github.com/inercia/terraform-provider-kubeadm/internal/assets"
 	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
 	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
 )
@@ -46,6 +47,32 @@ func doLoadDashboard(d *schema.ResourceData) ssh.Action {
 	}
 }
 
+// doLoadPSP loads the Pod Security Policies (if enabled)
+// note well: this must be done before loading any other manifest, as pods
+// will not be admitted when no policy can be used.
+func doLoadPSP(d *schema.ResourceData) ssh.Action {
+	opt, ok := d.GetOk("config.psp_enabled")
+	if !ok {
+		return nil
+	}
+	enabled, err := strconv.ParseBool(opt.(string))
+	if err != nil {
+		return ssh.ActionError("could not parse psp_enabled in provisioner")
+	}
+	if !enabled {
+		return nil
+	}
+
+	manifest := ssh.Manifest{Inline: assets.PSPManifestCode}
+	if err := manifest.ReplaceConfig(common.GetProvisionerConfig(d)); err != nil {
+		return ssh.ActionError(fmt.Sprintf("could not replace variables in Pod Security Policies manifest: %s", err))
+	}
+	return ssh.ActionList{
+		ssh.DoMessageInfo("Loading Pod Security Policies"),
+		doRemoteKubectlApply(d, []ssh.Manifest{manifest}),
+	}
+}
+
 // doLoadExtraManifests loads some extra manifests
 func doLoadExtraManifests(d *schema.ResourceData) ssh.Action {
 	manifestsOpt, ok := d.GetOk("manifests")
@@ -26,10 +26,12 @@ import (
 )
 
 const (
-	defHelmReplicas  = 1
-	defHelmNamespace = "kube-system"
-	// defHelmNodeselector = "node-role.kubernetes.io/master="
+	defHelmReplicas     = 1
+	defHelmNamespace    = "kube-system"
 	defHelmNodeselector = ""
+
+	// node selector for running Tiller in the masters (when they are schedulable)
+	defHelmMastersNodeselector = "node-role.kubernetes.io/master="
 )
 
 // doLoadHelm loads Helm (if enabled)
@@ -46,11 +48,17 @@ func doLoadHelm(d *schema.ResourceData) ssh.Action {
 		return ssh.DoMessageWarn("Helm will not be loaded")
 	}
 
+	nodeSelector := defHelmNodeselector
+	if getSchedulableMastersFromResourceData(d) {
+		// run Tiller in the masters, so it is not affected by workers coming and going
+		nodeSelector = defHelmMastersNodeselector
+	}
+
 	opts := installer.Options{
 		Namespace:                    defHelmNamespace,
 		AutoMountServiceAccountToken: true,
 		EnableHostNetwork:            false,
-		NodeSelectors:                defHelmNodeselector,
+		NodeSelectors:                nodeSelector,
 		UseCanary:                    false,
 		Replicas:                     defHelmReplicas,
 		// TODO: we shoud have options for enabling TLS and so...
@@ -42,8 +42,10 @@ func doKubeadmJoinWorker(d *schema.ResourceData) ssh.Action {
 		return ssh.ActionError(fmt.Sprintf("could not get a valid 'config' for join'ing: %s", err))
 	}
 
-	// ... update the nodename
-	joinConfig.NodeRegistration.Name = getNodenameFromResourceData(d)
+	// ... update the nodename, labels and taints
+	if err := setNodeRegistration(d, &joinConfig.NodeRegistration); err != nil {
+		return ssh.ActionError(err.Error())
+	}
 
 	// ... and update the `config.join` section
 	if err := common.JoinConfigToResourceData(d, joinConfig); err != nil {
@@ -86,23 +88,18 @@ func doKubeadmJoinControlPlane(d *schema.ResourceData) ssh.Action {
 		return ssh.ActionError(fmt.Sprintf("could not get a valid 'config' for join'ing: %s", err))
 	}
 	if len(initConfig.ClusterConfiguration.ControlPlaneEndpoint) == 0 {
-		return ssh.ActionError("Cannot create additional masters when the 'kubeadm.<name>.api.external' is empty")
+		return ssh.ActionError("Cannot create additional masters when the 'kubeadm.<name>.api.external' and 'kubeadm.<name>.api.vip' are empty")
 	}
 
 	// add a local Control-Plane section to the JoinConfiguration (that means a new master will be started here)
-	endpoint := kubeadmapi.APIEndpoint{}
-	if hp, ok := d.GetOk("listen"); ok {
-		h, p, err := common.SplitHostPort(hp.(string), common.DefAPIServerPort)
-		if err != nil {
-			return ssh.ActionError(fmt.Sprintf("could not parse listen address %q: %s", hp.(string), err))
-		}
-		endpoint = kubeadmapi.APIEndpoint{AdvertiseAddress: h, BindPort: int32(p)}
-	} else {
-		endpoint = kubeadmapi.APIEndpoint{AdvertiseAddress: "", BindPort: common.DefAPIServerPort}
+	joinConfig.ControlPlane, err = getJoinControlPlane(d)
+	if err != nil {
+		return ssh.ActionError(err.Error())
 	}
-	joinConfig.ControlPlane = &kubeadmapi.JoinControlPlane{LocalAPIEndpoint: endpoint}
 
-	joinConfig.NodeRegistration.Name = getNodenameFromResourceData(d)
+	if err := setNodeRegistration(d, &joinConfig.NodeRegistration); err != nil {
+		return ssh.ActionError(err.Error())
+	}
 
 	// ... and update the `config.join` section in the ResourceData
 	if err := common.JoinConfigToResourceData(d, joinConfig); err != nil {
@@ -125,13 +122,31 @@ func doKubeadmJoinControlPlane(d *schema.ResourceData) ssh.Action {
 			ssh.ActionList{
 				ssh.DoMessageInfo("Trying to join the cluster control-plane with 'kubadm join'..."),
 				doMaybeResetMaster(d, common.DefKubeadmJoinConfPath),
-				doUploadCerts(d), // (we must upload certs because a "kubeadm reset" wipes them...)
-				doKubeadm(d, common.DefKubeadmJoinConfPath, "join"),
+				doDistributeCerts(d),
+				doUploadEncryptionConfig(d),
+				doUploadAuditConfig(d),
+				doUploadKubeVip(d),
+				doKubeadm(d, common.DefKubeadmJoinConfPath, "join", getKubeadmJoinCertsArgs(d)...),
 			}),
+		doMaybeRemoveControlPlaneTaint(d),
 	}
 	return actions
 }
 
+// getJoinControlPlane returns the control plane section of the join configuration
+// for a new master, listening at the `listen` address (when provided)
+func getJoinControlPlane(d *schema.ResourceData) (*kubeadmapi.JoinControlPlane, error) {
+	endpoint := kubeadmapi.APIEndpoint{AdvertiseAddress: "", BindPort: common.DefAPIServerPort}
+	if hp, ok := d.GetOk("listen"); ok {
+		h, p, err := common.SplitHostPort(hp.(string), common.DefAPIServerPort)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse listen address %q: %s", hp.(string), err)
+		}
+		endpoint = kubeadmapi.APIEndpoint{AdvertiseAddress: h, BindPort: int32(p)}
+	}
+	return &kubeadmapi.JoinControlPlane{LocalAPIEndpoint: endpoint}, nil
+}
+
 // doCheckLocalKubeconfigExists checks that there is a local kubeconfig
 func doCheckLocalKubeconfigExists(d *schema.ResourceData) ssh.Action {
 	kubeconfig := getKubeconfigFromResourceData(d)
@@ -30,32 +30,11 @@ import (
 // 2) a user-provided script in some path
 // 3) an inlined user-provided script
 func doKubeadmSetup(d *schema.ResourceData) ssh.Action {
-	if _, ok := d.GetOk("install"); ok {
-		code := ""
-		descr := ""
-		auto := d.Get("install.0.auto").(bool)
-		inline := d.Get("install.0.inline").(string)
-		script := d.Get("install.0.script").(string)
-
-		if auto {
-			ssh.Debug("will upload the builtin auto-installation script")
-			descr = "Uploading and running built-in kubeadm installation script..."
-			code = assets.KubeadmSetupScriptCode
-		} else if len(inline) > 0 {
-			ssh.Debug("will upload auto-installation script from inlined script: %d bytes", len(inline))
-			descr = "Uploading and running inlined installation script..."
-			code = "#!/bin/sh\n" + inline
-		} else if len(script) > 0 {
-			ssh.Debug("will upload auto-installation from custom script from %q", script)
-			descr = fmt.Sprintf("Uploading and running custom kubeadm script from %s...", script)
-			contents, err := ioutil.ReadFile(script)
-			if err != nil {
-				errMsg := fmt.Sprintf("when reading kubeadm setup script %q: %s", script, err.Error())
-				return ssh.ActionError(errMsg)
-			}
-			code = string(contents)
-		}
-
+	code, descr, err := getKubeadmSetupScript(d)
+	if err != nil {
+		return ssh.ActionError(err.Error())
+	}
+	if len(code) > 0 {
 		return ssh.ActionList{
 			ssh.DoMessage(descr),
 			ssh.DoExecScript([]byte(code)),
@@ -65,3 +44,31 @@ func doKubeadmSetup(d *schema.ResourceData) ssh.Action {
 		ssh.DoMessageWarn("no auto-installation: assuming kubeadm is installed in the target node."),
 	}
 }
+
+// getKubeadmSetupScript returns the script for installing kubeadm (and a description),
+// or an empty script when no installation has been requested
+func getKubeadmSetupScript(d *schema.ResourceData) (string, string, error) {
+	if _, ok := d.GetOk("install"); !ok {
+		return "", "", nil
+	}
+
+	auto := d.Get("install.0.auto").(bool)
+	inline := d.Get("install.0.inline").(string)
+	script := d.Get("install.0.script").(string)
+
+	if auto {
+		ssh.Debug("will upload the builtin auto-installation script")
+		return assets.KubeadmSetupScriptCode, "Uploading and running built-in kubeadm installation script...", nil
+	} else if len(inline) > 0 {
+		ssh.Debug("will upload auto-installation script from inlined script: %d bytes", len(inline))
+		return "#!/bin/sh\n" + inline, "Uploading and running inlined installation script...", nil
+	} else if len(script) > 0 {
+		ssh.Debug("will upload auto-installation from custom script from %q", script)
+		contents, err := ioutil.ReadFile(script)
+		if err != nil {
+			return "", "", fmt.Errorf("when reading kubeadm setup script %q: %s", script, err.Error())
+		}
+		return string(contents), fmt.Sprintf("Uploading and running custom kubeadm script from %s...", script), nil
+	}
+	return "", "", nil
+}
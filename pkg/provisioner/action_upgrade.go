@@ -0,0 +1,183 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/terraform/helper/schema"
+	"k8s.io/apimachinery/pkg/util/version"
+
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+)
+
+const (
+	// command for getting the kubelet version in a node
+	kubectlGetNodeVersionCmd = `get node %s -o=jsonpath='{.status.nodeInfo.kubeletVersion}'`
+
+	// command for removing the kube-proxy addon (re)installed by `kubeadm upgrade apply`
+	kubectlDeleteKubeProxyCmd = `-n kube-system delete daemonset,configmap kube-proxy --ignore-not-found`
+)
+
+// doKubeadmUpgrade upgrades the node to the Kubernetes version in the `config`
+// * in the seeder, it runs a `kubeadm upgrade plan/apply`
+// * in any other node (masters and workers) it runs a `kubeadm upgrade node`
+// Nodes are drained before the upgrade and uncordoned after restarting the kubelet.
+// Nodes that are already running the target version are not touched.
+func doKubeadmUpgrade(d *schema.ResourceData) ssh.Action {
+	if getRoleFromResourceData(d) == "etcd" {
+		return ssh.DoMessageInfo("etcd members are not upgraded: nothing to do")
+	}
+
+	kubeVersion := getKubeVersionFromResourceData(d)
+	if len(kubeVersion) == 0 {
+		return ssh.ActionError("no Kubernetes version found in 'config'")
+	}
+
+	isSeeder := len(getJoinFromResourceData(d)) == 0
+
+	upgrade := ssh.ActionList{}
+	if isSeeder {
+		upgrade = append(upgrade,
+			ssh.DoMessageInfo("Upgrading the control plane to %s with 'kubeadm upgrade apply'...", kubeVersion),
+			doExecKubeadmWithConfig(d, "upgrade plan", "", kubeVersion),
+			doExecKubeadmWithConfig(d, "upgrade apply", "", "--yes", kubeVersion),
+		)
+		if getSkipKubeProxyFromResourceData(d) {
+			// `kubeadm upgrade apply` always installs the kube-proxy addon,
+			// so we must remove it when it has been replaced by the CNI plugin
+			upgrade = append(upgrade,
+				ssh.DoMessageInfo("Removing the kube-proxy addon installed in the upgrade..."),
+				doRemoteKubectl(d, kubectlDeleteKubeProxyCmd),
+			)
+		}
+	} else {
+		upgrade = append(upgrade,
+			ssh.DoMessageInfo("Upgrading node to %s with 'kubeadm upgrade node'...", kubeVersion),
+			doExecKubeadmWithConfig(d, "upgrade node", ""),
+		)
+	}
+
+	localKubeNode := ssh.KubeNode{}
+
+	actions := ssh.ActionList{
+		ssh.DoMessageInfo("Preparing to upgrade node to %s...", kubeVersion),
+		doKubeadmSetup(d),
+		doCheckCommonBinaries(d),
+		doCheckKubeadmVersion(d, kubeVersion),
+		DoGetNodename(d, &localKubeNode),
+		ssh.ActionFunc(func(ctx context.Context) ssh.Action {
+			if localKubeNode.IsEmpty() {
+				return ssh.ActionError("could not find Kubernetes nodename for this node")
+			}
+			nodename := localKubeNode.Nodename
+
+			return ssh.DoIfElse(
+				checkNodeHasVersion(d, nodename, kubeVersion),
+				ssh.DoMessageInfo("Kubernetes node %q is already running %s: nothing to upgrade", nodename, kubeVersion),
+				ssh.ActionList{
+					doKubectlDrainNode(d, nodename),
+					// make sure the node is schedulable again, even when something goes wrong
+					ssh.DoWithCleanup(
+						ssh.ActionList{
+							upgrade,
+							ssh.DoMessageInfo("Restarting the kubelet..."),
+							ssh.DoRestartService("kubelet.service"),
+						},
+						doKubectlUncordonNode(d, nodename)),
+					ssh.DoMessageInfo("Kubernetes node %q has been upgraded to %s", nodename, kubeVersion),
+				})
+		}),
+	}
+
+	// write the local kubeconfig again: the kubeconfig generated by the provider
+	// (its certificate is not affected by the upgrade) or, when the provider could
+	// not generate one, the "admin.conf" (with the certificates renewed in the upgrade)
+	if isSeeder {
+		actions = append(actions, doWriteLocalKubeconfig(d))
+	}
+
+	return actions
+}
+
+// doCheckKubeadmVersion checks the kubeadm binary in the remote machine
+// can upgrade the cluster to the given version.
+func doCheckKubeadmVersion(d *schema.ResourceData, kubeVersion string) ssh.Action {
+	targetVersion, err := version.ParseGeneric(kubeVersion)
+	if err != nil {
+		return ssh.ActionError(fmt.Sprintf("could not parse Kubernetes version %q: %s", kubeVersion, err))
+	}
+
+	kubeadm := getKubeadmFromResourceData(d)
+
+	return ssh.ActionFunc(func(ctx context.Context) ssh.Action {
+		var buf bytes.Buffer
+		res := ssh.DoSendingExecOutputToWriter(ssh.DoExec(fmt.Sprintf("%s version -o short", kubeadm)), &buf).Apply(ctx)
+		if ssh.IsError(res) {
+			return res
+		}
+
+		kubeadmVersionStr := strings.TrimSpace(buf.String())
+		ssh.Debug("kubeadm version: %q", kubeadmVersionStr)
+		kubeadmVersion, err := version.ParseGeneric(kubeadmVersionStr)
+		if err != nil {
+			return ssh.ActionError(fmt.Sprintf("could not parse kubeadm version %q: %s", kubeadmVersionStr, err))
+		}
+
+		if kubeadmVersion.LessThan(targetVersion) {
+			return ssh.ActionList{
+				ssh.DoMessageWarn("kubeadm %s cannot upgrade to %s.", kubeadmVersionStr, kubeVersion),
+				ssh.DoMessageWarn("The kubeadm packages must be upgraded first (for example, with an 'install' script)."),
+				ssh.DoAbort("kubeadm version is too old"),
+			}
+		}
+		return nil
+	})
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////
+// checks
+////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// checkNodeHasVersion checks if the kubelet in a node is running some Kubernetes version
+func checkNodeHasVersion(d *schema.ResourceData, nodename string, kubeVersion string) ssh.CheckerFunc {
+	return ssh.CheckerFunc(func(ctx context.Context) (bool, error) {
+		targetVersion, err := version.ParseGeneric(kubeVersion)
+		if err != nil {
+			return false, err
+		}
+
+		var buf bytes.Buffer
+		res := ssh.DoSendingExecOutputToWriter(
+			doRemoteKubectl(d, fmt.Sprintf(kubectlGetNodeVersionCmd, nodename)),
+			&buf).Apply(ctx)
+		if ssh.IsError(res) {
+			return false, res
+		}
+
+		nodeVersionStr := strings.TrimSpace(buf.String())
+		ssh.Debug("kubelet version in node %q: %q", nodename, nodeVersionStr)
+		nodeVersion, err := version.ParseGeneric(nodeVersionStr)
+		if err != nil {
+			// we could not parse the version: assume we must upgrade
+			return false, nil
+		}
+
+		return nodeVersion.AtLeast(targetVersion), nil
+	})
+}
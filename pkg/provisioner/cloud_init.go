@@ -0,0 +1,262 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"encoding/base64"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/hashicorp/terraform/helper/schema"
+	kubeadmconstants "k8s.io/kubernetes/cmd/kubeadm/app/constants"
+
+	"github.com/inercia/terraform-provider-kubeadm/internal/assets"
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
+)
+
+const (
+	// where the kubeadm installation script is written in the machine
+	cloudInitSetupScriptPath = "/usr/local/bin/kubeadm-setup.sh"
+
+	// the header of a cloud-config document
+	cloudInitHeader = "#cloud-config\n"
+)
+
+// cloudInitFile is a file in the `write_files` section of a cloud-config document
+type cloudInitFile struct {
+	Path        string `json:"path"`
+	Encoding    string `json:"encoding"`
+	Content     string `json:"content"`
+	Owner       string `json:"owner"`
+	Permissions string `json:"permissions"`
+}
+
+// cloudInitConfig is a (minimal) cloud-config document
+type cloudInitConfig struct {
+	WriteFiles []cloudInitFile `json:"write_files"`
+	RunCmd     []string        `json:"runcmd"`
+}
+
+func (c *cloudInitConfig) addFile(contents []byte, path string, permissions string) {
+	c.WriteFiles = append(c.WriteFiles, cloudInitFile{
+		Path:        path,
+		Encoding:    "b64",
+		Content:     base64.StdEncoding.EncodeToString(contents),
+		Owner:       "root:root",
+		Permissions: permissions,
+	})
+}
+
+func (c *cloudInitConfig) addCmd(format string, args ...interface{}) {
+	c.RunCmd = append(c.RunCmd, fmt.Sprintf(format, args...))
+}
+
+// RenderCloudInit returns a cloud-config document that provisions a machine
+// the same way the provisioner does over SSH: it writes the kubelet service
+// files and the CNI loopback configuration, optionally runs the kubeadm
+// installation script, and writes the kubeadm configuration before running
+// a `kubeadm init` or `kubeadm join`.
+// The ResourceData must have the same attributes as the provisioner (ie,
+// `config`, `join`, `role`, `install`...).
+// When the masters are schedulable, the control plane taint is removed after
+// the `kubeadm init/join`.
+// Note well: the post-init tasks (loading the CNI manifests, Helm, the dashboard...)
+// are not performed.
+func RenderCloudInit(d *schema.ResourceData) ([]byte, error) {
+	join := getJoinFromResourceData(d)
+	role := getRoleFromResourceData(d)
+
+	// note: the "etcd" role is rejected by the validation of the data source
+	if role == "worker" && len(join) == 0 {
+		return nil, fmt.Errorf("role is %q while no \"join\" argument has been provided", role)
+	}
+
+	c := cloudInitConfig{}
+
+	code, _, err := getKubeadmSetupScript(d)
+	if err != nil {
+		return nil, err
+	}
+	if len(code) > 0 {
+		c.addFile([]byte(code), cloudInitSetupScriptPath, "0700")
+		c.addCmd("sh %s", cloudInitSetupScriptPath)
+	}
+
+	// the same files uploaded by the provisioner before initting/joining
+	c.addFile([]byte(assets.CNIDefConfCode), common.DefCniLookbackConfPath, "0644")
+	if resolvConf := getUpstreamResolvConf(d); len(resolvConf) > 0 {
+		c.addFile(resolvConf, common.DefResolvUpstreamConf, "0644")
+	}
+	c.addFile(getKubeletSysconfig(d), getSysconfigPathFromResourceData(d), "0644")
+	c.addFile([]byte(assets.KubeletServiceCode), getServicePathFromResourceData(d), "0644")
+	c.addFile([]byte(assets.KubeadmDropinCode), getDropinPathFromResourceData(d), "0644")
+
+	// we must reload the containers runtime engine after changing the CNI configuration
+	c.addCmd("systemctl try-restart crio.service docker.service")
+	c.addCmd("systemctl --no-pager daemon-reload")
+	c.addCmd("systemctl enable kubelet.service")
+
+	isControlPlane := len(join) == 0 || role == "master"
+	if isControlPlane {
+		if err := addCloudInitControlPlaneFiles(d, &c); err != nil {
+			return nil, err
+		}
+	}
+
+	kubeadm := getKubeadmFromResourceData(d)
+	if len(join) == 0 {
+		initConfig, _, err := common.InitConfigFromResourceData(d)
+		if err != nil {
+			return nil, fmt.Errorf("could not get a valid 'config' for init'ing: %s", err)
+		}
+		if err := setNodeRegistration(d, &initConfig.NodeRegistration); err != nil {
+			return nil, err
+		}
+		initConfigBytes, err := common.InitConfigToYAML(initConfig)
+		if err != nil {
+			return nil, err
+		}
+		c.addFile(initConfigBytes, common.DefKubeadmInitConfPath, "0600")
+
+		args := []string{getKubeadmIgnoredChecksArg(d, "init"), fmt.Sprintf("--config=%s", common.DefKubeadmInitConfPath), "--skip-token-print"}
+		if getSkipKubeProxyFromResourceData(d) {
+			args = append(args, "--skip-phases=addon/kube-proxy")
+		}
+		args = append(args, getKubeadmInitCertsArgs(d)...)
+		c.addCmd("%s init %s", kubeadm, strings.Join(args, " "))
+	} else {
+		joinConfig, _, err := common.JoinConfigFromResourceData(d)
+		if err != nil {
+			return nil, fmt.Errorf("could not get a valid 'config' for join'ing: %s", err)
+		}
+		if role == "master" {
+			initConfig, _, err := common.InitConfigFromResourceData(d)
+			if err != nil {
+				return nil, fmt.Errorf("could not get a valid 'config' for join'ing: %s", err)
+			}
+			if len(initConfig.ClusterConfiguration.ControlPlaneEndpoint) == 0 {
+				return nil, fmt.Errorf("cannot create additional masters when the 'kubeadm.<name>.api.external' and 'kubeadm.<name>.api.vip' are empty")
+			}
+			joinConfig.ControlPlane, err = getJoinControlPlane(d)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if err := setNodeRegistration(d, &joinConfig.NodeRegistration); err != nil {
+			return nil, err
+		}
+		joinConfigBytes, err := common.JoinConfigToYAML(joinConfig)
+		if err != nil {
+			return nil, err
+		}
+		c.addFile(joinConfigBytes, common.DefKubeadmJoinConfPath, "0600")
+
+		args := []string{getKubeadmIgnoredChecksArg(d, "join"), fmt.Sprintf("--config=%s", common.DefKubeadmJoinConfPath)}
+		if role == "master" {
+			args = append(args, getKubeadmJoinCertsArgs(d)...)
+		}
+		c.addCmd("%s join %s", kubeadm, strings.Join(args, " "))
+	}
+
+	removeTaint, err := mustRemoveControlPlaneTaint(d)
+	if err != nil {
+		return nil, err
+	}
+	if removeTaint {
+		nodename := getNodenameFromResourceData(d)
+		if len(nodename) == 0 {
+			nodename = "$(hostname)"
+		}
+		c.addCmd("%s --kubeconfig=%s taint node %s %s- || true",
+			getKubectlFromResourceData(d), ssh.DefAdminKubeconfig, nodename,
+			common.TaintToString(kubeadmconstants.ControlPlaneTaint))
+	}
+
+	contents, err := yaml.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(cloudInitHeader), contents...), nil
+}
+
+// addCloudInitControlPlaneFiles adds the files the provisioner uploads to the
+// control plane machines: certificates, encryption and audit configurations, kube-vip...
+// Masters joining the cluster do not get any certificates when they are distributed
+// with kubeadm: they are downloaded from the "kubeadm-certs" Secret in the `kubeadm join`.
+func addCloudInitControlPlaneFiles(d *schema.ResourceData, c *cloudInitConfig) error {
+	files := []struct {
+		name string
+		path string
+	}{
+		{"encryption_config", common.DefEncryptionConfigPath},
+		{"audit_policy", common.DefAuditPolicyPath},
+		{"audit_webhook_config", common.DefAuditWebhookConfigPath},
+	}
+
+	if len(getJoinFromResourceData(d)) == 0 || !isKubeadmCertDistribution(d) {
+		if err := addCloudInitCerts(d, c); err != nil {
+			return err
+		}
+		files = append(files, externalEtcdCerts...)
+	}
+
+	for _, f := range files {
+		raw, ok := d.GetOk("config." + f.name)
+		if !ok || len(raw.(string)) == 0 {
+			continue
+		}
+		contents, err := common.FromTerraformSafeString(raw.(string))
+		if err != nil {
+			return fmt.Errorf("could not decode %s: %s", f.name, err)
+		}
+		c.addFile(contents, f.path, "0600")
+	}
+
+	kubeVipManifest, err := getKubeVipManifest(d)
+	if err != nil {
+		return err
+	}
+	if len(kubeVipManifest) > 0 {
+		c.addFile(kubeVipManifest, common.DefKubeVipManifestPath, "0600")
+	}
+	return nil
+}
+
+// addCloudInitCerts adds the certificates generated by the provider
+func addCloudInitCerts(d *schema.ResourceData, c *cloudInitConfig) error {
+	certsConfig := &common.CertsConfig{}
+	if err := certsConfig.FromResourceDataConfig(d); err != nil {
+		return fmt.Errorf("no certificates data in config")
+	}
+	certsDir := common.DefPKIDir
+	if certsDirRaw, ok := d.GetOk("config.certs_dir"); ok && len(certsDirRaw.(string)) > 0 {
+		certsDir = certsDirRaw.(string)
+	}
+	// note: keep the output stable, so it does not change on every refresh
+	certs := certsConfig.DistributionMap()
+	baseNames := []string{}
+	for baseName := range certs {
+		baseNames = append(baseNames, baseName)
+	}
+	sort.Strings(baseNames)
+	for _, baseName := range baseNames {
+		c.addFile([]byte(*certs[baseName]), path.Join(certsDir, baseName), "0600")
+	}
+	return nil
+}
@@ -17,6 +17,7 @@ package provisioner
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/url"
@@ -36,8 +37,8 @@ const (
 	localEtcdEndpointIP   = "127.0.0.1"
 	localEtcdEndpointPort = 2379
 
-	// pattern for getting the etcd container
-	etcContainerPattern = "k8s_etcd_etcd"
+	// name of the etcd container
+	etcdContainerName = "etcd"
 
 	// common arguments for etcdctl
 	// note: these arguments are valid IFF using "ETCDCTL_API=3" is defined in the environment
@@ -51,6 +52,15 @@ const (
 
 	// command for removing a member
 	subcmdMemberRemove = "member remove"
+
+	// command for saving a snapshot
+	subcmdSnapshotSave = "snapshot save"
+
+	// command for restoring a snapshot
+	subcmdSnapshotRestore = "snapshot restore"
+
+	// command for transferring the leadership to another member
+	subcmdMoveLeader = "move-leader"
 )
 
 var (
@@ -60,15 +70,21 @@ var (
 	ErrParsingEtcdOutput = errors.New("error parsing etcd output")
 )
 
-// runEtcdctlSubcommand runs a etcdctl command
-func DoRunEtcdctlSubcommand(subcommand string, args ...string) ssh.Action {
-	argEndpoints := fmt.Sprintf("--endpoints=https://%s:%d", localEtcdEndpointIP, localEtcdEndpointPort)
+// DoRunEtcdctlSubcommand runs a etcdctl command against the local etcd endpoint
+func DoRunEtcdctlSubcommand(runtime ssh.ContainerRuntime, subcommand string, args ...string) ssh.Action {
+	localEndpoint := fmt.Sprintf("https://%s:%d", localEtcdEndpointIP, localEtcdEndpointPort)
+	return DoRunEtcdctlSubcommandWithEndpoints(runtime, []string{localEndpoint}, subcommand, args...)
+}
+
+// DoRunEtcdctlSubcommandWithEndpoints runs a etcdctl command against some etcd endpoints
+func DoRunEtcdctlSubcommandWithEndpoints(runtime ssh.ContainerRuntime, endpoints []string, subcommand string, args ...string) ssh.Action {
+	argEndpoints := fmt.Sprintf("--endpoints=%s", strings.Join(endpoints, ","))
 
 	// build the full `etcdctl` command to run in the container
 	fullEtcdctlCommand := fmt.Sprintf("%s %s %s %s %s",
 		etcdctlCommand, argsCommon, argEndpoints, subcommand, strings.Join(args, " "))
 
-	return ssh.DoDockerExec(etcContainerPattern, fullEtcdctlCommand)
+	return ssh.DoContainerExec(runtime, etcdContainerName, fullEtcdctlCommand)
 }
 
 //////////////////////////////////////////////////////////////////////////
@@ -117,8 +133,69 @@ func (ep *EtcdEndpoint) FromString(s string) error {
 	return nil
 }
 
+// etcdEndpointStatusJSON is the status of an endpoint in the output of `etcdctl endpoint status -w json`
+type etcdEndpointStatusJSON struct {
+	Endpoint string `json:"Endpoint"`
+	Status   struct {
+		Header struct {
+			MemberID uint64 `json:"member_id"`
+		} `json:"header"`
+		Leader uint64 `json:"leader"`
+	} `json:"Status"`
+}
+
 type EtcdEndpointsSet map[string]EtcdEndpoint
 
+// FromJSON gets a set of endpoints from the output of `etcdctl endpoint status -w json`,
+// ignoring anything else in the output (ie, the errors printed for unreachable endpoints)
+func (endpoints *EtcdEndpointsSet) FromJSON(s string) error {
+	lines := getJSONLines(s, "[")
+	if len(lines) == 0 {
+		return ErrParsingEtcdOutput
+	}
+
+	for _, line := range lines {
+		statuses := []etcdEndpointStatusJSON{}
+		if err := json.Unmarshal([]byte(line), &statuses); err != nil {
+			ssh.Debug("cannot parse as endpoints status: %q", line)
+			return ErrParsingEtcdOutput
+		}
+
+		for _, status := range statuses {
+			u, err := url.Parse(status.Endpoint)
+			if err != nil {
+				return err
+			}
+			ep := EtcdEndpoint{
+				ID:       fmt.Sprintf("%x", status.Status.Header.MemberID),
+				Endpoint: *u,
+				IsLeader: status.Status.Header.MemberID == status.Status.Leader,
+			}
+			ssh.Debug("adding etcd endpoint: %+v", ep)
+			(*endpoints)[ep.ID] = ep
+		}
+	}
+	return nil
+}
+
+// FromPartialString gets a set of endpoints from a string, ignoring anything
+// that cannot be parsed (ie, the errors printed for unreachable endpoints)
+func (endpoints *EtcdEndpointsSet) FromPartialString(s string) error {
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		ep := EtcdEndpoint{}
+		if err := ep.FromString(line); err != nil {
+			ssh.Debug("ignoring line in etcd output: %q", line)
+			continue
+		}
+		(*endpoints)[ep.ID] = ep
+	}
+	return nil
+}
+
 // FromString gets a set of endpoints from a string
 func (endpoints *EtcdEndpointsSet) FromString(s string) (err error) {
 	for _, line := range strings.Split(s, "\n") {
@@ -151,51 +228,347 @@ func (endpoints EtcdEndpointsSet) GetLocalEndpoint() EtcdEndpoint {
 	return localEndpoint
 }
 
+//////////////////////////////////////////////////////////////////////////
+
+type EtcdMember struct {
+	ID        string
+	Name      string
+	PeerURL   string
+	ClientURL string
+}
+
+func (m EtcdMember) String() string {
+	return fmt.Sprintf("%s %s (peer:%s)", m.ID, m.Name, m.PeerURL)
+}
+
+func (m *EtcdMember) FromString(s string) error {
+	// parse something like
+	//
+	// 8e9e05c52164694d, started, master-0, https://10.0.0.10:2380, https://10.0.0.10:2379
+	//
+	// where:
+	//+------------------+---------+----------+------------------------+------------------------+
+	//|        ID        | STATUS  |   NAME   |       PEER ADDRS       |      CLIENT ADDRS      |
+	//+------------------+---------+----------+------------------------+------------------------+
+	//| 8e9e05c52164694d | started | master-0 | https://10.0.0.10:2380 | https://10.0.0.10:2379 |
+	//+------------------+---------+----------+------------------------+------------------------+
+	// (newer versions of etcdctl add an extra "is learner" column)
+	res := strings.Split(s, ",")
+	if len(res) < 5 {
+		ssh.Debug("cannot parse as member info: %q", s)
+		return ErrParsingEtcdOutput
+	}
+
+	m.ID = strings.TrimSpace(res[0])
+	m.Name = strings.TrimSpace(res[2])
+	m.PeerURL = strings.TrimSpace(res[3])
+	m.ClientURL = strings.TrimSpace(res[4])
+	if len(m.ID) == 0 || len(m.PeerURL) == 0 {
+		ssh.Debug("cannot parse as member info: %q", s)
+		return ErrParsingEtcdOutput
+	}
+
+	return nil
+}
+
+// etcdMembersListJSON is the output of `etcdctl member list -w json`
+type etcdMembersListJSON struct {
+	Members []struct {
+		ID         uint64   `json:"ID"`
+		Name       string   `json:"name"`
+		PeerURLs   []string `json:"peerURLs"`
+		ClientURLs []string `json:"clientURLs"`
+	} `json:"members"`
+}
+
+type EtcdMembersList []EtcdMember
+
+// FromJSON gets a list of members from the output of `etcdctl member list -w json`
+func (members *EtcdMembersList) FromJSON(s string) error {
+	lines := getJSONLines(s, "{")
+	if len(lines) != 1 {
+		return ErrParsingEtcdOutput
+	}
+
+	list := etcdMembersListJSON{}
+	if err := json.Unmarshal([]byte(lines[0]), &list); err != nil {
+		ssh.Debug("cannot parse as members list: %q", lines[0])
+		return ErrParsingEtcdOutput
+	}
+
+	for _, member := range list.Members {
+		m := EtcdMember{
+			ID:   fmt.Sprintf("%x", member.ID),
+			Name: member.Name,
+		}
+		if len(member.PeerURLs) > 0 {
+			m.PeerURL = member.PeerURLs[0]
+		}
+		if len(member.ClientURLs) > 0 {
+			m.ClientURL = member.ClientURLs[0]
+		}
+		ssh.Debug("adding etcd member: %+v", m)
+		*members = append(*members, m)
+	}
+	return nil
+}
+
+// FromString gets a list of members from a string
+func (members *EtcdMembersList) FromString(s string) error {
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		m := EtcdMember{}
+		if err := m.FromString(line); err != nil {
+			return err
+		}
+		ssh.Debug("adding etcd member: %+v", m)
+		*members = append(*members, m)
+	}
+	return nil
+}
+
+// getJSONLines returns the lines in the output of `etcdctl` that look like JSON documents
+// (`etcdctl` prints the JSON documents in one line)
+func getJSONLines(s string, prefix string) []string {
+	res := []string{}
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, prefix) {
+			res = append(res, line)
+		}
+	}
+	return res
+}
+
+// etcdQuorum returns the number of members needed for a quorum in a cluster with `n` members
+func etcdQuorum(n int) int {
+	return n/2 + 1
+}
+
+// checkEtcdMemberRemoval checks if a member can be removed from an etcd cluster with
+// `numMembers` members, where `numHealthy` members (including the removed one) are healthy,
+// without losing the quorum
+func checkEtcdMemberRemoval(numMembers int, numHealthy int) error {
+	if numHealthy < etcdQuorum(numMembers) {
+		return fmt.Errorf("the etcd cluster has already lost quorum (%d healthy members out of %d)",
+			numHealthy, numMembers)
+	}
+
+	remaining := numMembers - 1
+	if remaining == 0 {
+		// the last member is never removed
+		return nil
+	}
+	if numHealthy-1 < etcdQuorum(remaining) {
+		return fmt.Errorf("removing this member would leave %d healthy members out of %d, but %d are needed for quorum",
+			numHealthy-1, remaining, etcdQuorum(remaining))
+	}
+	return nil
+}
+
+// countHealthyEtcdMembers returns the number of members that have replied with their status
+func countHealthyEtcdMembers(members EtcdMembersList, healthy EtcdEndpointsSet) int {
+	count := 0
+	for _, m := range members {
+		if _, ok := healthy[m.ID]; ok {
+			count++
+		}
+	}
+	return count
+}
+
+// getNewEtcdLeader returns the ID of a healthy member (different to `current`) that could be the new leader
+func getNewEtcdLeader(members EtcdMembersList, healthy EtcdEndpointsSet, current string) string {
+	for _, m := range members {
+		if m.ID == current {
+			continue
+		}
+		if _, ok := healthy[m.ID]; ok {
+			return m.ID
+		}
+	}
+	return ""
+}
+
 /////////////////////////////////////////////////////////////////////////////////////////
 
+// doRunEtcdctlWithOutput runs some `etcdctl` command with a JSON output, parsing it with `parseJSON`.
+// When the output cannot be parsed (ie, for older versions of `etcdctl`), the command is run
+// again with the "simple" output, parsing it with `parseSimple`.
+// Note well: the output is parsed even when the command fails, as `etcdctl` can return
+// an error when some endpoints are unreachable.
+func doRunEtcdctlWithOutput(run func(format string) ssh.Action, parseJSON func(string) error, parseSimple func(string) error) ssh.Action {
+	return ssh.ActionFunc(func(ctx context.Context) ssh.Action {
+		var jsonBuf bytes.Buffer
+		res := ssh.DoSendingExecOutputToWriter(run("json"), &jsonBuf).Apply(ctx)
+		if err := parseJSON(jsonBuf.String()); err == nil {
+			return res
+		}
+
+		ssh.Debug("could not parse the etcdctl JSON output: falling back to the simple output")
+		var buf bytes.Buffer
+		res = ssh.DoSendingExecOutputToWriter(run("simple"), &buf).Apply(ctx)
+		if err := parseSimple(buf.String()); err != nil {
+			if ssh.IsError(res) {
+				return res
+			}
+			return ssh.ActionError(err.Error())
+		}
+		return res
+	})
+}
+
 // DoGetEndpointsList gets the list of endpoints in the etcd cluster
-func DoGetEndpointsList(eps *EtcdEndpointsSet) ssh.Action {
-	var buf bytes.Buffer
+func DoGetEndpointsList(runtime ssh.ContainerRuntime, eps *EtcdEndpointsSet) ssh.Action {
+	return doRunEtcdctlWithOutput(
+		func(format string) ssh.Action {
+			return DoRunEtcdctlSubcommand(runtime, subcmdEndpointsList, "-w", format)
+		},
+		eps.FromJSON,
+		eps.FromString)
+}
+
+// DoGetMembersList gets the list of members in the etcd cluster
+func DoGetMembersList(runtime ssh.ContainerRuntime, members *EtcdMembersList) ssh.Action {
+	return doRunEtcdctlWithOutput(
+		func(format string) ssh.Action {
+			return DoRunEtcdctlSubcommand(runtime, subcmdMembersList, "-w", format)
+		},
+		members.FromJSON,
+		members.FromString)
+}
+
+// DoGetEtcdClusterStatus gets the status of the local endpoint, the list of members
+// in the etcd cluster and the status of the members that could be reached
+func DoGetEtcdClusterStatus(runtime ssh.ContainerRuntime, local *EtcdEndpoint, members *EtcdMembersList, healthy *EtcdEndpointsSet) ssh.Action {
+	eps := EtcdEndpointsSet{}
 	return ssh.ActionList{
-		ssh.DoSendingExecOutputToWriter(DoRunEtcdctlSubcommand(subcmdEndpointsList), &buf),
+		DoGetEndpointsList(runtime, &eps),
 		ssh.ActionFunc(func(ctx context.Context) ssh.Action {
-			err := eps.FromString(buf.String())
-			if err != nil {
-				return ssh.ActionError(err.Error())
+			*local = eps.GetLocalEndpoint()
+			if local.ID == "" {
+				return ssh.ActionError("could not find the local etcd endpoint details")
 			}
 			return nil
 		}),
+		DoGetMembersList(runtime, members),
+		ssh.ActionFunc(func(ctx context.Context) ssh.Action {
+			clientURLs := []string{}
+			for _, m := range *members {
+				if len(m.ClientURL) > 0 {
+					clientURLs = append(clientURLs, m.ClientURL)
+				}
+			}
+			// note: `etcdctl` fails when some endpoints cannot be reached, so we
+			//       ignore the error and use the status of the members that replied
+			return ssh.DoTry(
+				doRunEtcdctlWithOutput(
+					func(format string) ssh.Action {
+						return DoRunEtcdctlSubcommandWithEndpoints(runtime, clientURLs, subcmdEndpointsList, "-w", format)
+					},
+					healthy.FromJSON,
+					healthy.FromPartialString))
+		}),
+		ssh.ActionFunc(func(ctx context.Context) ssh.Action {
+			// the local endpoint is healthy, even if we could not reach it with its public address
+			(*healthy)[local.ID] = *local
+			ssh.Debug("%d etcd members healthy out of %d", countHealthyEtcdMembers(*members, *healthy), len(*members))
+			return nil
+		}),
 	}
 }
 
+// doCheckEtcdMemberRemoval checks that the etcd cluster would not lose quorum
+// when removing the local etcd member, aborting otherwise (unless `force` is set)
+func doCheckEtcdMemberRemoval(d *schema.ResourceData) ssh.Action {
+	if isExternalEtcdFromResourceData(d) {
+		return nil
+	}
+	if getForceFromResourceData(d) {
+		return ssh.DoMessageWarn("'force' is set: the node will be removed without checking the etcd quorum")
+	}
+
+	runtime := getContainerRuntimeFromResourceData(d)
+	local := EtcdEndpoint{}
+	members := EtcdMembersList{}
+	healthy := EtcdEndpointsSet{}
+	return ssh.DoIf(
+		ssh.CheckContainerRunning(runtime, etcdContainerName),
+		ssh.ActionList{
+			ssh.DoMessageInfo("Checking the etcd cluster would not lose quorum..."),
+			DoGetEtcdClusterStatus(runtime, &local, &members, &healthy),
+			ssh.ActionFunc(func(ctx context.Context) ssh.Action {
+				if err := checkEtcdMemberRemoval(len(members), countHealthyEtcdMembers(members, healthy)); err != nil {
+					return ssh.ActionList{
+						ssh.DoMessageWarn("%s.", err),
+						ssh.DoMessageWarn("Use 'force = true' for removing this node anyway."),
+						ssh.DoAbort("the etcd cluster would lose quorum"),
+					}
+				}
+				return nil
+			}),
+		})
+}
+
 // doRemoveIfMember removes this node from the etcd cluster iff it was a member
+// (nothing is done when using an external etcd cluster).
+// The quorum is checked again right before removing the member, as the etcd cluster
+// could have changed since doCheckEtcdMemberRemoval (ie, when other masters are being
+// removed at the same time), aborting when it would be lost (unless `force` is set).
+// When this member is the leader, the leadership is moved to another member first.
 func doRemoveIfMember(d *schema.ResourceData) ssh.Action {
-	eps := EtcdEndpointsSet{}
-	return ssh.ActionList{
+	if isExternalEtcdFromResourceData(d) {
+		return ssh.DoMessageInfo("Using an external etcd cluster: no need to remove the node from the etcd cluster")
+	}
+
+	force := getForceFromResourceData(d)
+	runtime := getContainerRuntimeFromResourceData(d)
+	local := EtcdEndpoint{}
+	members := EtcdMembersList{}
+	healthy := EtcdEndpointsSet{}
+	remove := ssh.ActionList{
 		ssh.DoMessageInfo("Checking if we must delete the node from the etcd cluster..."),
 		ssh.DoIfElse(
-			ssh.CheckContainerRunning(etcContainerPattern),
+			ssh.CheckContainerRunning(runtime, etcdContainerName),
 			ssh.ActionList{
-				DoGetEndpointsList(&eps),
+				DoGetEtcdClusterStatus(runtime, &local, &members, &healthy),
 				ssh.ActionFunc(func(ctx context.Context) ssh.Action {
-					if len(eps) == 0 {
-						return ssh.DoMessageWarn("could not get list of etcd endpoints")
+					if len(members) <= 1 {
+						return ssh.DoMessageInfo("This is the last member of the etcd cluster: no need to remove it")
 					}
-					return nil
-				}),
-				ssh.ActionFunc(func(ctx context.Context) ssh.Action {
-					localEndpoint := eps.GetLocalEndpoint()
-					if localEndpoint.ID == "" {
-						return ssh.DoMessageWarn("could not find the local etcd endpoint details")
+
+					if !force {
+						if err := checkEtcdMemberRemoval(len(members), countHealthyEtcdMembers(members, healthy)); err != nil {
+							return ssh.ActionList{
+								ssh.DoMessageWarn("%s.", err),
+								ssh.DoMessageWarn("Remove the masters one at a time, or use 'force = true' for removing this node anyway."),
+								ssh.DoAbort("the etcd cluster would lose quorum"),
+							}
+						}
+					}
+
+					actions := ssh.ActionList{}
+					if local.IsLeader {
+						newLeader := getNewEtcdLeader(members, healthy, local.ID)
+						if newLeader == "" {
+							actions = append(actions, ssh.DoMessageWarn("%q is the etcd leader, but no other healthy member has been found for moving the leadership", local.ID))
+						} else {
+							actions = append(actions,
+								ssh.DoMessageInfo("Moving the etcd leadership from %q to %q", local.ID, newLeader),
+								DoRunEtcdctlSubcommand(runtime, subcmdMoveLeader, newLeader))
+						}
 					}
 
 					// now we have the etcd ID for the etcd instance running in this machine
 					// we can run the "member remove <ID>"
-					return ssh.ActionList{
-						ssh.DoMessageInfo("Removing %q from the etcd cluster", localEndpoint.ID),
-						DoRunEtcdctlSubcommand(subcmdMemberRemove, localEndpoint.ID),
-						ssh.DoMessageInfo("%q has been removed from the etcd cluster", localEndpoint.ID),
-					}
+					return append(actions,
+						ssh.DoMessageInfo("Removing %q from the etcd cluster", local.ID),
+						DoRunEtcdctlSubcommand(runtime, subcmdMemberRemove, local.ID),
+						ssh.DoMessageInfo("%q has been removed from the etcd cluster", local.ID),
+					)
 				}),
 			},
 			ssh.ActionList{
@@ -203,16 +576,27 @@ func doRemoveIfMember(d *schema.ResourceData) ssh.Action {
 			},
 		),
 	}
+
+	// when forced, the node is removed even if something fails here
+	if force {
+		return ssh.DoTry(remove)
+	}
+	return remove
 }
 
 // doPrintEtcdStatus prints the status of etcd, if running
 func doPrintEtcdStatus(d *schema.ResourceData) ssh.Action {
+	if isExternalEtcdFromResourceData(d) {
+		return ssh.DoMessageInfo("Using an external etcd cluster: etcd is not running in this node")
+	}
+
+	runtime := getContainerRuntimeFromResourceData(d)
 	eps := EtcdEndpointsSet{}
 	return ssh.DoIfElse(
-		ssh.CheckContainerRunning(etcContainerPattern),
+		ssh.CheckContainerRunning(runtime, etcdContainerName),
 		ssh.ActionList{
 			ssh.DoMessageInfo("Checking status of etcd (if running)..."),
-			DoGetEndpointsList(&eps),
+			DoGetEndpointsList(runtime, &eps),
 			ssh.ActionFunc(func(ctx context.Context) ssh.Action {
 				if len(eps) == 0 {
 					return ssh.DoMessageWarn("could not get list of etcd endpoints")
@@ -55,3 +55,171 @@ https://127.0.8.1:2379, f0085f42f7f00855, 3.3.10, 1.8 MB, false, 2, 24139\r
 	}
 
 }
+
+func TestParseMembersListOutput(t *testing.T) {
+	s := `
+8e9e05c52164694d, started, master-0, https://10.0.0.10:2380, https://10.0.0.10:2379
+91bc3c398fb3c146, started, master-1, https://10.0.0.11:2380, https://10.0.0.11:2379, false
+`
+
+	members := EtcdMembersList{}
+	if err := members.FromString(s); err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+
+	if len(members) != 2 {
+		t.Fatalf("unexpected number of members: %d", len(members))
+	}
+
+	if members[0].ID != "8e9e05c52164694d" || members[0].Name != "master-0" || members[0].PeerURL != "https://10.0.0.10:2380" {
+		t.Fatalf("unexpected member: %s", members[0])
+	}
+
+	if members[1].Name != "master-1" || members[1].PeerURL != "https://10.0.0.11:2380" {
+		t.Fatalf("unexpected member: %s", members[1])
+	}
+
+	if err := members.FromString("something unexpected"); err == nil {
+		t.Fatalf("Error expected when parsing an invalid output")
+	}
+}
+
+func TestCheckEtcdMemberRemoval(t *testing.T) {
+	cases := []struct {
+		members  int
+		healthy  int
+		expectOK bool
+	}{
+		{1, 1, true},
+		{2, 2, true},
+		{2, 1, false},
+		{3, 3, true},
+		{3, 2, false},
+		{3, 1, false},
+		{5, 5, true},
+		{5, 4, true},
+		{5, 3, false},
+	}
+
+	for _, c := range cases {
+		err := checkEtcdMemberRemoval(c.members, c.healthy)
+		if c.expectOK && err != nil {
+			t.Fatalf("unexpected error for %d healthy members out of %d: %v", c.healthy, c.members, err)
+		}
+		if !c.expectOK && err == nil {
+			t.Fatalf("error expected for %d healthy members out of %d", c.healthy, c.members)
+		}
+	}
+}
+
+func TestGetNewEtcdLeader(t *testing.T) {
+	s := `
+https://10.0.0.10:2379, 8e9e05c52164694d, 3.3.10, 1.8 MB, true, 2, 24139
+Failed to get the status of endpoint https://10.0.0.11:2379 (context deadline exceeded)
+https://10.0.0.12:2379, f0085f42f7f00855, 3.3.10, 1.8 MB, false, 2, 24139
+`
+	healthy := EtcdEndpointsSet{}
+	_ = healthy.FromPartialString(s)
+	if len(healthy) != 2 {
+		t.Fatalf("unexpected number of healthy endpoints: %d", len(healthy))
+	}
+
+	members := EtcdMembersList{
+		{ID: "8e9e05c52164694d", Name: "master-0"},
+		{ID: "91bc3c398fb3c146", Name: "master-1"},
+		{ID: "f0085f42f7f00855", Name: "master-2"},
+	}
+
+	if count := countHealthyEtcdMembers(members, healthy); count != 2 {
+		t.Fatalf("unexpected number of healthy members: %d", count)
+	}
+
+	// the unreachable member must not be chosen as the new leader
+	if leader := getNewEtcdLeader(members, healthy, "8e9e05c52164694d"); leader != "f0085f42f7f00855" {
+		t.Fatalf("unexpected new leader: %q", leader)
+	}
+
+	if leader := getNewEtcdLeader(members[:2], healthy, "8e9e05c52164694d"); leader != "" {
+		t.Fatalf("no leader expected, got %q", leader)
+	}
+}
+
+func TestParseEtcdJSONOutput(t *testing.T) {
+	// outputs captured from etcdctl 3.3.10 (some fields have been removed)
+	testCases := map[string]struct {
+		endpoints  string
+		members    string
+		leader     string
+		local      string
+		healthy    int
+		numMembers int
+	}{
+		"single member": {
+			endpoints:  `[{"Endpoint":"https://127.0.0.1:2379","Status":{"header":{"cluster_id":14841639068965178418,"member_id":16808268728825284693,"revision":1950,"raft_term":2},"version":"3.3.10","dbSize":1810432,"leader":16808268728825284693,"raftIndex":2243,"raftTerm":2}}]`,
+			members:    `{"header":{"cluster_id":14841639068965178418,"member_id":16808268728825284693,"raft_term":2},"members":[{"ID":16808268728825284693,"name":"master-0","peerURLs":["https://10.0.0.10:2380"],"clientURLs":["https://10.0.0.10:2379"]}]}`,
+			leader:     "e942f75ad6f00855",
+			local:      "e942f75ad6f00855",
+			healthy:    1,
+			numMembers: 1,
+		},
+		"unreachable member": {
+			endpoints: `Failed to get the status of endpoint https://10.0.0.11:2379 (context deadline exceeded)
+[{"Endpoint":"https://127.0.0.1:2379","Status":{"header":{"member_id":16808268728825284693},"leader":3456221291141567558}},{"Endpoint":"https://10.0.0.12:2379","Status":{"header":{"member_id":3456221291141567558},"leader":3456221291141567558}}]`,
+			members:    `{"header":{"member_id":16808268728825284693},"members":[{"ID":16808268728825284693,"name":"master-0","peerURLs":["https://10.0.0.10:2380"],"clientURLs":["https://10.0.0.10:2379"]},{"ID":10501334649042878790,"name":"master-1","peerURLs":["https://10.0.0.11:2380"],"clientURLs":["https://10.0.0.11:2379"]},{"ID":3456221291141567558,"name":"master-2","peerURLs":["https://10.0.0.12:2380"],"clientURLs":["https://10.0.0.12:2379"]}]}`,
+			leader:     "2ff6f6f3d3a29446",
+			local:      "e942f75ad6f00855",
+			healthy:    2,
+			numMembers: 3,
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			endpoints := EtcdEndpointsSet{}
+			if err := endpoints.FromJSON(testCase.endpoints); err != nil {
+				t.Fatalf("Error: %v", err)
+			}
+
+			local := endpoints.GetLocalEndpoint()
+			if local.ID != testCase.local {
+				t.Fatalf("unexpected local endpoint: %q", local.ID)
+			}
+
+			leader := ""
+			for _, ep := range endpoints {
+				if ep.IsLeader {
+					leader = ep.ID
+				}
+			}
+			if leader != testCase.leader {
+				t.Fatalf("unexpected leader: %q", leader)
+			}
+
+			members := EtcdMembersList{}
+			if err := members.FromJSON(testCase.members); err != nil {
+				t.Fatalf("Error: %v", err)
+			}
+			if len(members) != testCase.numMembers {
+				t.Fatalf("unexpected number of members: %d", len(members))
+			}
+			if members[0].ID != "e942f75ad6f00855" || members[0].Name != "master-0" ||
+				members[0].PeerURL != "https://10.0.0.10:2380" || members[0].ClientURL != "https://10.0.0.10:2379" {
+				t.Fatalf("unexpected member: %s", members[0])
+			}
+
+			if count := countHealthyEtcdMembers(members, endpoints); count != testCase.healthy {
+				t.Fatalf("unexpected number of healthy members: %d", count)
+			}
+		})
+	}
+
+	// the simple output must not be parsed as JSON
+	endpoints := EtcdEndpointsSet{}
+	if err := endpoints.FromJSON("https://127.0.0.1:2379, e942f75ad6f00855, 3.3.10, 1.8 MB, true, 2, 24139"); err == nil {
+		t.Fatalf("Error expected when parsing the simple output as JSON")
+	}
+	members := EtcdMembersList{}
+	if err := members.FromJSON("8e9e05c52164694d, started, master-0, https://10.0.0.10:2380, https://10.0.0.10:2379"); err == nil {
+		t.Fatalf("Error expected when parsing the simple output as JSON")
+	}
+}
@@ -17,10 +17,13 @@ package provisioner
 import (
 	"bytes"
 	"context"
+	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"strings"
 
 	"github.com/hashicorp/terraform/helper/schema"
+	corev1 "k8s.io/api/core/v1"
 
 	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
 	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
@@ -30,7 +33,11 @@ const (
 	// command for getting the machine-id
 	machineIDCmd = `cat /etc/machine-id`
 
+	// command for getting the list of nodes
+	kubectlGetNodesCmd = `get nodes -o json`
+
 	// command for getting a map of "machine-id <-> nodename"
+	// (used when the JSON output cannot be parsed)
 	kubectlGetNodenameCmd = `get nodes -o yaml -o=jsonpath='{range .items[*]}{.status.nodeInfo.machineID}{"\t"}{.metadata.name}{"\n"}{end}'`
 )
 
@@ -63,6 +70,17 @@ func doKubectlDrainNode(d *schema.ResourceData, nodename string) ssh.Action {
 	}
 }
 
+// doKubectlUncordonNode runs a kubectl for marking a node as schedulable again
+func doKubectlUncordonNode(d *schema.ResourceData, nodename string) ssh.Action {
+	args := []string{"uncordon", nodename}
+
+	ssh.Debug("running 'kubectl uncordon' command for %q", nodename)
+	return ssh.ActionList{
+		ssh.DoMessageInfo("Uncordoning kubernetes node %q", nodename),
+		doRemoteKubectl(d, args...),
+	}
+}
+
 // doKubectlDeleteNode deletes the node from the cluster (so it will be forgotten forever)
 func doKubectlDeleteNode(d *schema.ResourceData, nodename string) ssh.Action {
 	args := []string{"delete", "node", nodename}
@@ -100,34 +118,95 @@ func DoGetNodename(d *schema.ResourceData, node *ssh.KubeNode) ssh.Action {
 		machineID := strings.TrimSpace(buf.String())
 		ssh.Debug("... machineID: %q", machineID)
 
+		var nodesBuf bytes.Buffer
+		res = ssh.DoSendingExecOutputToWriter(ssh.DoRemoteKubectl(kubectl, kubeconfig, kubectlGetNodesCmd), &nodesBuf).Apply(ctx)
+		if !ssh.IsError(res) {
+			nodename, err := getNodenameFromNodesJSON(nodesBuf.String(), machineID)
+			if err == nil {
+				node.Nodename = nodename
+				ssh.Debug("... detected nodename %q", node.Nodename)
+				return nil
+			}
+			ssh.Debug("could not get the nodename from the JSON output: %s", err)
+		}
+
+		// fall back to getting a "machine-id <-> nodename" map
+		lines := []string{}
 		res = ssh.DoSendingExecOutputToFunc(
 			ssh.DoRemoteKubectl(kubectl, kubeconfig, kubectlGetNodenameCmd),
 			func(s string) {
-				if len(s) == 0 {
-					return
-				}
-				ssh.Debug("trying to find nodename in %q", s)
-				if strings.Contains(s, machineID) {
-					// parse:
-					// bf38f8ac633e4f64a4924b0ed7b25946        kubeadm-master-0
-					fields := strings.Fields(s)
-					if len(fields) < 2 {
-						ssh.Debug("could not get the nodename from fields: %+v", fields)
-						return
-					}
-					node.Nodename = strings.TrimSpace(fields[1])
-					ssh.Debug("... detected nodename %q", node.Nodename)
-				}
+				lines = append(lines, s)
 			}).Apply(ctx)
-
-		return res
+		if ssh.IsError(res) {
+			return res
+		}
+		node.Nodename = getNodenameFromMachineIDsMap(lines, machineID)
+		ssh.Debug("... detected nodename %q", node.Nodename)
+		return nil
 	})
 }
 
+// getNodenameFromNodesJSON gets the name of the node with some machine ID
+// from the output of `kubectl get nodes -o json`
+func getNodenameFromNodesJSON(s string, machineID string) (string, error) {
+	// skip anything before the JSON document
+	start := strings.Index(s, "{")
+	if start < 0 {
+		return "", fmt.Errorf("no JSON document found")
+	}
+
+	nodes := corev1.NodeList{}
+	if err := json.NewDecoder(strings.NewReader(s[start:])).Decode(&nodes); err != nil {
+		return "", err
+	}
+
+	for _, node := range nodes.Items {
+		if node.Status.NodeInfo.MachineID == machineID {
+			return node.Name, nil
+		}
+	}
+	return "", nil
+}
+
+// getNodenameFromMachineIDsMap gets the name of the node with some machine ID
+// from a list of "<machine-id> <nodename>" lines
+func getNodenameFromMachineIDsMap(lines []string, machineID string) string {
+	for _, line := range lines {
+		// parse:
+		// bf38f8ac633e4f64a4924b0ed7b25946        kubeadm-master-0
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		if fields[0] == machineID {
+			return strings.TrimSpace(fields[1])
+		}
+	}
+	return ""
+}
+
 //
 // kubeconfig
 //
 
+// doWriteLocalKubeconfig writes the admin kubeconfig generated by the provider to the
+// local file specified in the "config_path" attribute. When the provider could not
+// generate a kubeconfig (ie, no API server address was provided), it downloads
+// the "admin.conf" from the remote master.
+func doWriteLocalKubeconfig(d *schema.ResourceData) ssh.Action {
+	contents := getKubeconfigContentsFromResourceData(d)
+	if len(contents) == 0 {
+		ssh.Debug("no kubeconfig generated by the provider: downloading the remote 'admin.conf'")
+		return doDownloadKubeconfig(d)
+	}
+
+	kubeconfig := getKubeconfigFromResourceData(d)
+	return ssh.ActionList{
+		ssh.DoMessageInfo("Writing the admin kubeconfig generated from the cluster CA..."),
+		ssh.DoWriteLocalFile(kubeconfig, string(contents)),
+	}
+}
+
 // doDownloadKubeconfig downloads the "admin.conf" from the remote master
 // to the local file specified in the "config_path" attribute
 func doDownloadKubeconfig(d *schema.ResourceData) ssh.Action {
@@ -24,31 +24,84 @@ import (
 
 func TestDoGetNodename(t *testing.T) {
 	machineID := "  bf38f8ac633e4f64a4924b0ed7b25946\r"
-	output := `
+
+	// (trimmed) output of `kubectl get nodes -o json`
+	jsonOutput := `
+{
+    "apiVersion": "v1",
+    "items": [
+        {
+            "apiVersion": "v1",
+            "kind": "Node",
+            "metadata": {
+                "name": "kubeadm-master-0"
+            },
+            "status": {
+                "nodeInfo": {
+                    "machineID": "bf38f8ac633e4f64a4924b0ed7b25946",
+                    "kubeletVersion": "v1.14.1"
+                }
+            }
+        },
+        {
+            "apiVersion": "v1",
+            "kind": "Node",
+            "metadata": {
+                "name": "kubeadm-worker-0"
+            },
+            "status": {
+                "nodeInfo": {
+                    "machineID": "0b44fe52491e401181c4ef5607b70e96",
+                    "kubeletVersion": "v1.14.1"
+                }
+            }
+        }
+    ],
+    "kind": "List"
+}
+`
+
+	// output of the "machine-id <-> nodename" map
+	mapOutput := `
 bf38f8ac633e4f64a4924b0ed7b25946        kubeadm-master-0
 0b44fe52491e401181c4ef5607b70e96        kubeadm-worker-0
 `
 
-	// responses from the fake remote machine
-	responses := []string{
-		machineID,
-		"CONDITION_SUCCEEDED",
-		output,
+	testCases := map[string]struct {
+		responses []string
+		expected  string
+	}{
+		"json": {
+			responses: []string{machineID, "CONDITION_SUCCEEDED", jsonOutput},
+			expected:  "kubeadm-master-0",
+		},
+		"fallback to machine IDs map": {
+			responses: []string{machineID, "CONDITION_SUCCEEDED", "error: unknown output format", "CONDITION_SUCCEEDED", mapOutput},
+			expected:  "kubeadm-master-0",
+		},
+		"unknown machine": {
+			responses: []string{"  00000000000000000000000000000000\r", "CONDITION_SUCCEEDED", jsonOutput},
+			expected:  "",
+		},
 	}
 
-	d := schema.ResourceData{}
-	_ = d.Set("install.0.kubectl_path", "")
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			d := schema.ResourceData{}
+			_ = d.Set("install.0.kubectl_path", "")
 
-	node := ssh.KubeNode{}
-	ctx := ssh.NewTestingContextWithResponses(responses)
-	actions := ssh.ActionList{
-		DoGetNodename(&d, &node),
-	}
-	res := actions.Apply(ctx)
-	if ssh.IsError(res) {
-		t.Fatalf("Error: %s", res.Error())
-	}
-	if node.Nodename != "kubeadm-master-0" {
-		t.Fatalf("Error: wrong nodename %q", node.Nodename)
+			node := ssh.KubeNode{}
+			ctx := ssh.NewTestingContextWithResponses(testCase.responses)
+			actions := ssh.ActionList{
+				DoGetNodename(&d, &node),
+			}
+			res := actions.Apply(ctx)
+			if ssh.IsError(res) {
+				t.Fatalf("Error: %s", res.Error())
+			}
+			if node.Nodename != testCase.expected {
+				t.Fatalf("Error: wrong nodename %q (expected %q)", node.Nodename, testCase.expected)
+			}
+		})
 	}
 }
@@ -0,0 +1,257 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/hashicorp/terraform/helper/schema"
+	"github.com/hashicorp/terraform/terraform"
+	kubeadmconstants "k8s.io/kubernetes/cmd/kubeadm/app/constants"
+
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
+)
+
+// nodeOutput is the output for the messages when managing nodes from a resource
+// (resources have no access to the UI, so messages just go to the logs)
+var nodeOutput = ssh.OutputFunc(func(s string) {
+	log.Printf("[INFO] [KUBEADM] %s", s)
+})
+
+// CreateNode provisions a machine as a node of the cluster (like the provisioner does)
+// through SSH, using the `connInfo` connection details. It returns the name of the
+// Kubernetes node (or an empty string for etcd members).
+// Note well: the labels and taints are set in the node registration (see
+// setNodeRegistration), so they are not applied again once the node has joined.
+func CreateNode(d *schema.ResourceData, connInfo map[string]string) (string, error) {
+	localKubeNode := ssh.KubeNode{}
+
+	actions := ssh.ActionList{
+		ssh.DoMessageInfo("New node: provisioning"),
+		doProvisionNode(d),
+	}
+	if getRoleFromResourceData(d) != "etcd" {
+		actions = append(actions,
+			DoGetNodename(d, &localKubeNode),
+			ssh.ActionFunc(func(ctx context.Context) ssh.Action {
+				if localKubeNode.IsEmpty() {
+					return ssh.ActionError("could not find Kubernetes nodename for this node")
+				}
+				return nil
+			}))
+	}
+
+	if err := runNodeActions(d, connInfo, actions); err != nil {
+		return "", err
+	}
+	return localKubeNode.Nodename, nil
+}
+
+// UpdateNode updates the labels, taints and kubelet arguments of a node, as well as
+// the control plane taint when the `schedulable_masters` in the `config` changes
+func UpdateNode(d *schema.ResourceData, connInfo map[string]string, nodename string) error {
+	actions := ssh.ActionList{}
+	if d.HasChange("kubelet_args") {
+		actions = append(actions,
+			ssh.DoMessageInfo("Updating the kubelet arguments..."),
+			ssh.DoUploadBytesToFile(getKubeletSysconfig(d), getSysconfigPathFromResourceData(d)),
+			ssh.DoRestartService("kubelet.service"))
+	}
+	if getRoleFromResourceData(d) != "etcd" {
+		actions = append(actions,
+			doUpdateNodeLabels(d, nodename),
+			doUpdateNodeTaints(d, nodename),
+			doUpdateControlPlaneTaint(d, nodename))
+	}
+
+	return runNodeActions(d, connInfo, actions)
+}
+
+// UpgradeNode upgrades a node to the Kubernetes version in the `config`
+func UpgradeNode(d *schema.ResourceData, connInfo map[string]string) error {
+	return runNodeActions(d, connInfo, doKubeadmUpgrade(d))
+}
+
+// DeleteNode removes a node from the cluster (draining it and removing it from etcd)
+func DeleteNode(d *schema.ResourceData, connInfo map[string]string) error {
+	return runNodeActions(d, connInfo, doRemoveNode(d))
+}
+
+// runNodeActions connects to a remote machine and runs some actions
+func runNodeActions(d *schema.ResourceData, connInfo map[string]string, action ssh.Action) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	s := &terraform.InstanceState{
+		Ephemeral: terraform.EphemeralState{
+			ConnInfo: connInfo,
+		},
+	}
+
+	comm, err := getCommunicator(ctx, nodeOutput, s)
+	if err != nil {
+		return fmt.Errorf("could not connect to %q: %s", connInfo["host"], err)
+	}
+
+	useSudo := !d.Get("prevent_sudo").(bool) && connInfo["user"] != "root"
+	newCtx := ssh.WithValues(ctx, nodeOutput, nodeOutput, comm, useSudo)
+
+	res := ssh.DoWithCleanup(
+		action,
+		ssh.DoCleanupLeftovers()).Apply(newCtx)
+	if ssh.IsError(res) {
+		return res
+	}
+	return nil
+}
+
+// doUpdateNodeLabels adds/removes the labels that have changed in the `labels` argument
+func doUpdateNodeLabels(d *schema.ResourceData, nodename string) ssh.Action {
+	o, n := d.GetChange("labels")
+	args := getLabelsChanges(o.(map[string]interface{}), n.(map[string]interface{}))
+	if len(args) == 0 {
+		return nil
+	}
+
+	ssh.Debug("running 'kubectl label' command for %q: %v", nodename, args)
+	return ssh.ActionList{
+		ssh.DoMessageInfo("Updating labels in node %q...", nodename),
+		doRemoteKubectl(d, append([]string{"label", "--overwrite", "node", nodename}, args...)...),
+	}
+}
+
+// doUpdateNodeTaints adds/removes the taints that have changed in the `taints` argument
+func doUpdateNodeTaints(d *schema.ResourceData, nodename string) ssh.Action {
+	o, n := d.GetChange("taints")
+	removed, added, err := getTaintsChanges(o.([]interface{}), n.([]interface{}))
+	if err != nil {
+		return ssh.ActionError(err.Error())
+	}
+
+	actions := ssh.ActionList{}
+	if len(removed) > 0 {
+		ssh.Debug("running 'kubectl taint' command for removing %v from %q", removed, nodename)
+		actions = append(actions,
+			ssh.DoMessageInfo("Removing taints from node %q...", nodename),
+			doRemoteKubectl(d, append([]string{"taint", "node", nodename}, removed...)...))
+	}
+	if len(added) > 0 {
+		ssh.Debug("running 'kubectl taint' command for adding %v to %q", added, nodename)
+		actions = append(actions,
+			ssh.DoMessageInfo("Adding taints to node %q...", nodename),
+			doRemoteKubectl(d, append([]string{"taint", "--overwrite", "node", nodename}, added...)...))
+	}
+	return actions
+}
+
+// doUpdateControlPlaneTaint adds/removes the control plane taint in a master
+// when the `schedulable_masters` in the `config` has changed. The taint is kept
+// when it is explicitly present in the taints for the node.
+func doUpdateControlPlaneTaint(d *schema.ResourceData, nodename string) ssh.Action {
+	if !isControlPlaneFromResourceData(d) || !d.HasChange("config.schedulable_masters") {
+		return nil
+	}
+
+	taints, err := getNodeTaintsFromResourceData(d)
+	if err != nil {
+		return ssh.ActionError(err.Error())
+	}
+	for _, taint := range taints {
+		if taint.Key == kubeadmconstants.ControlPlaneTaint.Key && taint.Effect == kubeadmconstants.ControlPlaneTaint.Effect {
+			return nil
+		}
+	}
+
+	taint := common.TaintToString(kubeadmconstants.ControlPlaneTaint)
+	if getSchedulableMastersFromResourceData(d) {
+		ssh.Debug("removing the control plane taint %q from %q", taint, nodename)
+		return ssh.ActionList{
+			ssh.DoMessageInfo("Making master %q schedulable...", nodename),
+			ssh.DoTry(doRemoteKubectl(d, "taint", "node", nodename, taint+"-")),
+		}
+	}
+
+	ssh.Debug("adding the control plane taint %q to %q", taint, nodename)
+	return ssh.ActionList{
+		ssh.DoMessageInfo("Making master %q unschedulable...", nodename),
+		doRemoteKubectl(d, "taint", "--overwrite", "node", nodename, taint),
+	}
+}
+
+// getLabelsChanges returns the `kubectl label` arguments for going from
+// the old labels to the new ones (ie, "key=value" or "key-" for removals)
+func getLabelsChanges(old, new map[string]interface{}) []string {
+	args := []string{}
+	for k := range old {
+		if _, ok := new[k]; !ok {
+			args = append(args, k+"-")
+		}
+	}
+	for k, v := range new {
+		if prev, ok := old[k]; !ok || prev.(string) != v.(string) {
+			args = append(args, fmt.Sprintf("%s=%s", k, v.(string)))
+		}
+	}
+	sort.Strings(args)
+	return args
+}
+
+// getTaintsChanges returns the `kubectl taint` arguments for going from the
+// old taints to the new ones: the taints to remove (as "key:effect-") and
+// the taints to add or overwrite (as "key=value:effect")
+func getTaintsChanges(old, new []interface{}) ([]string, []string, error) {
+	oldTaints, err := getTaintsByID(old)
+	if err != nil {
+		return nil, nil, err
+	}
+	newTaints, err := getTaintsByID(new)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	removed := []string{}
+	for id := range oldTaints {
+		if _, ok := newTaints[id]; !ok {
+			removed = append(removed, id+"-")
+		}
+	}
+	added := []string{}
+	for id, taint := range newTaints {
+		if prev, ok := oldTaints[id]; !ok || prev != taint {
+			added = append(added, taint)
+		}
+	}
+	sort.Strings(removed)
+	sort.Strings(added)
+	return removed, added, nil
+}
+
+// getTaintsByID returns a map of taints (in the `kubectl taint` format) indexed by
+// their "key:effect", as taints are identified by their key and effect
+func getTaintsByID(taints []interface{}) (map[string]string, error) {
+	res := map[string]string{}
+	for _, t := range taints {
+		taint, err := common.ParseTaint(t.(string))
+		if err != nil {
+			return nil, err
+		}
+		res[fmt.Sprintf("%s:%s", taint.Key, taint.Effect)] = common.TaintToString(taint)
+	}
+	return res, nil
+}
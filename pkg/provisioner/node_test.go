@@ -0,0 +1,189 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package provisioner
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/terraform/helper/schema"
+	kubeadmapi "k8s.io/kubernetes/cmd/kubeadm/app/apis/kubeadm"
+
+	"github.com/inercia/terraform-provider-kubeadm/internal/assets"
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
+)
+
+func TestGetLabelsChanges(t *testing.T) {
+	old := map[string]interface{}{"pool": "cpu", "zone": "a", "tier": "frontend"}
+	new := map[string]interface{}{"pool": "gpu", "zone": "a", "spot": "true"}
+
+	expected := []string{"pool=gpu", "spot=true", "tier-"}
+	if args := getLabelsChanges(old, new); !reflect.DeepEqual(args, expected) {
+		t.Fatalf("Error: wrong labels changes: %+v != %+v", args, expected)
+	}
+
+	if args := getLabelsChanges(new, new); len(args) > 0 {
+		t.Fatalf("Error: unexpected labels changes: %+v", args)
+	}
+}
+
+func TestGetTaintsChanges(t *testing.T) {
+	old := []interface{}{"dedicated=ml:NoSchedule", "spot:PreferNoSchedule", "gpu:NoExecute"}
+	new := []interface{}{"dedicated=gpu:NoSchedule", "gpu:NoExecute", "spot:NoSchedule"}
+
+	removed, added, err := getTaintsChanges(old, new)
+	if err != nil {
+		t.Fatalf("Error: could not get taints changes: %s", err)
+	}
+	if expected := []string{"spot:PreferNoSchedule-"}; !reflect.DeepEqual(removed, expected) {
+		t.Fatalf("Error: wrong removed taints: %+v != %+v", removed, expected)
+	}
+	if expected := []string{"dedicated=gpu:NoSchedule", "spot:NoSchedule"}; !reflect.DeepEqual(added, expected) {
+		t.Fatalf("Error: wrong added taints: %+v != %+v", added, expected)
+	}
+
+	if _, _, err := getTaintsChanges(nil, []interface{}{"dedicated=gpu"}); err == nil {
+		t.Fatalf("Error: invalid taints should fail")
+	}
+}
+
+func TestGetKubeletSysconfig(t *testing.T) {
+	s := map[string]*schema.Schema{
+		"kubelet_args": {
+			Type:     schema.TypeMap,
+			Elem:     &schema.Schema{Type: schema.TypeString},
+			Optional: true,
+		},
+	}
+
+	d := schema.TestResourceDataRaw(t, s, map[string]interface{}{})
+	if sysconfig := string(getKubeletSysconfig(d)); sysconfig != assets.KubeletSysconfigCode {
+		t.Fatalf("Error: unexpected sysconfig without kubelet args:\n%s", sysconfig)
+	}
+
+	d = schema.TestResourceDataRaw(t, s, map[string]interface{}{
+		"kubelet_args": map[string]interface{}{
+			"max-pods":  "50",
+			"--node-ip": "10.0.0.2",
+		},
+	})
+	expected := "# kubelet extra configuration\nKUBELET_EXTRA_ARGS=\"--fail-swap-on=false --max-pods=50 --node-ip=10.0.0.2\""
+	if sysconfig := string(getKubeletSysconfig(d)); sysconfig != expected {
+		t.Fatalf("Error: wrong sysconfig:\n%s\nexpected:\n%s", sysconfig, expected)
+	}
+}
+
+func TestSetNodeRegistration(t *testing.T) {
+	config := map[string]interface{}{
+		"masters_taints": "dedicated=control-plane:NoExecute",
+		"workers_labels": "node.example.com/pool=default,tier=backend",
+		"workers_taints": "dedicated=ml:NoSchedule",
+	}
+
+	// a worker: the role defaults are merged with the node labels/taints
+	d := schema.TestResourceDataRaw(t, Provisioner().(*schema.Provisioner).Schema, map[string]interface{}{
+		"config":   config,
+		"join":     "10.0.0.1",
+		"nodename": "worker-0",
+		"labels": map[string]interface{}{
+			"node.example.com/pool": "gpu",
+		},
+		"taints": []interface{}{"dedicated=gpu:NoSchedule", "spot:PreferNoSchedule"},
+		"zone":   "eu-west-1a",
+	})
+
+	nodeReg := kubeadmapi.NodeRegistrationOptions{
+		KubeletExtraArgs: map[string]string{"node-labels": "rack=r1"},
+	}
+	if err := setNodeRegistration(d, &nodeReg); err != nil {
+		t.Fatalf("Error: could not set node registration: %s", err)
+	}
+	if nodeReg.Name != "worker-0" {
+		t.Fatalf("Error: wrong nodename: %q", nodeReg.Name)
+	}
+	expectedLabels := "failure-domain.beta.kubernetes.io/zone=eu-west-1a,node.example.com/pool=gpu,rack=r1,tier=backend"
+	if labels := nodeReg.KubeletExtraArgs["node-labels"]; labels != expectedLabels {
+		t.Fatalf("Error: wrong node labels: %q != %q", labels, expectedLabels)
+	}
+	expectedTaints := "dedicated=gpu:NoSchedule,spot:PreferNoSchedule"
+	if taints := common.TaintsToString(nodeReg.Taints); taints != expectedTaints {
+		t.Fatalf("Error: wrong taints: %q != %q", taints, expectedTaints)
+	}
+
+	// a master: the control plane taint must be kept
+	d = schema.TestResourceDataRaw(t, Provisioner().(*schema.Provisioner).Schema, map[string]interface{}{
+		"config": config,
+	})
+	nodeReg = kubeadmapi.NodeRegistrationOptions{}
+	if err := setNodeRegistration(d, &nodeReg); err != nil {
+		t.Fatalf("Error: could not set node registration: %s", err)
+	}
+	if len(nodeReg.KubeletExtraArgs["node-labels"]) > 0 {
+		t.Fatalf("Error: unexpected node labels in master: %q", nodeReg.KubeletExtraArgs["node-labels"])
+	}
+	expectedTaints = "node-role.kubernetes.io/master:NoSchedule,dedicated=control-plane:NoExecute"
+	if taints := common.TaintsToString(nodeReg.Taints); taints != expectedTaints {
+		t.Fatalf("Error: wrong taints: %q != %q", taints, expectedTaints)
+	}
+
+	// a schedulable master: the control plane taint must not be added
+	schedulableConfig := map[string]interface{}{
+		"masters_taints":      "dedicated=control-plane:NoExecute",
+		"schedulable_masters": "true",
+	}
+	d = schema.TestResourceDataRaw(t, Provisioner().(*schema.Provisioner).Schema, map[string]interface{}{
+		"config": schedulableConfig,
+	})
+	nodeReg = kubeadmapi.NodeRegistrationOptions{}
+	if err := setNodeRegistration(d, &nodeReg); err != nil {
+		t.Fatalf("Error: could not set node registration: %s", err)
+	}
+	expectedTaints = "dedicated=control-plane:NoExecute"
+	if taints := common.TaintsToString(nodeReg.Taints); taints != expectedTaints {
+		t.Fatalf("Error: wrong taints: %q != %q", taints, expectedTaints)
+	}
+	if remove, err := mustRemoveControlPlaneTaint(d); err != nil || remove {
+		t.Fatalf("Error: the control plane taint should not be removed when some taints are provided")
+	}
+
+	// no taints: kubeadm defaults must be used
+	d = schema.TestResourceDataRaw(t, Provisioner().(*schema.Provisioner).Schema, map[string]interface{}{})
+	nodeReg = kubeadmapi.NodeRegistrationOptions{}
+	if err := setNodeRegistration(d, &nodeReg); err != nil {
+		t.Fatalf("Error: could not set node registration: %s", err)
+	}
+	if nodeReg.Taints != nil {
+		t.Fatalf("Error: unexpected taints: %+v", nodeReg.Taints)
+	}
+	if remove, err := mustRemoveControlPlaneTaint(d); err != nil || remove {
+		t.Fatalf("Error: the control plane taint should not be removed when masters are not schedulable")
+	}
+
+	// no taints in a schedulable master: the kubeadm taint must be removed afterwards
+	d = schema.TestResourceDataRaw(t, Provisioner().(*schema.Provisioner).Schema, map[string]interface{}{
+		"config": map[string]interface{}{"schedulable_masters": "true"},
+	})
+	if remove, err := mustRemoveControlPlaneTaint(d); err != nil || !remove {
+		t.Fatalf("Error: the control plane taint should be removed in a schedulable master")
+	}
+
+	// ... but not in workers
+	d = schema.TestResourceDataRaw(t, Provisioner().(*schema.Provisioner).Schema, map[string]interface{}{
+		"config": map[string]interface{}{"schedulable_masters": "true"},
+		"join":   "10.0.0.1",
+	})
+	if remove, err := mustRemoveControlPlaneTaint(d); err != nil || remove {
+		t.Fatalf("Error: the control plane taint should not be removed in a worker")
+	}
+}
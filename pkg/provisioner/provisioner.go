@@ -78,6 +78,29 @@ func applyFn(ctx context.Context) error {
 		return action.Apply(newCtx)
 	}
 
+	//
+	// resource upgrade
+	//
+
+	upgrade := d.Get("upgrade").(bool)
+	if upgrade {
+		ssh.Debug("node will be upgraded")
+		return ssh.DoWithCleanup(
+			doKubeadmUpgrade(d),
+			ssh.DoCleanupLeftovers()).Apply(newCtx)
+	}
+
+	//
+	// etcd snapshot
+	//
+
+	if snapshot := getEtcdSnapshotFromResourceData(d); len(snapshot) > 0 {
+		ssh.Debug("etcd snapshot will be saved at %q", snapshot)
+		return ssh.DoWithCleanup(
+			doEtcdSnapshotSave(d, snapshot),
+			ssh.DoCleanupLeftovers()).Apply(newCtx)
+	}
+
 	//
 	// resource creation
 	//
@@ -92,8 +115,23 @@ func applyFn(ctx context.Context) error {
 		actions = append(actions, ssh.DoMessageInfo("New resource: provisioning"))
 	}
 
+	actions = append(actions, doProvisionNode(d))
+
+	return ssh.ActionList{
+		ssh.DoWithCleanup(
+			actions,
+			ssh.DoCleanupLeftovers()),
+	}.Apply(newCtx)
+}
+
+// doProvisionNode returns the actions for adding a machine to the cluster:
+// installing kubeadm, preparing the kubelet and doing a `kubeadm init/join`
+// (depending on the `join` and `role` arguments)
+func doProvisionNode(d *schema.ResourceData) ssh.Action {
 	// add the actions for installing kubeadm
-	actions = append(actions, doKubeadmSetup(d))
+	actions := ssh.ActionList{
+		doKubeadmSetup(d),
+	}
 
 	// determine what to do (init, join or join --control-plane) depending on the argument provided
 	join := getJoinFromResourceData(d)
@@ -106,12 +144,16 @@ func applyFn(ctx context.Context) error {
 		doPrepareCRI(),
 		doUploadResolvConf(d),
 		ssh.DoEnableService("kubelet.service"),
-		ssh.DoUploadBytesToFile([]byte(assets.KubeletSysconfigCode), getSysconfigPathFromResourceData(d)),
+		ssh.DoUploadBytesToFile(getKubeletSysconfig(d), getSysconfigPathFromResourceData(d)),
 		ssh.DoUploadBytesToFile([]byte(assets.KubeletServiceCode), getServicePathFromResourceData(d)),
 		ssh.DoUploadBytesToFile([]byte(assets.KubeadmDropinCode), getDropinPathFromResourceData(d)),
 	)
 
-	if len(join) == 0 {
+	if len(getEtcdRestoreFromResourceData(d)) > 0 && (len(join) > 0 || role == "etcd") {
+		actions = append(actions, ssh.ActionError("etcd snapshots can only be restored in the seeder"))
+	} else if role == "etcd" {
+		actions = append(actions, doKubeadmEtcdMember(d))
+	} else if len(join) == 0 {
 		switch role {
 		case "worker":
 			actions = append(actions, ssh.ActionError(fmt.Sprintf("role is %q while no \"join\" argument has been provided", role)))
@@ -138,9 +180,5 @@ func applyFn(ctx context.Context) error {
 		doPrintEtcdStatus(d),
 	)
 
-	return ssh.ActionList{
-		ssh.DoWithCleanup(
-			actions,
-			ssh.DoCleanupLeftovers()),
-	}.Apply(newCtx)
+	return actions
 }
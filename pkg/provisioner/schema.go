@@ -17,12 +17,16 @@ package provisioner
 import (
 	"fmt"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/hashicorp/terraform/helper/schema"
 	"github.com/hashicorp/terraform/helper/validation"
 	"github.com/hashicorp/terraform/terraform"
+	corev1 "k8s.io/api/core/v1"
 
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
 	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
 )
 
@@ -46,8 +50,8 @@ func Provisioner() terraform.ResourceProvisioner {
 				Type:         schema.TypeString,
 				Optional:     true,
 				Default:      "",
-				Description:  "role of this machine: master or worker",
-				ValidateFunc: validation.StringInSlice([]string{"master", "worker"}, true),
+				Description:  "role of this machine: master, worker or etcd",
+				ValidateFunc: validation.StringInSlice([]string{"master", "worker", "etcd"}, true),
 			},
 			"ignore_checks": {
 				Type:        schema.TypeList,
@@ -61,6 +65,36 @@ func Provisioner() terraform.ResourceProvisioner {
 				Default:     false,
 				Description: "when true, remove this node from the cluster instead of adding it",
 			},
+			"force": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "when true, remove this node from the etcd cluster even if the etcd cluster would lose quorum",
+			},
+			"upgrade": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "when true, upgrade this node to the Kubernetes version in the config instead of adding it",
+			},
+			"etcd_snapshot": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Default:     "",
+				Description: "when not empty, save a snapshot of etcd in this local file instead of adding the node",
+			},
+			"etcd_restore": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Default:     "",
+				Description: "local etcd snapshot to restore when initializing a new cluster (only for the seeder)",
+			},
+			"etcd_restore_skip_checksum": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "when true, restore the etcd snapshot even if no checksum file is found next to it",
+			},
 			"nodename": {
 				Type:        schema.TypeString,
 				Optional:    true,
@@ -73,6 +107,32 @@ func Provisioner() terraform.ResourceProvisioner {
 				Description:  "for masters, IP/DNS:port to listen at",
 				ValidateFunc: common.ValidateHostPort,
 			},
+			"labels": {
+				Type:         schema.TypeMap,
+				Elem:         &schema.Schema{Type: schema.TypeString},
+				Optional:     true,
+				Description:  "labels for the node (in addition to the labels for its role in the kubeadm resource)",
+				ValidateFunc: common.ValidateLabels,
+			},
+			"taints": {
+				Type: schema.TypeList,
+				Elem: &schema.Schema{
+					Type:         schema.TypeString,
+					ValidateFunc: common.ValidateTaint,
+				},
+				Optional:    true,
+				Description: "taints for the node, as 'key=value:effect' (in addition to the taints for its role in the kubeadm resource)",
+			},
+			"zone": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "zone of the node (for the well-known topology label)",
+			},
+			"region": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "region of the node (for the well-known topology label)",
+			},
 			"prevent_sudo": {
 				Type:        schema.TypeBool,
 				Optional:    true,
@@ -190,6 +250,54 @@ func getKubeconfigFromResourceData(d *schema.ResourceData) string {
 	return f
 }
 
+// getForceFromResourceData returns true if the node must be removed even when that is not safe
+func getForceFromResourceData(d *schema.ResourceData) bool {
+	return d.Get("force").(bool)
+}
+
+// getEtcdSnapshotFromResourceData returns the local file where the etcd snapshot must be saved
+func getEtcdSnapshotFromResourceData(d *schema.ResourceData) string {
+	return getLocalPathFromResourceData(d, "etcd_snapshot")
+}
+
+// getEtcdRestoreFromResourceData returns the local etcd snapshot that must be restored
+func getEtcdRestoreFromResourceData(d *schema.ResourceData) string {
+	return getLocalPathFromResourceData(d, "etcd_restore")
+}
+
+// getEtcdRestoreSkipChecksumFromResourceData returns true if the etcd snapshot
+// can be restored without a checksum file
+func getEtcdRestoreSkipChecksumFromResourceData(d *schema.ResourceData) bool {
+	skip, ok := d.GetOk("etcd_restore_skip_checksum")
+	return ok && skip.(bool)
+}
+
+// getLocalPathFromResourceData returns the absolute path for a local file in some argument
+func getLocalPathFromResourceData(d *schema.ResourceData, key string) string {
+	opt, ok := d.GetOk(key)
+	if !ok || len(strings.TrimSpace(opt.(string))) == 0 {
+		return ""
+	}
+	f, err := filepath.Abs(strings.TrimSpace(opt.(string)))
+	if err != nil {
+		return ""
+	}
+	return f
+}
+
+// getKubeconfigContentsFromResourceData returns the admin kubeconfig generated by the provider (if any)
+func getKubeconfigContentsFromResourceData(d *schema.ResourceData) []byte {
+	kubeconfigOpt, ok := d.GetOk("config.kubeconfig")
+	if !ok {
+		return nil
+	}
+	contents, err := common.FromTerraformSafeString(kubeconfigOpt.(string))
+	if err != nil {
+		return nil
+	}
+	return contents
+}
+
 func getSysconfigPathFromResourceData(d *schema.ResourceData) string {
 	// NOTE: the "install" block is optional, so there will be no
 	// default values for "install.0.XXX" if the "install" block has not been given...
@@ -235,6 +343,15 @@ func getTokenFromResourceData(d *schema.ResourceData) string {
 	return ""
 }
 
+// getCACertHashFromResourceData returns the hash of the CA certificate in the config
+func getCACertHashFromResourceData(d *schema.ResourceData) (string, error) {
+	caCrt, ok := d.GetOk("config.ca_crt")
+	if !ok {
+		return "", fmt.Errorf("no CA certificate found in config")
+	}
+	return common.CACertHash([]byte(caCrt.(string)))
+}
+
 // getKubectlFromResourceData returns the kubectl binary path from the config
 func getKubectlFromResourceData(d *schema.ResourceData) string {
 	if kubectlPathOpt, ok := d.GetOk("install.0.kubectl_path"); ok {
@@ -250,3 +367,198 @@ func getNodenameFromResourceData(d *schema.ResourceData) string {
 	}
 	return ""
 }
+
+// isControlPlaneFromResourceData returns true if the node is (or will be) part of the control plane
+func isControlPlaneFromResourceData(d *schema.ResourceData) bool {
+	return len(getJoinFromResourceData(d)) == 0 || getRoleFromResourceData(d) == "master"
+}
+
+// getCertDistributionFromResourceData returns how the certificates are
+// distributed to the masters joining the cluster: "ssh" or "kubeadm"
+func getCertDistributionFromResourceData(d *schema.ResourceData) string {
+	if mode, ok := d.GetOk("config.cert_distribution"); ok && len(mode.(string)) > 0 {
+		return mode.(string)
+	}
+	return common.DefCertDistribution
+}
+
+// getCertificateKeyFromResourceData returns the key for encrypting the certificates
+// uploaded to the cluster
+func getCertificateKeyFromResourceData(d *schema.ResourceData) string {
+	if key, ok := d.GetOk("config.certificate_key"); ok {
+		return key.(string)
+	}
+	return ""
+}
+
+// getAPIVipFromResourceData returns the virtual IP of the control plane (if any)
+func getAPIVipFromResourceData(d *schema.ResourceData) string {
+	if vip, ok := d.GetOk("config.api_vip"); ok {
+		return vip.(string)
+	}
+	return ""
+}
+
+// getEtcdManagedFromResourceData returns true if the members of the external
+// etcd cluster are bootstrapped by the provisioner
+func getEtcdManagedFromResourceData(d *schema.ResourceData) bool {
+	opt, ok := d.GetOk("config.etcd_managed")
+	if !ok {
+		return false
+	}
+	managed, err := strconv.ParseBool(opt.(string))
+	if err != nil {
+		return false
+	}
+	return managed
+}
+
+// getSchedulableMastersFromResourceData returns true if the masters must not be tainted
+func getSchedulableMastersFromResourceData(d *schema.ResourceData) bool {
+	opt, ok := d.GetOk("config.schedulable_masters")
+	if !ok {
+		return false
+	}
+	schedulable, err := strconv.ParseBool(opt.(string))
+	if err != nil {
+		return false
+	}
+	return schedulable
+}
+
+// getNodeLabelsFromResourceData returns the labels for the node: the labels for its role
+// in the config, the `labels` and the topology labels for the `zone` and `region`
+func getNodeLabelsFromResourceData(d *schema.ResourceData) (map[string]string, error) {
+	defaultsKey := "config.workers_labels"
+	if isControlPlaneFromResourceData(d) {
+		defaultsKey = "config.masters_labels"
+	}
+
+	labels := map[string]string{}
+	if defaultsOpt, ok := d.GetOk(defaultsKey); ok {
+		defaults, err := common.ParseLabels(defaultsOpt.(string))
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range defaults {
+			labels[k] = v
+		}
+	}
+	if labelsOpt, ok := d.GetOk("labels"); ok {
+		for k, v := range labelsOpt.(map[string]interface{}) {
+			labels[k] = v.(string)
+		}
+	}
+	if zone, ok := d.GetOk("zone"); ok {
+		labels[corev1.LabelZoneFailureDomain] = zone.(string)
+	}
+	if region, ok := d.GetOk("region"); ok {
+		labels[corev1.LabelZoneRegion] = region.(string)
+	}
+	return labels, nil
+}
+
+// getNodeTaintsFromResourceData returns the taints for the node: the taints for its role
+// in the config and the `taints`. Taints are identified by their key and effect, so
+// the `taints` replace any taint for the role with the same key and effect.
+func getNodeTaintsFromResourceData(d *schema.ResourceData) ([]corev1.Taint, error) {
+	defaultsKey := "config.workers_taints"
+	if isControlPlaneFromResourceData(d) {
+		defaultsKey = "config.masters_taints"
+	}
+
+	taints := []corev1.Taint{}
+	if defaultsOpt, ok := d.GetOk(defaultsKey); ok {
+		defaults, err := common.ParseTaints(defaultsOpt.(string))
+		if err != nil {
+			return nil, err
+		}
+		taints = append(taints, defaults...)
+	}
+	if taintsOpt, ok := d.GetOk("taints"); ok {
+		for _, t := range taintsOpt.([]interface{}) {
+			taint, err := common.ParseTaint(t.(string))
+			if err != nil {
+				return nil, err
+			}
+			taints = mergeTaint(taints, taint)
+		}
+	}
+	return taints, nil
+}
+
+// mergeTaint adds a taint to a list of taints, replacing any taint with the same key and effect
+func mergeTaint(taints []corev1.Taint, taint corev1.Taint) []corev1.Taint {
+	for i := range taints {
+		if taints[i].Key == taint.Key && taints[i].Effect == taint.Effect {
+			taints[i] = taint
+			return taints
+		}
+	}
+	return append(taints, taint)
+}
+
+// getKubeletArgsFromResourceData returns the extra arguments for the kubelet (as "--key=value"),
+// sorted by name
+func getKubeletArgsFromResourceData(d *schema.ResourceData) []string {
+	argsOpt, ok := d.GetOk("kubelet_args")
+	if !ok {
+		return nil
+	}
+
+	args := []string{}
+	for k, v := range argsOpt.(map[string]interface{}) {
+		args = append(args, fmt.Sprintf("--%s=%s", strings.TrimLeft(k, "-"), v.(string)))
+	}
+	sort.Strings(args)
+	return args
+}
+
+// getKubeVersionFromResourceData returns the Kubernetes version in the config
+func getKubeVersionFromResourceData(d *schema.ResourceData) string {
+	if versionOpt, ok := d.GetOk("config.kube_version"); ok {
+		return strings.TrimSpace(versionOpt.(string))
+	}
+	return ""
+}
+
+// getSkipKubeProxyFromResourceData returns true if kube-proxy must not be installed
+// (ie, because the CNI plugin will replace it)
+func getSkipKubeProxyFromResourceData(d *schema.ResourceData) bool {
+	pluginOpt, ok := d.GetOk("config.cni_plugin")
+	if !ok || strings.ToLower(strings.TrimSpace(pluginOpt.(string))) != "cilium" {
+		return false
+	}
+	replacementOpt, ok := d.GetOk("config.cilium_kube_proxy_replacement")
+	if !ok {
+		return false
+	}
+	replacement, err := strconv.ParseBool(replacementOpt.(string))
+	if err != nil {
+		return false
+	}
+	return replacement
+}
+
+// getContainerRuntimeFromResourceData returns the container runtime used in the nodes,
+// as determined by the CRI socket in the config
+func getContainerRuntimeFromResourceData(d *schema.ResourceData) ssh.ContainerRuntime {
+	initConfig, _, err := common.InitConfigFromResourceData(d)
+	if err != nil {
+		return ssh.NewContainerRuntime("")
+	}
+	return ssh.NewContainerRuntime(initConfig.NodeRegistration.CRISocket)
+}
+
+// isExternalEtcdFromResourceData returns true if the cluster uses an external etcd cluster
+// (and this machine is not one of its members)
+func isExternalEtcdFromResourceData(d *schema.ResourceData) bool {
+	if getRoleFromResourceData(d) == "etcd" {
+		return false
+	}
+	initConfig, _, err := common.InitConfigFromResourceData(d)
+	if err != nil {
+		return false
+	}
+	return initConfig.Etcd.External != nil
+}
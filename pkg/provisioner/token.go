@@ -17,8 +17,10 @@ package provisioner
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"regexp"
 	"strings"
 	"time"
@@ -33,6 +35,7 @@ import (
 
 const (
 	// TTL for tokens created for a new join, when no previous token is available
+	// and no TTL has been specified in the `token` block of the kubeadm resource
 	newJoinTokenTTL = "1h"
 )
 
@@ -50,11 +53,70 @@ type KubeadmToken struct {
 }
 
 func (token KubeadmToken) IsExpired(now time.Time) bool {
+	// tokens with no expiration time never expire
+	if token.Expires.IsZero() {
+		return false
+	}
 	return now.After(token.Expires)
 }
 
+// kubeadmTokenJSON is a token in the output of `kubeadm token list -o json`
+type kubeadmTokenJSON struct {
+	Token       string    `json:"token"`
+	Description string    `json:"description"`
+	TTL         string    `json:"ttl"`
+	Expires     time.Time `json:"expires"`
+	Usages      []string  `json:"usages"`
+	Groups      []string  `json:"groups"`
+}
+
 type KubeadmTokensSet map[string]KubeadmToken
 
+// FromJSON parses the output of `kubeadm token list -o json`
+// (ie, a stream of JSON objects, one per token)
+func (kt KubeadmTokensSet) FromJSON(s string) error {
+	// skip anything before the first object
+	start := strings.Index(s, "{")
+	if start < 0 {
+		if len(strings.TrimSpace(s)) == 0 {
+			return nil // no tokens
+		}
+		return errKubeadmParse
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(s[start:]))
+	for {
+		token := kubeadmTokenJSON{}
+		if err := decoder.Decode(&token); err == io.EOF {
+			break
+		} else if err != nil {
+			ssh.Debug("could not decode token: %s", err)
+			return errKubeadmParse
+		}
+
+		matched, err := regexp.MatchString(common.TokenRegex, token.Token)
+		if err != nil {
+			return err
+		}
+		if !matched {
+			ssh.Debug("%q does not match %q: ignored", token.Token, common.TokenRegex)
+			continue
+		}
+
+		kt[token.Token] = KubeadmToken{
+			Token:       token.Token,
+			TTL:         token.TTL,
+			Expires:     token.Expires,
+			Usages:      strings.Join(token.Usages, ","),
+			Description: token.Description,
+			Extra:       strings.Join(token.Groups, ","),
+		}
+	}
+	return nil
+}
+
+// FromString parses the (table) output of `kubeadm token list`,
+// used for versions of kubeadm that do not support the JSON output
 func (kt KubeadmTokensSet) FromString(s string) error {
 	// Parse something like:
 	//
@@ -70,8 +132,9 @@ func (kt KubeadmTokensSet) FromString(s string) error {
 			continue
 		}
 
+		// note: the description can contain spaces, so we can have more than 6 components
 		components := strings.Fields(lineCleaned)
-		if len(components) != 6 {
+		if len(components) < 6 {
 			ssh.Debug("does not look like a token line (len=%d): %q", len(components), lineCleaned)
 			continue
 		}
@@ -89,16 +152,17 @@ func (kt KubeadmTokensSet) FromString(s string) error {
 		}
 
 		// parse the expiration time
+		// (tokens with no expiration time show "<never>", so they get a zero time)
 		str := strings.TrimSpace(components[2])
-		expiration, err := time.Parse(time.RFC3339, str)
+		expiration, _ := time.Parse(time.RFC3339, str)
 
 		kt[maybeToken] = KubeadmToken{
 			Token:       maybeToken,
 			TTL:         strings.TrimSpace(components[1]),
 			Expires:     expiration,
 			Usages:      strings.TrimSpace(components[3]),
-			Description: strings.TrimSpace(components[4]),
-			Extra:       strings.TrimSpace(components[5]),
+			Description: strings.Join(components[4:len(components)-1], " "),
+			Extra:       strings.TrimSpace(components[len(components)-1]),
 		}
 	}
 	return nil
@@ -129,21 +193,36 @@ func DoExecKubeadmToken(d *schema.ResourceData, cmd string) ssh.Action {
 
 // DoGetCurrentRemoteTokens get the list of remote tokens stored in the API server
 func DoGetCurrentRemoteTokens(d *schema.ResourceData, kts KubeadmTokensSet) ssh.Action {
+	var jsonBuf bytes.Buffer
 	var buf bytes.Buffer
 
 	// run "kubeadm token list" in the remote host, uploading the kubeconfig before
-	return ssh.ActionList{
-		ssh.DoSendingExecOutputToWriter(DoExecKubeadmToken(d, "list"), &buf),
-		ssh.ActionFunc(func(ctx context.Context) ssh.Action {
-			ssh.Debug("parsing kubeadm output")
-			ssh.Debug("%s", buf.String())
-			if err := kts.FromString(buf.String()); err != nil {
-				ssh.Debug("error when parsing 'kubeadm token' output: %s", err)
-				return ssh.ActionError(fmt.Sprintf("Could not parse kubeadm output: %s", err))
+	// note: older versions of kubeadm do not support the JSON output, so we
+	//       fall back to parsing the table output
+	return ssh.ActionFunc(func(ctx context.Context) ssh.Action {
+		res := ssh.DoSendingExecOutputToWriter(DoExecKubeadmToken(d, "list -o json"), &jsonBuf).Apply(ctx)
+		if !ssh.IsError(res) {
+			ssh.Debug("parsing kubeadm JSON output")
+			ssh.Debug("%s", jsonBuf.String())
+			if err := kts.FromJSON(jsonBuf.String()); err == nil {
+				return nil
 			}
-			return nil
-		}),
-	}
+		}
+
+		ssh.Debug("could not get the list of tokens in JSON: falling back to the table output")
+		return ssh.ActionList{
+			ssh.DoSendingExecOutputToWriter(DoExecKubeadmToken(d, "list"), &buf),
+			ssh.ActionFunc(func(ctx context.Context) ssh.Action {
+				ssh.Debug("parsing kubeadm output")
+				ssh.Debug("%s", buf.String())
+				if err := kts.FromString(buf.String()); err != nil {
+					ssh.Debug("error when parsing 'kubeadm token' output: %s", err)
+					return ssh.ActionError(fmt.Sprintf("Could not parse kubeadm output: %s", err))
+				}
+				return nil
+			}),
+		}
+	})
 }
 
 // SetNewToken sets a new token in the configuration in the ResourceData
@@ -155,10 +234,20 @@ func DoSetNewToken(d *schema.ResourceData, newToken string) ssh.Action {
 		if err != nil {
 			return ssh.ActionError(fmt.Sprintf("could not get a valid 'config' for join'ing: %s", err))
 		}
-		joinConfig.Discovery.BootstrapToken = &kubeadmapi.BootstrapTokenDiscovery{
-			Token:                    newToken,
-			UnsafeSkipCAVerification: true,
+		// keep the CA pinning (or the lack of it) in the current configuration
+		discovery := &kubeadmapi.BootstrapTokenDiscovery{}
+		if joinConfig.Discovery.BootstrapToken != nil {
+			discovery = joinConfig.Discovery.BootstrapToken.DeepCopy()
+		} else {
+			caCertHash, err := getCACertHashFromResourceData(d)
+			if err != nil {
+				return ssh.ActionError(fmt.Sprintf("could not get a CA certificate hash: %s", err))
+			}
+			discovery.CACertHashes = []string{caCertHash}
 		}
+		discovery.Token = newToken
+
+		joinConfig.Discovery.BootstrapToken = discovery
 		joinConfig.Discovery.TLSBootstrapToken = newToken
 
 		if err := common.JoinConfigToResourceData(d, joinConfig); err != nil {
@@ -220,9 +309,30 @@ func doRefreshToken(d *schema.ResourceData) ssh.Action {
 			ssh.DoMessageInfo("%q is still a valid token", curTokenInJoinConfig),
 			ssh.ActionList{
 				ssh.DoMessageWarn("%q is not valid token anymore: will create a new token %q...", curTokenInJoinConfig, newToken),
-				ssh.DoSendingExecOutputToDevNull(DoExecKubeadmToken(d, fmt.Sprintf("create --ttl=%s %s", newJoinTokenTTL, newToken))),
+				ssh.DoSendingExecOutputToDevNull(DoExecKubeadmToken(d, "create "+getNewTokenArgs(d, newToken))),
 				DoSetNewToken(d, newToken),
 				ssh.DoMessageInfo("New token %q created successfully.", newToken),
 			}),
 	}
 }
+
+// getNewTokenArgs returns the arguments for `kubeadm token create`, using the
+// options in the `token` block of the kubeadm resource
+func getNewTokenArgs(d *schema.ResourceData, token string) string {
+	ttl := newJoinTokenTTL
+	if v, ok := d.GetOk("config.token_ttl"); ok {
+		ttl = v.(string)
+	}
+
+	args := []string{fmt.Sprintf("--ttl=%s", ttl)}
+	if v, ok := d.GetOk("config.token_usages"); ok {
+		args = append(args, fmt.Sprintf("--usages=%s", v.(string)))
+	}
+	if v, ok := d.GetOk("config.token_groups"); ok {
+		args = append(args, fmt.Sprintf("--groups=%s", v.(string)))
+	}
+	if v, ok := d.GetOk("config.token_description"); ok {
+		args = append(args, fmt.Sprintf("--description=%q", v.(string)))
+	}
+	return strings.Join(append(args, token), " ")
+}
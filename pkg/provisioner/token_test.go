@@ -17,6 +17,8 @@ package provisioner
 import (
 	"testing"
 	"time"
+
+	"github.com/hashicorp/terraform/helper/schema"
 )
 
 func TestGetKubeadmTokensFromString(t *testing.T) {
@@ -60,3 +62,128 @@ TOKEN                     TTL       EXPIRES                USAGES
 		}
 	}
 }
+
+func TestGetKubeadmTokensFromJSON(t *testing.T) {
+	now, _ := time.Parse(time.RFC822, "01 Jan 20 20:00 UTC")
+
+	testCases := map[string]struct {
+		output  string
+		json    bool
+		expired map[string]bool
+	}{
+		"json": {
+			output: `
+{
+    "kind": "BootstrapToken",
+    "apiVersion": "output.kubeadm.k8s.io/v1alpha1",
+    "creationTimestamp": null,
+    "token": "5befc5.a36864a4c9cc2c7d",
+    "description": "token for the workers",
+    "ttl": "22h0m0s",
+    "expires": "2019-07-10T15:08:31Z",
+    "usages": [
+        "authentication",
+        "signing"
+    ],
+    "groups": [
+        "system:bootstrappers:kubeadm:default-node-token"
+    ]
+}
+{
+    "kind": "BootstrapToken",
+    "apiVersion": "output.kubeadm.k8s.io/v1alpha1",
+    "creationTimestamp": null,
+    "token": "9befc8.a36864a4c9cc2c7d",
+    "usages": [
+        "authentication",
+        "signing"
+    ],
+    "groups": [
+        "system:bootstrappers:kubeadm:default-node-token"
+    ]
+}
+`,
+			json: true,
+			expired: map[string]bool{
+				"5befc5.a36864a4c9cc2c7d": true,
+				"9befc8.a36864a4c9cc2c7d": false, // no expiration
+			},
+		},
+		"table with spaces in description": {
+			output: `
+TOKEN                     TTL         EXPIRES                USAGES                   DESCRIPTION             EXTRA GROUPS\r
+5befc5.a36864a4c9cc2c7d   22h         2019-07-10T15:08:31Z   authentication,signing   token for the workers   system:bootstrappers:kubeadm:default-node-token\r
+9befc8.a36864a4c9cc2c7d   <forever>   <never>                authentication,signing   <none>                  system:bootstrappers:kubeadm:default-node-token\r
+`,
+			json: false,
+			expired: map[string]bool{
+				"5befc5.a36864a4c9cc2c7d": true,
+				"9befc8.a36864a4c9cc2c7d": false,
+			},
+		},
+		"no tokens": {
+			output:  "",
+			json:    true,
+			expired: map[string]bool{},
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			tokens := KubeadmTokensSet{}
+			var err error
+			if testCase.json {
+				err = tokens.FromJSON(testCase.output)
+			} else {
+				err = tokens.FromString(testCase.output)
+			}
+			if err != nil {
+				t.Fatalf("Error: %v", err)
+			}
+
+			if len(tokens) != len(testCase.expired) {
+				t.Fatalf("error: %d tokens found, %d expected", len(tokens), len(testCase.expired))
+			}
+			for _, token := range tokens {
+				expired, ok := testCase.expired[token.Token]
+				if !ok {
+					t.Fatalf("error: token %q not found in tests cases table", token.Token)
+				}
+				if expired != token.IsExpired(now) {
+					t.Fatalf("error: token %q reports as 'expired=%t' but we expected '%t'", token.Token, token.IsExpired(now), expired)
+				}
+			}
+
+			if token, ok := tokens["5befc5.a36864a4c9cc2c7d"]; ok && token.Description != "token for the workers" {
+				t.Fatalf("error: unexpected description %q", token.Description)
+			}
+		})
+	}
+
+	tokens := KubeadmTokensSet{}
+	if err := tokens.FromJSON("unknown flag: --output"); err == nil {
+		t.Fatalf("error expected when parsing a non-JSON output")
+	}
+}
+
+func TestGetNewTokenArgs(t *testing.T) {
+	token := "5befc5.a36864a4c9cc2c7d"
+
+	d := schema.TestResourceDataRaw(t, Provisioner().(*schema.Provisioner).Schema, map[string]interface{}{})
+	if args := getNewTokenArgs(d, token); args != "--ttl=1h "+token {
+		t.Fatalf("Error: wrong arguments without token options: %q", args)
+	}
+
+	d = schema.TestResourceDataRaw(t, Provisioner().(*schema.Provisioner).Schema, map[string]interface{}{
+		"config": map[string]interface{}{
+			"token_ttl":         "2h0m0s",
+			"token_usages":      "signing,authentication",
+			"token_groups":      "system:bootstrappers:kubeadm:default-node-token",
+			"token_description": "some token",
+		},
+	})
+	expected := `--ttl=2h0m0s --usages=signing,authentication --groups=system:bootstrappers:kubeadm:default-node-token --description="some token" ` + token
+	if args := getNewTokenArgs(d, token); args != expected {
+		t.Fatalf("Error: wrong arguments: %q != %q", args, expected)
+	}
+}